@@ -0,0 +1,391 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for packing and unpacking tar (optionally gzipped) and zip
+  archives.  File permissions are preserved.  When unpacking, entries that
+  would land outside the destination directory (via absolute paths, "..",
+  or symbolic links) are rejected.
+\*****************************************************************************/
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*****************************************************************************\
+  Check if the archive name calls for gzip compression.
+\*****************************************************************************/
+
+func isGzipName(archive string) bool {
+	return strings.HasSuffix(archive, ".gz") || strings.HasSuffix(archive, ".tgz")
+}
+
+/*****************************************************************************\
+  Return the path at which to unpack an archive entry within dest, or an
+  error if the entry would land outside dest, whether by its name or by
+  symbolic links already unpacked along its path.
+\*****************************************************************************/
+
+func safeArchivePath(dest string, name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return "", Error("Illegal absolute path \"%s\" in archive", name)
+	}
+	target := filepath.Join(dest, name)
+	if !withinDir(filepath.Clean(dest), target) {
+		return "", Error("Illegal path \"%s\" in archive (outside of %s)", name, dest)
+	}
+	real_dest, err := realPath(dest)
+	if err != nil {
+		return "", Error("Error resolving directory \"%s\": %v", dest, err)
+	}
+	real_target, err := realPath(target)
+	if err != nil || !withinDir(real_dest, real_target) {
+		return "", Error("Illegal path \"%s\" in archive (outside of %s via a symbolic link)", name, dest)
+	}
+	return target, nil
+}
+
+func withinDir(dir string, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(os.PathSeparator))
+}
+
+/*****************************************************************************\
+  Return the absolute path with all symbolic links resolved, of which the
+  components not yet existing are taken as is.  The path is not cleaned
+  before resolving, so "link/.." is the parent of the link's target.  A
+  dangling symbolic link is an error.
+\*****************************************************************************/
+
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return resolvePath(path)
+}
+
+func resolvePath(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err == nil || !os.IsNotExist(err) {
+		return real, err
+	} else if _, err = os.Lstat(path); err == nil {
+		return "", Error("dangling symbolic link %s", path)
+	}
+	i := strings.LastIndex(path, string(os.PathSeparator))
+	if i < 0 || i == len(path)-1 {
+		return path, nil
+	}
+	parent := path[:i]
+	if parent == "" || strings.HasSuffix(parent, ":") {
+		parent += string(os.PathSeparator)
+	}
+	if real, err = resolvePath(parent); err != nil {
+		return "", err
+	}
+	return filepath.Join(real, path[i+1:]), nil
+}
+
+/*****************************************************************************\
+  Pack the contents of the directory dir into a tar archive, compressed with
+  gzip if the archive name ends in .gz or .tgz.
+\*****************************************************************************/
+
+func Tar(archive string, dir string) (err error) {
+
+	file, err := os.Create(archive)
+	if err != nil {
+		return Error("Error creating archive \"%s\": %v", archive, err)
+	}
+	defer func() {
+		if close_err := file.Close(); err == nil && close_err != nil {
+			err = Error("Error closing archive \"%s\": %v", archive, close_err)
+		}
+	}()
+
+	var gz *gzip.Writer
+	var writer io.Writer = file
+	if isGzipName(archive) {
+		gz = gzip.NewWriter(file)
+		writer = gz
+	}
+	tw := tar.NewWriter(writer)
+
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if err = tw.WriteHeader(header); err != nil {
+			return Error("Error writing archive \"%s\": %v", archive, err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFileTo(tw, path)
+	})
+	// Closing writes the tar end blocks, then the gzip trailer.
+	if close_err := tw.Close(); err == nil && close_err != nil {
+		err = Error("Error writing archive \"%s\": %v", archive, close_err)
+	}
+	if gz != nil {
+		if close_err := gz.Close(); err == nil && close_err != nil {
+			err = Error("Error writing archive \"%s\": %v", archive, close_err)
+		}
+	}
+	return err
+}
+
+/*****************************************************************************\
+  Unpack a tar archive (gzipped if named .gz or .tgz) into the directory
+  dest, which is created if need be.
+\*****************************************************************************/
+
+func Untar(archive string, dest string) error {
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return Error("Error opening archive \"%s\": %v", archive, err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if isGzipName(archive) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return Error("Error reading archive \"%s\": %v", archive, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	if err = os.MkdirAll(dest, 0755); err != nil {
+		return Error("Error creating directory \"%s\": %v", dest, err)
+	}
+
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return Error("Error reading archive \"%s\": %v", archive, err)
+		}
+		target, err := safeArchivePath(dest, header.Name)
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(header.Mode).Perm()
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(target, mode); err != nil {
+				return Error("Error creating directory \"%s\": %v", target, err)
+			}
+		case tar.TypeReg:
+			if err = writeArchiveFile(target, tr, mode); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err = checkArchiveLink(dest, target, header.Linkname); err != nil {
+				return Error("Illegal symbolic link \"%s\" -> \"%s\" in archive", header.Name, header.Linkname)
+			}
+			if err = os.Symlink(header.Linkname, target); err != nil {
+				return Error("Error creating symbolic link \"%s\": %v", target, err)
+			}
+		default:
+			Warn("Skipping unsupported entry \"%s\" in archive %s", header.Name, archive)
+		}
+	}
+}
+
+/*****************************************************************************\
+  Pack the contents of the directory dir into a zip archive.  Symbolic links
+  and other special files are skipped.
+\*****************************************************************************/
+
+func Zip(archive string, dir string) (err error) {
+
+	file, err := os.Create(archive)
+	if err != nil {
+		return Error("Error creating archive \"%s\": %v", archive, err)
+	}
+	defer func() {
+		if close_err := file.Close(); err == nil && close_err != nil {
+			err = Error("Error closing archive \"%s\": %v", archive, close_err)
+		}
+	}()
+	zw := zip.NewWriter(file)
+
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == "." {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			Warn("Skipping special file \"%s\"", path)
+			return nil
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+		writer, err := zw.CreateHeader(header)
+		if err != nil {
+			return Error("Error writing archive \"%s\": %v", archive, err)
+		}
+		if info.IsDir() {
+			return nil
+		}
+		return copyFileTo(writer, path)
+	})
+	// Closing writes the central directory.
+	if close_err := zw.Close(); err == nil && close_err != nil {
+		err = Error("Error writing archive \"%s\": %v", archive, close_err)
+	}
+	return err
+}
+
+/*****************************************************************************\
+  Unpack a zip archive into the directory dest, which is created if need be.
+\*****************************************************************************/
+
+func Unzip(archive string, dest string) error {
+
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return Error("Error opening archive \"%s\": %v", archive, err)
+	}
+	defer zr.Close()
+	if err = os.MkdirAll(dest, 0755); err != nil {
+		return Error("Error creating directory \"%s\": %v", dest, err)
+	}
+
+	for _, entry := range zr.File {
+		target, err := safeArchivePath(dest, entry.Name)
+		if err != nil {
+			return err
+		}
+		mode := entry.Mode()
+		if mode.IsDir() {
+			if err = os.MkdirAll(target, mode.Perm()); err != nil {
+				return Error("Error creating directory \"%s\": %v", target, err)
+			}
+			continue
+		} else if !mode.IsRegular() {
+			Warn("Skipping unsupported entry \"%s\" in archive %s", entry.Name, archive)
+			continue
+		}
+		reader, err := entry.Open()
+		if err != nil {
+			return Error("Error reading \"%s\" in archive %s: %v", entry.Name, archive, err)
+		}
+		err = writeArchiveFile(target, reader, mode.Perm())
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Check that a symbolic link to be unpacked at target resolves within dest,
+  following any symbolic links on the way, both those of target's parent
+  directories and those of the link itself.
+\*****************************************************************************/
+
+func checkArchiveLink(dest string, target string, link string) error {
+	if filepath.IsAbs(link) {
+		return Error("absolute symbolic link")
+	}
+	real_dest, err := realPath(dest)
+	if err != nil {
+		return err
+	}
+	real_parent, err := realPath(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	real_link, err := resolvePath(real_parent + string(os.PathSeparator) + filepath.FromSlash(link))
+	if err != nil {
+		return err
+	} else if !withinDir(real_dest, real_link) {
+		return Error("symbolic link outside of %s", dest)
+	}
+	return nil
+}
+
+func copyFileTo(writer io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return Error("Error opening file \"%s\": %v", path, err)
+	}
+	defer file.Close()
+	if _, err = io.Copy(writer, file); err != nil {
+		return Error("Error archiving file \"%s\": %v", path, err)
+	}
+	return nil
+}
+
+func writeArchiveFile(target string, reader io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return Error("Error creating directory \"%s\": %v", filepath.Dir(target), err)
+	}
+	// Replace, rather than write through, whatever is at target: O_EXCL
+	// fails on (and does not follow) a symbolic link created since.
+	if info, err := os.Lstat(target); err == nil {
+		if info.IsDir() {
+			return Error("Error creating file \"%s\": is a directory", target)
+		} else if err = os.Remove(target); err != nil {
+			return Error("Error replacing file \"%s\": %v", target, err)
+		}
+	}
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return Error("Error creating file \"%s\": %v", target, err)
+	}
+	if _, err = io.Copy(file, reader); err != nil {
+		file.Close()
+		return Error("Error writing file \"%s\": %v", target, err)
+	}
+	if err = file.Close(); err != nil {
+		return Error("Error closing file \"%s\": %v", target, err)
+	}
+	// Apply the mode explicitly, as OpenFile's is subject to the umask.
+	return os.Chmod(target, mode)
+}
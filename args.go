@@ -0,0 +1,71 @@
+package sitepkg
+
+/*****************************************************************************\
+  Typed positional arguments.  The ArgAs functions return the argument at the
+  specified index (from 0) of the arguments returned by ConfigureOptions,
+  converted, with consistent errors naming the argument and its position:
+
+    argument TTL (#2): bad value "x": not an integer
+\*****************************************************************************/
+
+import (
+	"net"
+	"strconv"
+	"time"
+)
+
+/*****************************************************************************\
+  Return the argument at index, or an error if there is none.
+\*****************************************************************************/
+
+func positionalArg(args []string, index int, name string) (string, error) {
+	if index < 0 || index >= len(args) {
+		return "", Error("missing argument %s (#%d)", name, index+1)
+	}
+	return args[index], nil
+}
+
+func badArg(index int, name string, value string, reason string) error {
+	return Error("argument %s (#%d): bad value \"%s\": %s", name, index+1, value, reason)
+}
+
+func ArgAsInt(args []string, index int, name string) (int, error) {
+	value, err := positionalArg(args, index, name)
+	if err != nil {
+		return 0, err
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, badArg(index, name, value, "not an integer")
+	}
+	return number, nil
+}
+
+func ArgAsIP(args []string, index int, name string) (net.IP, error) {
+	value, err := positionalArg(args, index, name)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, badArg(index, name, value, "not an IP address")
+	}
+	return ip, nil
+}
+
+/*****************************************************************************\
+  Durations are as for ParseDuration: i.e. "90s", "1h30m", or a number of
+  seconds.
+\*****************************************************************************/
+
+func ArgAsDuration(args []string, index int, name string) (time.Duration, error) {
+	value, err := positionalArg(args, index, name)
+	if err != nil {
+		return 0, err
+	}
+	duration, err := ParseDuration(value)
+	if err != nil {
+		return 0, badArg(index, name, value, "not a duration (i.e. 90s, 1h30m)")
+	}
+	return duration, nil
+}
@@ -0,0 +1,126 @@
+package sitepkg
+
+/*****************************************************************************\
+  Circuit breakers for flaky backends.  After BreakerThreshold consecutive
+  failures talking to a backend (an API base URL, or an ssh host), requests
+  to it fail fast, with a "backend unhealthy" error, for BreakerCooldown
+  seconds; then a single trial request is allowed through, which closes the
+  breaker if it succeeds.  Bulk runs thus report the problem promptly rather
+  than timing out thousands of times.  The REST and ssh helpers use these if
+  the breaker options are defined (see SetBreakerOpts).
+\*****************************************************************************/
+
+import (
+	"sync"
+	"time"
+)
+
+const ErrBackendUnhealthy = "backend unhealthy"
+
+type CircuitBreaker struct {
+	Name      string
+	Threshold int
+	Cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	lock      sync.Mutex
+}
+
+var breakers = make(map[string]*CircuitBreaker)
+var breakersLock sync.Mutex
+
+/*****************************************************************************\
+  Define the standard circuit breaker options.
+\*****************************************************************************/
+
+func SetBreakerOpts() {
+	std.definePkgOpts(func() {
+		SetIntOpt("BreakerThreshold", "", true, 5, "Specify the consecutive backend failures after which to fail fast (0 to disable)")
+		SetIntOpt("BreakerCooldown", "", true, 30, "Specify how long, in seconds, to fail fast before retrying a failed backend")
+	})
+}
+
+/*****************************************************************************\
+  Return the circuit breaker for the named backend, creating it per the
+  breaker options if need be.  Return nil if breakers are not enabled.
+\*****************************************************************************/
+
+func Breaker(name string) *CircuitBreaker {
+	breakersLock.Lock()
+	defer breakersLock.Unlock()
+	if breaker, ok := breakers[name]; ok {
+		return breaker
+	}
+	threshold, _ := pkgOpt[int]("BreakerThreshold")
+	if threshold <= 0 {
+		return nil
+	}
+	cooldown, _ := pkgOpt[int]("BreakerCooldown")
+	breaker := &CircuitBreaker{Name: name, Threshold: threshold, Cooldown: time.Duration(cooldown) * time.Second}
+	breakers[name] = breaker
+	return breaker
+}
+
+/*****************************************************************************\
+  Return an error if the breaker is open (the backend is deemed unhealthy).
+  A nil breaker always allows requests.
+\*****************************************************************************/
+
+func (cb *CircuitBreaker) Allow() error {
+	if cb == nil {
+		return nil
+	}
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	if cb.failures < cb.Threshold {
+		return nil
+	} else if now := time.Now(); now.Before(cb.openUntil) {
+		return Error("%s: %s (%d consecutive failures); retrying after %s", cb.Name,
+			ErrBackendUnhealthy, cb.failures, cb.openUntil.Format("15:04:05"))
+	} else {
+		// Let this one trial request through; fail fast until it completes.
+		cb.openUntil = now.Add(cb.Cooldown)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Record the outcome of a request to the backend.
+\*****************************************************************************/
+
+func (cb *CircuitBreaker) Success() {
+	if cb == nil {
+		return
+	}
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.failures = 0
+}
+
+func (cb *CircuitBreaker) Failure() {
+	if cb == nil {
+		return
+	}
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	cb.failures++
+	if cb.failures == cb.Threshold {
+		Warn("%s: %s after %d consecutive failures", cb.Name, ErrBackendUnhealthy, cb.failures)
+	}
+	if cb.failures >= cb.Threshold {
+		cb.openUntil = time.Now().Add(cb.Cooldown)
+	}
+}
+
+/*****************************************************************************\
+  Check if the breaker is open.
+\*****************************************************************************/
+
+func (cb *CircuitBreaker) IsOpen() bool {
+	if cb == nil {
+		return false
+	}
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+	return cb.failures >= cb.Threshold && time.Now().Before(cb.openUntil)
+}
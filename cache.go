@@ -0,0 +1,172 @@
+package sitepkg
+
+/*****************************************************************************\
+  An HTTP response cache for the REST helper.  Cached GET responses are used
+  for CacheTTL seconds, then revalidated with a conditional request (using
+  the ETag or Last-Modified of the response).  If the API cannot be reached,
+  or fails, a stale cached response is used, with a warning, so read-heavy
+  tools survive brief API outages.  Responses are cached as files in the
+  cache dir: CacheDir, or else the user's cache dir for the package.
+
+  Responses are cached per URL and per identity (the client's User and
+  Headers, i.e. RunAs/OnBehalfOf), so one user's view of the API is never
+  served to another.
+\*****************************************************************************/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ResponseCache struct {
+	Dir string
+	TTL time.Duration
+}
+
+type cacheEntry struct {
+	Key          string
+	URL          string
+	ETag         string
+	LastModified string
+	Time         time.Time
+	Body         []byte
+}
+
+/*****************************************************************************\
+  Define the standard cache options.
+\*****************************************************************************/
+
+func SetCacheOpts() {
+	std.definePkgOpts(func() {
+		SetStringOpt("CacheDir", "", true, "", "Specify the directory for cached API responses")
+		SetIntOpt("CacheTTL", "", true, 300, "Specify how long, in seconds, to use cached API responses (0 to disable)")
+	})
+}
+
+/*****************************************************************************\
+  Return the cache dir: the CacheDir option if set, otherwise the package's
+  directory in the user's cache dir (i.e.: ~/.cache/ibapi), tenant specific.
+\*****************************************************************************/
+
+func CacheDir() (string, error) {
+	if dir, _ := pkgOpt[string]("CacheDir"); dir != "" {
+		return TenantDir(dir), nil
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", Error("Failure getting cache dir: %v", err)
+	}
+	return TenantDir(dir + "/" + PkgName), nil
+}
+
+/*****************************************************************************\
+  Create a response cache per the cache options.  Return nil (no caching) if
+  CacheTTL is 0.
+\*****************************************************************************/
+
+func NewResponseCache() (*ResponseCache, error) {
+	ttl, _ := pkgOpt[int]("CacheTTL")
+	if ttl <= 0 {
+		return nil, nil
+	}
+	dir, err := CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseCache{Dir: dir + "/http", TTL: time.Duration(ttl) * time.Second}, nil
+}
+
+// Return the cache key of a request: its URL, and the identity it is sent as.
+func cacheKey(rc *RESTClient, request_url string) string {
+	var names []string
+	for name := range rc.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	key := request_url + "\nUser: " + rc.User
+	for _, name := range names {
+		key += "\n" + name + ": " + strings.Join(rc.Headers[name], ", ")
+	}
+	return key
+}
+
+func (cache *ResponseCache) filename(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return cache.Dir + "/" + hex.EncodeToString(sum[:])
+}
+
+func (cache *ResponseCache) load(key string) *cacheEntry {
+	var entry cacheEntry
+	data, err := os.ReadFile(cache.filename(key))
+	if err != nil {
+		return nil
+	} else if err = json.Unmarshal(data, &entry); err != nil || entry.Key != key {
+		return nil
+	}
+	return &entry
+}
+
+func (cache *ResponseCache) store(entry *cacheEntry) {
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = writeFileAtomic(cache.filename(entry.Key), data, 0600)
+	}
+	if err != nil {
+		ShowDebug("Failure caching response for %s: %v", entry.URL, err)
+	}
+}
+
+/*****************************************************************************\
+  GET the URL via the cache.
+\*****************************************************************************/
+
+func (cache *ResponseCache) get(rc *RESTClient, request_url string) ([]byte, error) {
+
+	key := cacheKey(rc, request_url)
+	entry := cache.load(key)
+	if entry != nil && time.Since(entry.Time) < cache.TTL {
+		ShowDebug("REST: cached: %s", request_url)
+		return entry.Body, nil
+	}
+
+	headers := make(http.Header)
+	if entry != nil && entry.ETag != "" {
+		headers.Set("If-None-Match", entry.ETag)
+	}
+	if entry != nil && entry.LastModified != "" {
+		headers.Set("If-Modified-Since", entry.LastModified)
+	}
+	status, response_headers, body, err := rc.send(http.MethodGet, request_url, nil, headers)
+
+	if entry != nil && (err != nil || status >= 500) {
+		if err == nil {
+			err = checkStatus(http.MethodGet, request_url, status, body)
+		}
+		Warn("%v; using cached response from %s", err, entry.Time.Format(time.RFC3339))
+		return entry.Body, nil
+	} else if err != nil {
+		return nil, err
+	} else if status == http.StatusNotModified && entry != nil {
+		entry.Time = time.Now()
+		cache.store(entry)
+		return entry.Body, nil
+	} else if err = checkStatus(http.MethodGet, request_url, status, body); err != nil {
+		return body, err
+	}
+
+	cache.store(&cacheEntry{
+		Key:          key,
+		URL:          request_url,
+		ETag:         response_headers.Get("ETag"),
+		LastModified: response_headers.Get("Last-Modified"),
+		Time:         time.Now(),
+		Body:         body,
+	})
+	return body, nil
+}
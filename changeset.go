@@ -0,0 +1,193 @@
+package sitepkg
+
+/*****************************************************************************\
+  A ChangeSet records the changes a program makes (or, in a dry run, would
+  make), so that they are reported the same way by every tool: listed for
+  dry runs and confirmations, written to audit records, and summarized at
+  the end of the run.
+\*****************************************************************************/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded     ChangeType = "added"
+	ChangeModified  ChangeType = "modified"
+	ChangeDeleted   ChangeType = "deleted"
+	ChangeUnchanged ChangeType = "unchanged"
+	ChangeFailed    ChangeType = "failed"
+)
+
+type Change struct {
+	Type   ChangeType
+	Object string
+	Before interface{} `json:",omitempty"`
+	After  interface{} `json:",omitempty"`
+	Error  string      `json:",omitempty"`
+}
+
+type ChangeSet struct {
+	Changes []Change
+	lock    sync.Mutex
+}
+
+/*****************************************************************************\
+  Create an empty ChangeSet.
+\*****************************************************************************/
+
+func NewChangeSet() *ChangeSet {
+	return &ChangeSet{}
+}
+
+/*****************************************************************************\
+  Record a change.  These are safe to call from multiple goroutines.
+\*****************************************************************************/
+
+func (cs *ChangeSet) Record(change Change) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.Changes = append(cs.Changes, change)
+}
+
+func (cs *ChangeSet) Added(object string, after interface{}) {
+	cs.Record(Change{Type: ChangeAdded, Object: object, After: after})
+}
+
+func (cs *ChangeSet) Modified(object string, before interface{}, after interface{}) {
+	cs.Record(Change{Type: ChangeModified, Object: object, Before: before, After: after})
+}
+
+func (cs *ChangeSet) Deleted(object string, before interface{}) {
+	cs.Record(Change{Type: ChangeDeleted, Object: object, Before: before})
+}
+
+/*****************************************************************************\
+  Return the number of changes of the specified type, or of all changes if
+  change_type is "".
+\*****************************************************************************/
+
+func (cs *ChangeSet) Count(change_type ChangeType) (count int) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	for _, change := range cs.Changes {
+		if change_type == "" || change.Type == change_type {
+			count++
+		}
+	}
+	return count
+}
+
+/*****************************************************************************\
+  Return a one line summary of the changes: "2 added, 1 modified, 0 deleted",
+  followed by the number unchanged and failed, if any.
+\*****************************************************************************/
+
+func (cs *ChangeSet) Summary() string {
+	summary := fmt.Sprintf("%d added, %d modified, %d deleted",
+		cs.Count(ChangeAdded), cs.Count(ChangeModified), cs.Count(ChangeDeleted))
+	if unchanged := cs.Count(ChangeUnchanged); unchanged > 0 {
+		summary += fmt.Sprintf(", %d unchanged", unchanged)
+	}
+	if failed := cs.Count(ChangeFailed); failed > 0 {
+		summary += fmt.Sprintf(", %d failed", failed)
+	}
+	return summary
+}
+
+/*****************************************************************************\
+  Write the list of changes to w, one per line:
+    + object: after
+    ~ object: before -> after
+    - object: before
+    ! object: error
+  Unchanged objects are listed ("= object") only in Verbose mode.
+  If dry_run is set, the list is introduced as changes that would be made;
+  if there are none (other than unchanged objects), as "No changes."
+\*****************************************************************************/
+
+func (cs *ChangeSet) Write(w io.Writer, dry_run bool) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	var changes int
+	for _, change := range cs.Changes {
+		if change.Type != ChangeUnchanged {
+			changes++
+		}
+	}
+	if changes == 0 {
+		Fprintln(w, "No changes.")
+	} else if dry_run {
+		Fprintln(w, "Changes that would be made (dry run):")
+	} else {
+		Fprintln(w, "Changes:")
+	}
+	for _, change := range cs.Changes {
+		switch change.Type {
+		case ChangeAdded:
+			Fprintln(w, "  + %s: %v", change.Object, change.After)
+		case ChangeModified:
+			Fprintln(w, "  ~ %s: %v -> %v", change.Object, change.Before, change.After)
+		case ChangeDeleted:
+			Fprintln(w, "  - %s: %v", change.Object, change.Before)
+		case ChangeFailed:
+			Fprintln(w, "  ! %s: %s", change.Object, change.Error)
+		case ChangeUnchanged:
+			if Verbose {
+				Fprintln(w, "  = %s", change.Object)
+			}
+		default:
+			Fprintln(w, "  %s %s", change.Type, change.Object)
+		}
+	}
+}
+
+/*****************************************************************************\
+  Show the list of changes (see Write), unless in Quiet mode.
+\*****************************************************************************/
+
+func (cs *ChangeSet) Show(dry_run bool) {
+	if !Quiet {
+		cs.Write(DefaultShow, dry_run)
+	}
+}
+
+/*****************************************************************************\
+  Show the summary of changes, unless in Quieter mode.
+\*****************************************************************************/
+
+func (cs *ChangeSet) ShowSummary() {
+	if !Quieter {
+		Show("%s", cs.Summary())
+	}
+}
+
+/*****************************************************************************\
+  Return an audit record (JSON) of the changes, including who made them,
+  when, and for which tenant.
+\*****************************************************************************/
+
+func (cs *ChangeSet) AuditRecord() ([]byte, error) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	record := struct {
+		Time     string
+		Program  string
+		Identity string
+		Tenant   string `json:",omitempty"`
+		Changes  []Change
+	}{
+		Time:     time.Now().Format(time.RFC3339),
+		Program:  ProgramName,
+		Identity: Identity(),
+		Tenant:   Tenant,
+		Changes:  cs.Changes,
+	}
+	return json.Marshal(record)
+}
@@ -0,0 +1,104 @@
+package sitepkg
+
+/*****************************************************************************\
+  Config file validation, for --CheckConfig: every config file for the
+  program is checked, in all its sections (not just those that apply to the
+  invoked command), and all problems are reported with file:line, rather than
+  stopping at the first.  For use in CI and config management pipelines.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+/*****************************************************************************\
+  Check each of the config files, writing any problems to w, one per line:
+    /etc/opt/ibapi/ibapi.conf:12: error: Unknown option "usrname"
+  Return the numbers of errors and warnings found.
+\*****************************************************************************/
+
+func (c *Configurator) CheckConfig(w io.Writer) (errors int, warnings int, err error) {
+
+	c.setConfigDirs()
+	config_files, err := c.configFiles()
+	if err != nil {
+		return 0, 0, err
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	for _, config_file := range config_files {
+		report := func(line int, severity string, format string, a ...interface{}) {
+			Fprintln(w, "%s:%d: %s: %s", config_file, line, severity, fmt.Sprintf(format, a...))
+			if severity == "error" {
+				errors++
+			} else {
+				warnings++
+			}
+		}
+		seen := make(map[string]int)
+		err = c.scanConfigFile(config_file, true, func(entry configEntry) error {
+			if entry.Err != nil {
+				report(entry.Line, "error", "%v", entry.Err)
+				return nil
+			}
+			name := entry.Name
+			if renamed, ok := c.optionRenames[name]; ok {
+				report(entry.Line, "warning", "Option \"%s\" was renamed \"%s\" in version %s", name, renamed.newName, renamed.version)
+				name = c.optionKey(renamed.newName)
+			}
+			option, ok := c.Config[name]
+			if !ok {
+				report(entry.Line, "error", "Unknown option \"%s\"", name)
+				return nil
+			} else if option.Sources&SourceFile == 0 {
+				report(entry.Line, "error", "Illegal option \"%s\": %s", name, sourceViolation(option, SourceFile))
+				return nil
+			}
+			if entry.Append && option.Type != "list" {
+				report(entry.Line, "error", "Illegal \"+=\" for %s option \"%s\"", option.Type, name)
+			} else if err := checkOptionValue(option, name, entry.Value); err != nil {
+				report(entry.Line, "error", "%v", err)
+			}
+			key := entry.Section + "\x00" + name
+			if line, ok := seen[key]; ok {
+				report(entry.Line, "warning", "Option \"%s\" already set at line %d", name, line)
+			}
+			seen[key] = entry.Line
+			return nil
+		})
+		if err != nil {
+			report(0, "error", "%v", err)
+		}
+	}
+	if !Quiet {
+		Fprintln(w, "Checked %d config file(s): %d error(s), %d warning(s).", len(config_files), errors, warnings)
+	}
+	return errors, warnings, nil
+}
+
+/*****************************************************************************\
+  Check that a value read from a config file is valid for the option,
+  without setting the option.
+\*****************************************************************************/
+
+func checkOptionValue(option *Option, name string, value string) error {
+	return setOptionValue(scratchOption(option.Type), name, value)
+}
+
+/*****************************************************************************\
+  Check the config files (see Configurator.CheckConfig), showing any problems,
+  and exit: non-zero if any errors were found.
+\*****************************************************************************/
+
+func checkConfigMode() {
+	errors, _, err := std.CheckConfig(os.Stdout)
+	if err != nil {
+		Exit(1, err)
+	} else if errors > 0 {
+		Exit(1)
+	}
+	Exit(0)
+}
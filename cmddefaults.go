@@ -0,0 +1,102 @@
+package sitepkg
+
+/*****************************************************************************\
+  Per command defaults.  SetOptDefaultForCommand registers a default for an
+  option that applies only when the specified command path is invoked (see
+  GetCommandPaths), mirroring what config file sections allow.  Such defaults
+  are applied before the config files are read, the more specific command
+  paths last, and are shown with the Source "default(host:add)".  As they are
+  also the options' defaults for the run, help, ShowConfig and GenConfig show
+  the defaults for the invoked command.
+\*****************************************************************************/
+
+import (
+	"strings"
+)
+
+type commandDefault struct {
+	name  string
+	value interface{}
+}
+
+/*****************************************************************************\
+  Register a default for an option when the specified command path (i.e.
+  "host:add", or "host add") is invoked.  The value must be of the option's
+  type, or a string, which is parsed as if read from a config file.
+\*****************************************************************************/
+
+func (c *Configurator) SetOptDefaultForCommand(command string, name string, value interface{}) error {
+	c.writeLock()
+	defer c.lock.Unlock()
+	lc := c.optionKey(name)
+	default_value, err := c.defaultValue(lc, name, value, "SetOptDefaultForCommand")
+	if err != nil {
+		return err
+	}
+	if c.commandDefaults == nil {
+		c.commandDefaults = make(map[string][]commandDefault)
+	}
+	command = c.commandPath(command)
+	c.commandDefaults[command] = append(c.commandDefaults[command], commandDefault{name: lc, value: default_value})
+	return nil
+}
+
+/*****************************************************************************\
+  Return a default value for an option: the value if of the option's type, or
+  a string parsed as if read from a config file.
+\*****************************************************************************/
+
+func (c *Configurator) defaultValue(lc string, name string, value interface{}, caller string) (interface{}, error) {
+	option, ok := c.Config[lc]
+	if !ok {
+		return nil, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	scratch := scratchOption(option.Type)
+	if string_value, is_string := value.(string); is_string && option.Type != "string" {
+		if err := setOptionValue(scratch, lc, string_value); err != nil {
+			return nil, err
+		}
+	} else if !setTypedValue(scratch, value) {
+		return nil, Error("%s: bad %T value for %s option \"%s\".", caller, value, option.Type, name)
+	}
+	return optionValue(scratch), nil
+}
+
+/*****************************************************************************\
+  Return a command path in the form returned by GetCommandPaths: i.e. for
+  "host add" or "ibapi host add", "host:add".
+\*****************************************************************************/
+
+func (c *Configurator) commandPath(command string) string {
+	words := strings.FieldsFunc(command, func(r rune) bool { return r == ' ' || r == ':' })
+	if len(words) > 1 && words[0] == c.ProgramName {
+		words = words[1:]
+	}
+	return strings.Join(words, ":")
+}
+
+/*****************************************************************************\
+  Apply the defaults registered for the invoked command paths.
+\*****************************************************************************/
+
+func (c *Configurator) applyCommandDefaults() {
+	c.writeLock()
+	defer c.lock.Unlock()
+	for _, command := range c.GetCommandPaths() {
+		for _, entry := range c.commandDefaults[command] {
+			option := c.Config[entry.name]
+			setTypedValue(option, entry.value)
+			option.Default = entry.value
+			option.Source = "default(" + command + ")"
+			recordAssignment(option, 0)
+		}
+	}
+}
+
+/*****************************************************************************\
+  Check if an option Source is a default, general or per command.
+\*****************************************************************************/
+
+func isDefaultSource(source string) bool {
+	return source == "Default" || strings.HasPrefix(source, "default(")
+}
@@ -0,0 +1,132 @@
+package sitepkg
+
+/*****************************************************************************\
+  A bridge for programs using cobra (github.com/spf13/cobra), whose commands
+  parse their flags with pflag, so that such programs keep the sitepkg
+  config files, per command sections and POD, environment, secrets and
+  standard options.  The options are exported as flags of the cobra command
+  tree, and each command configures them before running:
+
+    sitepkg.PackageInit("ibapi", version)
+    root := &cobra.Command{Use: "ibapi",
+        PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+            return sitepkg.ConfigureCommand(cmd.CommandPath(), cmd.Flags())
+        }}
+    sitepkg.ExportFlags(root.PersistentFlags())
+
+  The command path, less the program name (i.e. "host add" of "ibapi host
+  add"), selects the config file sections and POD page, as SetCommandPath
+  does.  Cobra provides its own help and shell completion, so the Help and
+  Completion options are not exported; the POD may be used for help with
+  cmd.SetHelpFunc and Usage.
+\*****************************************************************************/
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+/*****************************************************************************\
+  Define flags for the options in the flag set (i.e. a cobra command's
+  PersistentFlags), bound to the option values.  Options whose names or
+  short options are already defined in the flag set are skipped.
+\*****************************************************************************/
+
+func (c *Configurator) ExportFlags(flag_set *pflag.FlagSet) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	for name, option := range c.Config {
+		if name == c.optionKey("Help") || name == c.optionKey("Completion") || flag_set.Lookup(name) != nil {
+			continue
+		}
+		if option.ShortOpt != "" && flag_set.ShorthandLookup(option.ShortOpt) != nil {
+			exported := *option
+			exported.ShortOpt = ""
+			defineFlag(flag_set, name, &exported)
+			continue
+		}
+		defineFlag(flag_set, name, option)
+	}
+	if !c.CaseSensitive {
+		flag_set.SetNormalizeFunc(flagCaseInsensitive)
+	}
+}
+
+/*****************************************************************************\
+  Configure the options, as ConfigureOptions does, for a command line already
+  parsed into the flag set (i.e. a cobra command's Flags), which are applied
+  as the command line layer.
+\*****************************************************************************/
+
+func (c *Configurator) ConfigureFlags(flag_set *pflag.FlagSet) error {
+
+	// The flags were parsed into the options; save the values given, and
+	// restore the defaults, so that the layers apply in order.
+	given := make(map[string]interface{})
+	c.writeLock()
+	flag_set.Visit(func(flag *pflag.Flag) {
+		if option, ok := c.Config[flag.Name]; ok {
+			given[flag.Name] = optionValue(option)
+			setTypedValue(option, option.Default)
+		}
+	})
+	c.lock.Unlock()
+
+	if root, ok := given[c.optionKey("Root")].(string); ok && root != c.Root {
+		c.SetRoot(root)
+	}
+	c.setConfigDirs()
+	if tenant, ok := given[c.optionKey("Tenant")].(string); ok {
+		if err := checkTenant(tenant); err != nil {
+			return err
+		}
+		c.Tenant = tenant
+	}
+	if trace, ok := given[c.optionKey("TraceConfig")].(bool); ok {
+		c.Trace = trace
+	}
+
+	return c.readLayers(func() error {
+		c.writeLock()
+		defer c.lock.Unlock()
+		for name, value := range given {
+			option := c.Config[name]
+			if option.Sources&SourceCommandLine == 0 {
+				return Error("Illegal option \"--%s\": %s", name, sourceViolation(option, SourceCommandLine))
+			} else if option.Final {
+				return Error("Option \"--%s\" may not be overridden; it is final, set by %s", name, option.Source)
+			}
+			setTypedValue(option, value)
+			option.Source = "CommandLine"
+			recordAssignment(option, 0)
+		}
+		return nil
+	})
+}
+
+/*****************************************************************************\
+  Configure the options for the specified command path (i.e. cobra's
+  cmd.CommandPath(), "ibapi host add") and its parsed flags, then handle
+  the standard options, as ConfigureOptions does.
+\*****************************************************************************/
+
+func ConfigureCommand(command_path string, flag_set *pflag.FlagSet) error {
+	words := strings.Fields(command_path)
+	if len(words) > 0 {
+		words = words[1:]
+	}
+	syncGlobals()
+	SetCommandPath(strings.Join(words, " "))
+	std.setConfigDirs()
+	syncGlobals()
+	configFileModes(func(name string) bool {
+		flag := flag_set.Lookup(std.optionKey(name))
+		return flag != nil && flag.Changed && flag.Value.String() == "true"
+	})
+	return handleStandardOptions(std.ConfigureFlags(flag_set))
+}
+
+func ExportFlags(flag_set *pflag.FlagSet) {
+	std.ExportFlags(flag_set)
+}
@@ -0,0 +1,110 @@
+package sitepkg
+
+/*****************************************************************************\
+  Color output.  Warnings are shown in yellow, errors in red and debug (and
+  trace) messages dimmed, per the Color option:
+
+    auto     color output to terminals, unless NO_COLOR is set (to anything
+             but "") or TERM is "dumb"; the default
+    always   color all output, i.e. when piped to "less -R"
+    never    never color output
+
+  Callers may color their own output the same way with Colorize, which
+  colors text only if color is enabled for the writer:
+
+    sitepkg.Println("%s", sitepkg.Colorize(sitepkg.DefaultPrint, sitepkg.ColorSuccess, "OK"))
+\*****************************************************************************/
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+type Color string
+
+const (
+	ColorRed    Color = "31"
+	ColorGreen  Color = "32"
+	ColorYellow Color = "33"
+	ColorBlue   Color = "34"
+	ColorBold   Color = "1"
+	ColorDim    Color = "2"
+
+	ColorError   = ColorRed
+	ColorWarn    = ColorYellow
+	ColorDebug   = ColorDim
+	ColorSuccess = ColorGreen
+)
+
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+var colorMode = ColorAuto
+
+/*****************************************************************************\
+  Set the color mode: auto, always or never.  ConfigureOptions sets it from
+  the Color option.
+\*****************************************************************************/
+
+func SetColorMode(mode string) error {
+	switch lc := strings.ToLower(mode); lc {
+	case ColorAuto, ColorAlways, ColorNever:
+		colorMode = lc
+	case "":
+		colorMode = ColorAuto
+	default:
+		return Error("Bad color mode \"%s\": expected auto, always or never", mode)
+	}
+	return nil
+}
+
+func setColorFromOptions() error {
+	mode, _ := pkgOpt[string]("Color")
+	if err := SetColorMode(mode); err != nil {
+		return WrapError(UsageError, err)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Check if output to the specified writer is colored.
+\*****************************************************************************/
+
+func ColorEnabled(w io.Writer) bool {
+	switch colorMode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// Check if w is a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+/*****************************************************************************\
+  Return the text in the specified color, if color is enabled for the
+  writer to which it is to be written; otherwise the text as is.
+\*****************************************************************************/
+
+func Colorize(w io.Writer, color Color, text string) string {
+	if text == "" || !ColorEnabled(w) {
+		return text
+	}
+	return "\x1b[" + string(color) + "m" + text + "\x1b[0m"
+}
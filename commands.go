@@ -0,0 +1,460 @@
+package sitepkg
+
+/*****************************************************************************\
+  Subcommands.  AddCommand registers a command path (i.e. "host add") with
+  the function that runs it.  ConfigureOptions finds the invoked command in
+  the command line arguments, registers its options (see Command.Options),
+  and scopes the config file sections, POD lookup and per command defaults
+  to it, as GetCommandPaths then returns its paths.  RunCommand then runs it:
+
+    sitepkg.AddCommand("host add", hostAdd, "Add a host").Options(func() {
+        sitepkg.SetIntOpt("TTL", "", true, 0, "Specify the TTL")
+    })
+    args, err := sitepkg.ConfigureOptions()
+    ...
+    err = sitepkg.RunCommand(args)
+
+  Command words must precede any arguments, and options of the command must
+  follow it, as they are not known until the command is found.  Programs
+  which find their subcommands themselves may instead call SetCommandPath.
+  Aliases (see AddCommandAlias) are resolved to the commands they alias.
+\*****************************************************************************/
+
+import (
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+type Command struct {
+	Path          string
+	Desc          string
+	Run           func(args []string) error
+	options       []func()
+	noIntersperse bool
+}
+
+/*****************************************************************************\
+  Register a command, by path (i.e. "host add", or "host:add"), with the
+  function that runs it and a description.  Parent commands (i.e. "host")
+  are registered as needed, without a Run function.  Return the command, for
+  registering its options.
+\*****************************************************************************/
+
+func (c *Configurator) AddCommand(path string, run func(args []string) error, desc string) *Command {
+	c.writeLock()
+	defer c.lock.Unlock()
+	path = c.commandPath(path)
+	if path == "" {
+		panic(Error("programming error: empty command path"))
+	}
+	if c.commands == nil {
+		c.commands = make(map[string]*Command)
+	}
+	words := strings.Split(path, ":")
+	for i := range words {
+		parent := strings.Join(words[:i+1], ":")
+		if _, ok := c.commands[parent]; !ok {
+			c.commands[parent] = &Command{Path: parent}
+		}
+	}
+	command := c.commands[path]
+	if command.Run != nil {
+		panic(Error("programming error: command \"%s\" redefined", path))
+	}
+	command.Run = run
+	command.Desc = desc
+	return command
+}
+
+/*****************************************************************************\
+  Register an alias for a command (i.e. "ls" for "list", or "host ls" for
+  "host list"), so that invoking the alias invokes the command, and the
+  config file sections, POD lookup and per command defaults are those of the
+  command.  Aliases of parent commands apply to their subcommands: with "h"
+  an alias of "host", "h ls" invokes "host list".
+\*****************************************************************************/
+
+func (c *Configurator) AddCommandAlias(alias string, path string) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	alias, path = c.commandPath(alias), c.commandPath(path)
+	if alias == "" || path == "" {
+		panic(Error("programming error: empty command alias or path"))
+	} else if _, ok := c.commands[alias]; ok {
+		panic(Error("programming error: alias \"%s\" is a command", alias))
+	}
+	if c.aliases == nil {
+		c.aliases = make(map[string]string)
+	}
+	c.aliases[alias] = path
+}
+
+/*****************************************************************************\
+  Resolve any aliases in a command path, word by word, returning the path of
+  the command aliased.
+\*****************************************************************************/
+
+func (c *Configurator) resolveAlias(path string) string {
+	var resolved string
+	for _, word := range strings.Split(path, ":") {
+		next := word
+		if resolved != "" {
+			next = resolved + ":" + word
+		}
+		if canonical, ok := c.aliases[next]; ok {
+			next = canonical
+		}
+		resolved = next
+	}
+	return resolved
+}
+
+/*****************************************************************************\
+  Register a function defining the command's options, called by
+  ConfigureOptions only if the command, or one of its subcommands, is
+  invoked.
+\*****************************************************************************/
+
+func (command *Command) Options(fn func()) *Command {
+	command.options = append(command.options, fn)
+	return command
+}
+
+/*****************************************************************************\
+  Return the commands directly under the specified command path ("" for the
+  top level commands), sorted by path.
+\*****************************************************************************/
+
+func (c *Configurator) Subcommands(path string) []*Command {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	var subcommands []*Command
+	prefix := ""
+	if path = c.commandPath(path); path != "" {
+		prefix = path + ":"
+	}
+	for command_path, command := range c.commands {
+		if rest := strings.TrimPrefix(command_path, prefix); strings.HasPrefix(command_path, prefix) &&
+			rest != "" && !strings.Contains(rest, ":") {
+			subcommands = append(subcommands, command)
+		}
+	}
+	sort.Slice(subcommands, func(i, j int) bool { return subcommands[i].Path < subcommands[j].Path })
+	return subcommands
+}
+
+/*****************************************************************************\
+  Set the invoked command path (i.e. "host add", or "host:add"), for programs
+  which find their subcommands themselves, rather than registering them with
+  AddCommand or rewriting os.Args[0].  Call it before ConfigureOptions, so
+  that the config file sections and per command defaults for the path apply.
+\*****************************************************************************/
+
+func (c *Configurator) SetCommandPath(path string) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	c.invoked, c.pathSet = c.resolveAlias(c.commandPath(path)), true
+}
+
+/*****************************************************************************\
+  Return the invoked command, or nil if none.
+\*****************************************************************************/
+
+func (c *Configurator) InvokedCommand() *Command {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.commands[c.invoked]
+}
+
+/*****************************************************************************\
+  Find the invoked command in the command line arguments: the longest
+  sequence of command words before any other argument, skipping options and
+  the values of the options known to take them.  Register the options of the
+  command and its parents, noting them as command options, and return the
+  arguments without the command words.
+\*****************************************************************************/
+
+func (c *Configurator) findCommand(args []string) []string {
+
+	var path, plugin string
+	var rest []string
+
+	c.lock.RLock()
+	if len(c.commands) == 0 {
+		c.lock.RUnlock()
+		return args
+	}
+	found := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if found || arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		} else if strings.HasPrefix(arg, "-") && arg != "-" {
+			rest = append(rest, arg)
+			if c.takesValue(arg) && i+1 < len(args) {
+				i++
+				rest = append(rest, args[i])
+			}
+			continue
+		}
+		next := arg
+		if path != "" {
+			next = path + ":" + arg
+		}
+		if canonical, ok := c.aliases[next]; ok {
+			next = canonical
+		}
+		if _, ok := c.commands[next]; ok {
+			path = next
+		} else {
+			found = true
+			plugin = c.pluginFor(path, arg)
+			rest = append(rest, arg)
+		}
+	}
+	var options []func()
+	if path != "" {
+		words := strings.Split(path, ":")
+		for i := range words {
+			options = append(options, c.commands[strings.Join(words[:i+1], ":")].options...)
+		}
+	}
+	c.lock.RUnlock()
+
+	c.lock.Lock()
+	c.invoked, c.pathSet, c.plugin = path, true, plugin
+	global := make(map[string]bool)
+	for lc := range c.Config {
+		global[lc] = true
+	}
+	c.lock.Unlock()
+	// The option functions define options, so call them without the lock.
+	for _, fn := range options {
+		fn()
+	}
+	c.lock.Lock()
+	for lc := range c.Config {
+		if !global[lc] {
+			if c.commandOptions == nil {
+				c.commandOptions = make(map[string]bool)
+			}
+			c.commandOptions[lc] = true
+		}
+	}
+	c.lock.Unlock()
+	return rest
+}
+
+/*****************************************************************************\
+  Check if a command line option takes a separate value: i.e. "--Tenant",
+  but not "--Tenant=x" or a boolean option.
+\*****************************************************************************/
+
+func (c *Configurator) takesValue(arg string) bool {
+	if strings.Contains(arg, "=") {
+		return false
+	}
+	if strings.HasPrefix(arg, "--") {
+		option, ok := c.Config[c.optionKey(arg[2:])]
+		return ok && option.Type != "bool"
+	}
+	// A short option, or group of them; the last may take a value.
+	if len(arg) < 2 {
+		return false
+	}
+	shortopt := arg[len(arg)-1:]
+	for _, option := range c.Config {
+		if option.ShortOpt == shortopt {
+			return option.Type != "bool" && len(arg) == 2
+		}
+	}
+	return false
+}
+
+/*****************************************************************************\
+  Run the invoked command with the specified (remaining) arguments.  Return
+  an error, listing the commands available, if no runnable command was
+  invoked.
+\*****************************************************************************/
+
+func (c *Configurator) RunCommand(args []string) error {
+	if c.plugin != "" && len(args) > 0 {
+		return c.runPlugin(args[1:])
+	}
+	command := c.InvokedCommand()
+	if command != nil && command.Run != nil {
+		return command.Run(args)
+	}
+	var names []string
+	path := ""
+	if command != nil {
+		path = command.Path
+	}
+	for _, subcommand := range c.Subcommands(path) {
+		names = append(names, strings.ReplaceAll(subcommand.Path, ":", " "))
+	}
+	words := strings.ReplaceAll(path, ":", " ")
+	if len(names) == 0 {
+		return Error("No commands defined.")
+	} else if len(args) > 0 {
+		return Error("Unknown command \"%s\"; commands: %s",
+			strings.TrimLeft(words+" "+args[0], " "), strings.Join(names, ", "))
+	} else if command == nil {
+		return Error("No command specified; commands: %s", strings.Join(names, ", "))
+	}
+	return Error("Incomplete command \"%s\"; commands: %s", words, strings.Join(names, ", "))
+}
+
+/*****************************************************************************\
+  Show the commands under the invoked command, if any, with their
+  descriptions, and any plugins.
+\*****************************************************************************/
+
+func (c *Configurator) showCommands() {
+	path := ""
+	if command := c.InvokedCommand(); command != nil {
+		path = command.Path
+	}
+	subcommands := c.Subcommands(path)
+	if len(subcommands) == 0 {
+		return
+	}
+	Show("Commands:")
+	for _, subcommand := range subcommands {
+		Show("  %-20s  %s", strings.ReplaceAll(subcommand.Path, ":", " "), subcommand.Desc)
+	}
+	plugins := c.Plugins(path)
+	words := make([]string, 0, len(plugins))
+	for word := range plugins {
+		if _, ok := c.commands[strings.TrimPrefix(path+":"+word, ":")]; !ok {
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	for _, word := range words {
+		Show("  %-20s  (plugin: %s)", strings.TrimSpace(strings.ReplaceAll(path, ":", " ")+" "+word), plugins[word])
+	}
+	Show("")
+}
+
+/*****************************************************************************\
+  Return the invoked command line, without arguments: i.e. "ibapi host add".
+\*****************************************************************************/
+
+func (c *Configurator) commandLine() string {
+	return strings.TrimSpace(c.ProgramName + " " + strings.ReplaceAll(c.invoked, ":", " "))
+}
+
+/*****************************************************************************\
+  Write the usage of the command line options to w: those of the invoked
+  command, if any, separately from the global options.
+\*****************************************************************************/
+
+func (c *Configurator) writeFlagUsages(w io.Writer) {
+	if len(c.commandOptions) == 0 {
+		Fprint(w, "%s", c.FlagSet.FlagUsages())
+		return
+	}
+	command_flags, global_flags := c.splitFlags()
+	Fprintln(w, "Command options:")
+	Fprintln(w, "%s", command_flags.FlagUsages())
+	Fprintln(w, "Global options:")
+	Fprint(w, "%s", global_flags.FlagUsages())
+}
+
+// Split the command line flags into those of the invoked command and the rest.
+func (c *Configurator) splitFlags() (command_flags *pflag.FlagSet, global_flags *pflag.FlagSet) {
+	command_flags = pflag.NewFlagSet(c.invoked, pflag.ContinueOnError)
+	global_flags = pflag.NewFlagSet(c.ProgramName, pflag.ContinueOnError)
+	c.FlagSet.VisitAll(func(flag *pflag.Flag) {
+		if c.commandOptions[flag.Name] {
+			command_flags.AddFlag(flag)
+		} else {
+			global_flags.AddFlag(flag)
+		}
+	})
+	return command_flags, global_flags
+}
+
+/*****************************************************************************\
+  Scope a POD document for the whole program to the invoked command: return
+  the section whose =head heading names the command (i.e. "host add", or
+  "B<host add> I<host> I<address>"), as a document of its own, followed by
+  the command's options.  Return ok false if there is no invoked command, or
+  no such section.
+\*****************************************************************************/
+
+func (c *Configurator) commandPodSection(pod string) (section string, ok bool) {
+
+	var lines []string
+	level := ""
+
+	words := strings.ReplaceAll(c.invoked, ":", " ")
+	if words == "" {
+		return "", false
+	}
+	for _, line := range strings.Split(pod, "\n") {
+		heading_level, heading := podHeading(line)
+		if level != "" && heading_level != "" && heading_level <= level {
+			break
+		} else if level == "" && heading_level != "" &&
+			(heading == words || strings.HasPrefix(heading, words+" ")) {
+			level = heading_level
+			line = "=head1 " + strings.TrimSpace(strings.SplitN(line, " ", 2)[1])
+		} else if level == "" || strings.HasPrefix(line, "=cut") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if level == "" {
+		return "", false
+	}
+	if command_flags, _ := c.splitFlags(); command_flags.HasFlags() {
+		lines = append(lines, "", "=head1 OPTIONS", "")
+		for _, usage := range strings.Split(strings.TrimRight(command_flags.FlagUsages(), "\n"), "\n") {
+			lines = append(lines, "  "+usage)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n\n=cut\n", true
+}
+
+/*****************************************************************************\
+  Return the level ("1", "2", ...) of a POD =head line, and its heading as
+  command words: lower case, without formatting codes, and with ":" as " ".
+  Return "" for other lines.
+\*****************************************************************************/
+
+func podHeading(line string) (level string, heading string) {
+	if !strings.HasPrefix(line, "=head") || len(line) < 6 {
+		return "", ""
+	}
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) < 2 {
+		return line[5:6], ""
+	}
+	heading = podFormatting.ReplaceAllString(fields[1], "$1")
+	heading = strings.ReplaceAll(strings.ToLower(heading), ":", " ")
+	return line[5:6], strings.Join(strings.Fields(heading), " ")
+}
+
+var podFormatting = regexp.MustCompile(`[A-Z]<([^<>]*)>`)
+
+/*****************************************************************************\
+  Have command line errors followed by the usage of the invoked command.
+\*****************************************************************************/
+
+func (c *Configurator) setCommandUsage() {
+	usage := func() {
+		Fprintln(DefaultErr, "Usage of %s:", c.commandLine())
+		c.writeFlagUsages(DefaultErr)
+	}
+	c.FlagSet.Usage = usage
+	if c.FlagSet == pflag.CommandLine {
+		pflag.Usage = usage
+	}
+}
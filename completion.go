@@ -0,0 +1,350 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for shell completion.  The generated completion script completes
+  option names itself, and calls back into the program ("prog __complete
+  option prefix") to complete option values, so that the values offered are
+  the real site values found in the package files at the time of completion.
+  Values may also come from a callback (see SetOptCompletionFunc), i.e. to
+  complete --View from the views of the tenant's grid.
+\*****************************************************************************/
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const CompleteCommand = "__complete"
+
+/*****************************************************************************\
+  A completion callback: return the values of an option, or those beginning
+  with prefix (the values returned are filtered by prefix in any case).
+\*****************************************************************************/
+
+type CompletionFunc func(prefix string) ([]string, error)
+
+type optionCompletion struct {
+	Values   []string
+	ListFile string
+	Func     CompletionFunc
+}
+
+var completions = make(map[string]*optionCompletion)
+
+/*****************************************************************************\
+  Define the list of valid values of an "enum" option, for completion.
+\*****************************************************************************/
+
+func SetOptCompletionValues(name string, values ...string) {
+	lc := std.optionKey(name)
+	completions[lc] = &optionCompletion{Values: values}
+}
+
+/*****************************************************************************\
+  Define the package list file (i.e.: "datacenters.list") from which to read
+  the valid values of an option, for completion.  The file is searched for in
+  the standard package places, as with ReadListFromPkgFile.
+\*****************************************************************************/
+
+func SetOptCompletionFile(name string, filename string) {
+	lc := std.optionKey(name)
+	completions[lc] = &optionCompletion{ListFile: filename}
+}
+
+/*****************************************************************************\
+  Define a callback returning the valid values of an option, for completion.
+  In the completion callback mode, the options are configured (from the
+  config files, environment and the command line so far) before calling it.
+\*****************************************************************************/
+
+func SetOptCompletionFunc(name string, complete CompletionFunc) {
+	lc := std.optionKey(name)
+	completions[lc] = &optionCompletion{Func: complete}
+}
+
+/*****************************************************************************\
+  Return a completion callback which caches the values returned by complete
+  (called with an empty prefix) for ttl, in the cache dir (see CacheDir) as
+  "completion/<key>", so that slow sources (i.e. an API) are not queried at
+  every tab.  If the values cannot be refreshed, stale cached values are used.
+\*****************************************************************************/
+
+func CachedCompletion(key string, ttl time.Duration, complete CompletionFunc) CompletionFunc {
+	return func(prefix string) ([]string, error) {
+		dir, err := CacheDir()
+		if err != nil {
+			return complete(prefix)
+		}
+		cache_file := filepath.Join(dir, "completion", key)
+		info, stat_err := os.Stat(cache_file)
+		if stat_err == nil && time.Since(info.ModTime()) < ttl {
+			return ReadListFromFile(cache_file)
+		}
+		values, err := complete("")
+		if err != nil {
+			if stat_err == nil {
+				ShowDebug("Using stale completions for %s: %v", key, err)
+				return ReadListFromFile(cache_file)
+			}
+			return nil, err
+		}
+		if err = writeFileAtomic(cache_file, []byte(strings.Join(values, "\n")+"\n"), 0600); err != nil {
+			ShowDebug("Failure caching completions for %s: %v", key, err)
+		}
+		return values, nil
+	}
+}
+
+/*****************************************************************************\
+  Return the completion values of the specified option that begin with the
+  specified prefix.
+\*****************************************************************************/
+
+func CompleteOptionValues(name string, prefix string) ([]string, error) {
+
+	var values, matches []string
+	var err error
+
+	lc := std.optionKey(name)
+	completion, ok := completions[lc]
+	if !ok {
+		return nil, nil
+	}
+	if completion.Func != nil {
+		if values, err = completion.Func(prefix); err != nil {
+			return nil, err
+		}
+	} else if completion.ListFile != "" {
+		if values, err = ReadListFromPkgFile(completion.ListFile); err != nil {
+			return nil, err
+		}
+	} else {
+		values = completion.Values
+	}
+	for _, value := range values {
+		// For list files, complete on the first field of each entry.
+		if fields := strings.Fields(value); len(fields) > 0 {
+			value = fields[0]
+		}
+		if strings.HasPrefix(value, prefix) {
+			matches = append(matches, value)
+		}
+	}
+	return matches, nil
+}
+
+/*****************************************************************************\
+  Handle the hidden completion callback mode: "prog __complete option prefix",
+  or, for programs with subcommands, "prog __complete -- words... prefix".
+\*****************************************************************************/
+
+func completeMode(args []string) {
+	if len(args) > 0 && args[0] == "--" {
+		completeCommandLine(args[1:])
+	}
+	var name, prefix string
+	if len(args) > 0 {
+		name = strings.TrimLeft(args[0], "-")
+	}
+	if len(args) > 1 {
+		prefix = args[1]
+	}
+	if hasCompletionFunc(name) {
+		configureForCompletion(nil)
+	}
+	values, err := CompleteOptionValues(name, prefix)
+	if err != nil {
+		ShowDebug("Failure completing option %s: %v", name, err)
+		Exit(1)
+	}
+	for _, value := range values {
+		Println("%s", value)
+	}
+	Exit(0)
+}
+
+/*****************************************************************************\
+  Write a completion script for the specified shell to w.  Only bash (which
+  zsh can also use, via bashcompinit) is currently supported.
+\*****************************************************************************/
+
+func GenCompletion(w io.Writer, shell string) error {
+
+	var flags []string
+
+	if shell != "bash" {
+		return Error("Unsupported shell \"%s\" for completion.", shell)
+	}
+	function := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(ProgramName) + "_complete"
+
+	// With subcommands, the options depend on the command, so call back into
+	// the program to complete the command line so far.
+	if len(std.commands) > 0 {
+		Fprintln(w, "# bash completion for %s", ProgramName)
+		Fprintln(w, "%s() {", function)
+		Fprintln(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"")
+		Fprintln(w, "    COMPREPLY=( $(%s %s -- \"${COMP_WORDS[@]:1:COMP_CWORD-1}\" \"$cur\" 2>/dev/null) )",
+			ProgramName, CompleteCommand)
+		Fprintln(w, "}")
+		Fprintln(w, "complete -o default -F %s %s", function, ProgramName)
+		return nil
+	}
+
+	sorted_keys := make([]string, 0, len(Config))
+	for name := range Config {
+		sorted_keys = append(sorted_keys, name)
+	}
+	sort.Strings(sorted_keys)
+
+	Fprintln(w, "# bash completion for %s", ProgramName)
+	Fprintln(w, "%s() {", function)
+	Fprintln(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\"")
+	Fprintln(w, "    local prev=\"${COMP_WORDS[COMP_CWORD-1]}\"")
+	Fprintln(w, "    case \"$prev\" in")
+	for _, name := range sorted_keys {
+		option := Config[name]
+		flags = append(flags, "--"+name)
+		if option.ShortOpt != "" {
+			flags = append(flags, "-"+option.ShortOpt)
+		}
+		if _, ok := completions[name]; !ok {
+			continue
+		}
+		pattern := "--" + name
+		if option.ShortOpt != "" {
+			pattern += "|-" + option.ShortOpt
+		}
+		Fprintln(w, "        %s)", pattern)
+		Fprintln(w, "            COMPREPLY=( $(%s %s %s \"$cur\" 2>/dev/null) )", ProgramName, CompleteCommand, name)
+		Fprintln(w, "            return;;")
+	}
+	Fprintln(w, "    esac")
+	Fprintln(w, "    if [[ \"$cur\" == -* ]]; then")
+	Fprintln(w, "        COMPREPLY=( $(compgen -W \"%s\" -- \"$cur\") )", strings.Join(flags, " "))
+	Fprintln(w, "    fi")
+	Fprintln(w, "}")
+	Fprintln(w, "complete -o default -F %s %s", function, ProgramName)
+	return nil
+}
+
+/*****************************************************************************\
+  Complete the last of the command line words, showing the completions, and
+  exit.
+\*****************************************************************************/
+
+func completeCommandLine(words []string) {
+	for _, match := range commandLineCompletions(words) {
+		Println("%s", match)
+	}
+	Exit(0)
+}
+
+/*****************************************************************************\
+  Return the completions of the last of the command line words: the value of
+  the option preceding it, an option of the invoked command, or a subcommand.
+\*****************************************************************************/
+
+func commandLineCompletions(words []string) []string {
+
+	var prefix, previous string
+	var matches []string
+
+	if len(words) > 0 {
+		prefix = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+	if len(words) > 0 {
+		previous = words[len(words)-1]
+	}
+	args := std.findCommand(words)
+
+	std.lock.RLock()
+	takes_value := strings.HasPrefix(previous, "-") && std.takesValue(previous)
+	std.lock.RUnlock()
+	if takes_value {
+		name := strings.TrimLeft(previous, "-")
+		if !strings.HasPrefix(previous, "--") {
+			name = shortOptName(name)
+		}
+		if hasCompletionFunc(name) {
+			configureForCompletion(words[:len(words)-1])
+		}
+		matches, _ = CompleteOptionValues(name, prefix)
+	} else if strings.HasPrefix(prefix, "-") {
+		std.lock.RLock()
+		for lc, option := range std.Config {
+			if option.Sources&SourceCommandLine != 0 && strings.HasPrefix("--"+lc, strings.ToLower(prefix)) {
+				matches = append(matches, "--"+lc)
+			}
+		}
+		std.lock.RUnlock()
+	} else if !hasArguments(args) {
+		words := std.Plugins(std.invoked)
+		for _, command := range std.Subcommands(std.invoked) {
+			words[command.Path[strings.LastIndex(command.Path, ":")+1:]] = command.Path
+		}
+		for word := range words {
+			if strings.HasPrefix(word, prefix) {
+				matches = append(matches, word)
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// Check if there are any arguments, other than options and their values.
+func hasArguments(args []string) bool {
+	std.lock.RLock()
+	defer std.lock.RUnlock()
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") || args[i] == "-" {
+			return true
+		} else if std.takesValue(args[i]) {
+			i++
+		}
+	}
+	return false
+}
+
+// Check if an option's values are completed by a callback.
+func hasCompletionFunc(name string) bool {
+	completion, ok := completions[std.optionKey(name)]
+	return ok && completion.Func != nil
+}
+
+/*****************************************************************************\
+  Configure the options for a completion callback, from the config files,
+  the environment and the command line words so far.  As the command line is
+  incomplete, errors are ignored.
+\*****************************************************************************/
+
+func configureForCompletion(words []string) {
+	if _, err := std.ConfigureOptions(words); err != nil {
+		ShowDebug("Failure configuring options for completion: %v", err)
+	}
+	syncGlobals()
+}
+
+func shortOptName(shortopt string) string {
+	std.lock.RLock()
+	defer std.lock.RUnlock()
+	for lc, option := range std.Config {
+		if option.ShortOpt == shortopt {
+			return lc
+		}
+	}
+	return shortopt
+}
+
+/*****************************************************************************\
+  Check if the program was invoked in the completion callback mode.
+\*****************************************************************************/
+
+func isCompleteMode() bool {
+	return len(os.Args) > 1 && os.Args[1] == CompleteCommand
+}
@@ -7,11 +7,12 @@ package sitepkg
 \*****************************************************************************/
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"sort"
 	"strconv"
@@ -22,24 +23,40 @@ import (
 )
 
 type Option struct {
+	Name        string
 	Type        string
 	ShortOpt    string
-	ConfigFile  bool
+	Sources     OptionSource
 	Desc        string
+	Group       string
 	StringValue *string
 	BoolValue   *bool
 	IntValue    *int
 	UintValue   *uint
+	ListValue   *[]string
+	Default     interface{}
 	Source      string
+	History     []Assignment
+	Final       bool `json:",omitempty"`
+	derive      func() (interface{}, error)
+	noValue     string
+	yieldShort  bool
+	pkg         bool
+}
+
+type Assignment struct {
+	Source string
+	Line   int `json:",omitempty"`
+	Value  interface{}
+	Append bool `json:",omitempty"`
 }
 
 const ConfErrNoSuchOption = "No such option"
 const ConfUseConfig = "ConfUseConfig"
+const ConfFinalSuffix = "!final"
 
 type Options map[string]*Option
 
-var Config = make(Options)
-var ConfigDirs []string
 var PodMap = make(map[string]string)
 
 /*****************************************************************************\
@@ -47,83 +64,178 @@ var PodMap = make(map[string]string)
   Call this function after defining all the options for the program.
   First read in options from any AND ALL config files found.
   Then parse the command line for any overrides.
+  Then handle the standard options (--Help, --ShowConfig, etc).
 \*****************************************************************************/
 
 func ConfigureOptions() ([]string, error) {
 
-	var args, configFiles, commandPaths []string
+	syncGlobals()
+	// Config dirs are needed to complete option values from package files.
+	args := std.normalizeArgs(os.Args[1:], "")
+	std.preScanRoot(std.optionArgs(args))
+	std.setConfigDirs()
+	syncGlobals()
 
-	ConfigDirs = []string{PackageEtc, LocalEtc, LocalEtc + "-" + PkgVersion}
-	if home, err := os.UserHomeDir(); err != nil {
-		Warn("Failure getting home dir: %v", err)
-	} else {
-		ConfigDirs = append(ConfigDirs, home+"/."+PkgName, home+"/."+Package)
+	// If invoked by a completion script to complete an option value, do so and exit.
+	if isCompleteMode() {
+		completeMode(os.Args[2:])
 	}
 
-	if PkgName != ProgramName {
-		configFiles = append(configFiles, PkgName+".conf")
-	}
-	if commandPaths = GetCommandPaths(); len(commandPaths) == 0 {
-		return args, Error("bug: failure getting command paths")
+	// Handle the modes acting on the config files before reading them.
+	option_args := std.optionArgs(args)
+	configFileModes(func(name string) bool { return preScanFlag(option_args, name) })
+
+	args, err := std.ConfigureOptions(args)
+	return args, handleStandardOptions(err)
+}
+
+/*****************************************************************************\
+  As ConfigureOptions, with a context: the run's command context (see
+  CommandContext) is derived from ctx, so that the operations of sitepkg
+  (REST calls, ssh, scp, the pager, etc) and of the program using it are
+  cancelled with ctx, i.e. when embedded in a server, or in tests with a
+  deadline.  If ctx is done, an error is returned.
+\*****************************************************************************/
+
+func ConfigureOptionsContext(ctx context.Context) ([]string, error) {
+	setCommandParent(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	for _, p := range commandPaths {
-		configFiles = append(configFiles, p+".conf")
+	args, err := ConfigureOptions()
+	if err == nil {
+		err = ctx.Err()
 	}
+	return args, err
+}
 
-	for _, filename := range configFiles {
-		for _, pathname := range ConfigDirs {
-			config_file := pathname + "/" + filename
-			if _, err := os.Stat(config_file); err == nil {
-				if err := ReadConfigFile(config_file); err != nil {
-					return args, Error("%s!", err)
-				}
-			} else if !os.IsNotExist(err) {
-				return args, Error("Error stat'ing config file %s: %s", config_file, err)
-			}
+/*****************************************************************************\
+  Handle the standard options acting on the config files (--CheckConfig,
+  etc), if set, and exit.  They are handled before the config files are
+  read, which stops at the first error; is_set reports if an option is set
+  on the command line.
+\*****************************************************************************/
+
+func configFileModes(is_set func(name string) bool) {
+	if _, err := pkgOpt[bool]("CheckConfig"); err == nil && is_set("CheckConfig") {
+		checkConfigMode()
+	}
+	if _, err := pkgOpt[bool]("Deprecations"); err == nil && is_set("Deprecations") {
+		deprecationsMode()
+	}
+	if _, err := pkgOpt[bool]("MigrateConfig"); err == nil && is_set("MigrateConfig") {
+		if err := MigrateConfig(); err != nil {
+			Exit(1)
 		}
+		Exit(0)
 	}
-	args, err := ProcessCommandLine()
+}
+
+/*****************************************************************************\
+  Having configured the options, set the convenience globals, handle the
+  standard options (--Help, --ShowConfig, etc), and record the run's config
+  file and option state.  Return err, the error configuring the options.
+\*****************************************************************************/
+
+func handleStandardOptions(err error) error {
+
+	syncGlobals()
 	if err != nil {
-		return args, err
+		return err
 	}
-
-	// Set convenience globals: Verbose, Quiet, Debug.
-	// Note that these options may not exist for a given program.
-	debug, _ := GetBoolOpt("Debug")
-	if debug {
-		Debug = true
-		Verbose = true
-	} else {
-		verbose, _ := GetBoolOpt("Verbose")
-		if verbose {
-			Verbose = true
-		} else {
-			Quiet, _ = GetBoolOpt("Quiet")
-			Quieter, _ = GetBoolOpt("Quieter")
-		}
+	if err = setLoggingFromOptions(); err != nil {
+		return err
+	} else if _, err = GetOutputFormat(); err != nil {
+		return err
+	} else if _, err = GetTimeout(); err != nil {
+		return err
 	}
 
 	// If --Help is an option, and it is set, Show Usage and exit.
-	help, _ := GetBoolOpt("Help")
+	help, _ := pkgOpt[bool]("Help")
 	if help {
 		Usage()
 		Exit(0)
 	}
 
+	// If --GenConfig is an option, and it is set, GenConfig and exit.
+	gen_config, _ := pkgOpt[bool]("GenConfig")
+	if gen_config {
+		if err := GenConfig(DefaultPrint, ""); err != nil {
+			Exit(1, err)
+		}
+		Exit(0)
+	}
+
+	// If --Configure is an option, and it is set, run the ConfigureWizard and exit.
+	configure, _ := pkgOpt[bool]("Configure")
+	if configure {
+		if err := ConfigureWizard(); err != nil {
+			Exit(1, err)
+		}
+		Exit(0)
+	}
+
+	// If --Completion is an option, and it is set, GenCompletion and exit.
+	shell, _ := pkgOpt[string]("Completion")
+	if shell != "" {
+		if err := GenCompletion(DefaultPrint, shell); err != nil {
+			Exit(1, err)
+		}
+		Exit(0)
+	}
+
 	// If --ShowConfig is an option, and it is set, ShowConfig and exit.
-	show_config, _ := GetBoolOpt("ShowConfig")
+	show_config, _ := pkgOpt[bool]("ShowConfig")
 	if show_config {
 		ShowConfig()
 		Exit(0)
 	}
 
+	// If --ShowChanged is an option, and it is set, ShowChangedConfig and exit.
+	show_changed, _ := pkgOpt[bool]("ShowChanged")
+	if show_changed {
+		ShowChangedConfig()
+		Exit(0)
+	}
+
+	// If --SupportInfo is an option, and it is set, ShowSupportInfo and exit.
+	support_info, _ := pkgOpt[bool]("SupportInfo")
+	if support_info {
+		ShowSupportInfo()
+		Exit(0)
+	}
+
 	// If --Version is an option, and it is set, ShowVersion and exit.
-	show_version, _ := GetBoolOpt("Version")
+	show_version, _ := pkgOpt[bool]("Version")
 	if show_version {
 		ShowVersion()
 		Exit(0)
 	}
-	return args, err
+	// If --OptionHistory is an option, and it is set, ShowOptionHistory and exit.
+	history_option, _ := pkgOpt[string]("OptionHistory")
+	if history_option != "" {
+		if err := ShowOptionHistory(history_option); err != nil {
+			Exit(1, err)
+		}
+		Exit(0)
+	}
+
+	CheckFileDrift(ConfigFilesRead...)
+	if err := recordOptionHistory(); err != nil {
+		ShowDebug("Failure recording option history: %v", err)
+	}
+	optionsConfigured = true
+
+	// If --Interactive is an option, and it is set, RunInteractive and exit.
+	interactive, _ := pkgOpt[bool]("Interactive")
+	if interactive {
+		if err := RunInteractive(); err != nil {
+			Exit(1, err)
+		}
+		Exit(0)
+	}
+	return nil
 }
 
 /*****************************************************************************\
@@ -131,14 +243,41 @@ func ConfigureOptions() ([]string, error) {
 \*****************************************************************************/
 
 func Usage() {
+	if JSONOutput() {
+		showUsageJSON()
+		return
+	}
 	err := ShowPod()
 	if err != nil {
 		Warn("Failure showing full usage: %v", err)
-		Show("Usage of %s:\n", os.Args[0])
-		pflag.PrintDefaults()
+		Show("Usage of %s:\n", std.commandLine())
+		std.showCommands()
+		std.writeFlagUsages(DefaultShow)
 	}
 }
 
+/*****************************************************************************\
+  Show usage as a JSON document: the program, its version, its command line
+  options, and its usage text if found in PodMap.
+\*****************************************************************************/
+
+func showUsageJSON() {
+	var options []OptionInfo
+	EachOption(func(info OptionInfo) bool {
+		if info.Sources&SourceCommandLine != 0 {
+			options = append(options, info)
+		}
+		return true
+	})
+	usage, _ := FindPodText()
+	showJSON(struct {
+		Program    string
+		PkgVersion string
+		Usage      string `json:",omitempty"`
+		Options    []OptionInfo
+	}{ProgramName, PkgVersion, usage, options})
+}
+
 /*****************************************************************************\
   Use pod2text to show the POD page for this command.
 \*****************************************************************************/
@@ -166,11 +305,36 @@ func ShowPod() error {
 		return Error("No POD text or POD file found")
 	}
 
-	page_opt, err := GetBoolOpt("Page")
+	// If the POD found is not that of the invoked command, but of the whole
+	// program, show only the command's section, if any.
+	var podSource string
+	commandPaths := GetCommandPaths()
+	command := commandPaths[len(commandPaths)-1]
+	if podText != "" && PodMap[command] == "" {
+		if section, ok := std.commandPodSection(podText); ok {
+			podText = section
+		}
+	} else if podPath != "" && filepath.Base(podPath) != command {
+		if data, err := os.ReadFile(podPath); err != nil {
+			Warn("Failure reading POD file %s: %v", podPath, err)
+		} else if section, ok := std.commandPodSection(string(data)); ok {
+			podSource = section
+		}
+	}
+	pod2textCommand := func() *exec.Cmd {
+		if podSource != "" {
+			command := exec.CommandContext(CommandContext(), pod2text)
+			command.Stdin = strings.NewReader(podSource)
+			return command
+		}
+		return exec.CommandContext(CommandContext(), pod2text, podPath)
+	}
+
+	page_opt, err := pkgOpt[bool]("Page")
 	var pager string
 
 	if page_opt {
-		pager, err = GetStringOpt("Pager")
+		pager, err = pkgOpt[string]("Pager")
 		if err != nil {
 			Warn("Failure getting pager: %v", err)
 		}
@@ -187,12 +351,12 @@ func ShowPod() error {
 			Print("%s", podText)
 			return nil
 		} else {
-			pod2text_command := exec.Command(pod2text, podPath)
+			pod2text_command := pod2textCommand()
 			pod2text_command.Stdout = os.Stdout
 			return pod2text_command.Run()
 		}
 	}
-	pager_command := exec.Command(pager)
+	pager_command := exec.CommandContext(CommandContext(), pager)
 	pager_command.Stdout = os.Stdout
 	pager_command.Stderr = os.Stderr
 
@@ -204,7 +368,7 @@ func ShowPod() error {
 			pw.Close()
 		}()
 	} else {
-		pod_command := exec.Command(pod2text, podPath)
+		pod_command := pod2textCommand()
 		if pager_command.Stdin, err = pod_command.StdoutPipe(); err != nil {
 			Warn("Error attaching pipe: %v", err)
 		}
@@ -258,8 +422,8 @@ func FindPodFile() (string, error) {
 
 	podPaths := []string{
 		PackageDir + "/share/pod/pod1/",
-		"/usr/share/doc/" + PkgName + "/pod1/",
-		"/usr/share/doc/" + Package + "/pod1/",
+		RootPath("/usr/share/doc/" + PkgName + "/pod1/"),
+		RootPath("/usr/share/doc/" + Package + "/pod1/"),
 	}
 
 	// Set up the list of paths to search.
@@ -319,179 +483,398 @@ func ExecPath(command string) (command_path string, err error) {
     % ibapi host add ....
   ignore all sections except:
     ibapi  host  host:add
+  plus, if a tenant is specified (--Tenant lab), the section "tenant:lab".
+
+  A value followed by "!final" (i.e. "Pager = less !final") marks the option
+  final: later config files and the environment cannot override it (such
+  attempts are ignored, with a warning), nor can the command line or the
+  program (an error).  This is for site-enforced settings.
+
+  A value may be double quoted, as a Go string (i.e. Comment = "a # b"), to
+  include what would otherwise be taken as a comment, blanks, etc; the config
+  writers quote such values.
 
 \*****************************************************************************/
 
-func ReadConfigFile(config_file string) error {
+func (c *Configurator) ReadConfigFile(config_file string) error {
+	ShowDebug("Reading config file: %s", config_file)
+	return c.scanConfigFile(config_file, false, func(entry configEntry) error {
+		if entry.Err != nil {
+			return entry.Err
+		}
+		name := c.optionKey(c.migrateName(c.optionRenames, "Option", entry.Name, config_file))
+		return c.setFileOption(name, entry.Value, config_file, entry.Line, entry.Final, entry.Append)
+	})
+}
+
+/*****************************************************************************\
+  An option assignment, or a syntax error, in a config file.
+\*****************************************************************************/
+
+type configEntry struct {
+	Line    int
+	Section string
+	Name    string
+	Value   string
+	Final   bool
+	Append  bool
+	Err     error
+}
+
+/*****************************************************************************\
+  Scan a config file, calling fn for each option assignment, and each syntax
+  error, in the sections that apply (or in all sections), stopping at the
+  first error fn returns.  Option names are passed in lower case.
+\*****************************************************************************/
+
+func (c *Configurator) scanConfigFile(config_file string, all_sections bool, fn func(entry configEntry) error) error {
 
 	var section string
 	var ignoreSection bool
 	var line_no int
 	var commandPaths []string
 
-	if commandPaths = GetCommandPaths(); len(commandPaths) == 0 {
+	if commandPaths = c.GetCommandPaths(); len(commandPaths) == 0 {
 		return Error("bug: failure getting command paths")
 	}
-	ShowDebug("Reading config file: %s", config_file)
+	sections := append(commandPaths, c.tenantSections()...)
 
-	file, err := os.Open(config_file)
+	data, err := os.ReadFile(config_file)
 	if err != nil {
-		return Error("Error opening config file \"%s\": %v", config_file, err)
+		return Error("Error reading config file \"%s\": %v", config_file, err)
 	}
-	scanner := bufio.NewScanner(file)
-
-	for scanner.Scan() {
-		line_no++
+	// Migrate older files, per their config-version header, in memory.
+	lines := strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), "\n")
+	// Lines are numbered as in the file, not as migrated.
+	var line_numbers []int
+	version, _, version_err := configFileVersion(lines)
+	if version_err == nil {
+		original := append([]string(nil), lines...)
+		lines = c.migrateConfigLines(config_file, lines, version)
+		line_numbers = sourceLineNumbers(original, lines)
+	}
+	for i, text := range lines {
+		line_no = i + 1
+		if line_numbers != nil {
+			line_no = line_numbers[i]
+		}
 		// Trim any leading spaces:
-		line := strings.TrimLeft(scanner.Text(), " \t")
+		line := strings.TrimLeft(text, " \t")
 		// Skip comment lines:
 		if strings.HasPrefix(line, "#") {
+			c.trace("%s:%d: %s: comment, skipped", config_file, line_no, traceSection(section))
 			continue
 		}
 		// Skip blank lines:
 		if line == "" {
+			c.trace("%s:%d: %s: blank, skipped", config_file, line_no, traceSection(section))
+			continue
+		}
+		c.trace("%s:%d: %s", config_file, line_no, line)
+		// Shave off a trailing comment (must be separated from option value by
+		// at least one space, and follow the closing quote of a quoted value):
+		if i := strings.Index(line, "="); i >= 0 && !strings.HasPrefix(line, "[") {
+			value, _ := splitConfigComment(line[i+1:])
+			line = line[:i+1] + value
+		} else {
+			line = configCommentRegexp.Split(line, 2)[0]
+		}
+
+		// Skip the config-version header, reporting it if bad.
+		if section == "" && isVersionHeader(line) {
+			if version_err != nil {
+				if err = fn(configEntry{Line: line_no, Err: version_err}); err != nil {
+					return err
+				}
+			}
 			continue
 		}
-		// Shave off a trailing comment (must be separated from option value by at least one space):
-		comment := regexp.MustCompile("[ \t]+#.*$")
-		slice := comment.Split(line, 2)
-		line = slice[0]
 
 		// Check for a command section ([ptr]).
 		if strings.HasPrefix(line, "[") {
 			section = strings.TrimPrefix(line, "[")
 			section = strings.TrimSuffix(section, "]")
+			section = c.migrateName(c.sectionRenames, "Section", section, config_file)
 			//Show("Section = %s", section)
 			if section == "" {
-				return Error("empty section name at line %d: %s", line_no, line)
-			} else if inList, err := InList(commandPaths, section); err != nil {
+				err = Error("empty section name at line %d: %s", line_no, line)
+				if err = fn(configEntry{Line: line_no, Err: err}); err != nil {
+					return err
+				}
+			} else if inList, err := InList(sections, section); err != nil {
 				return Error("failure checking commandPath list")
 			} else {
-				ignoreSection = !inList
+				ignoreSection = !inList && !all_sections
+				if ignoreSection {
+					c.trace("%s:%d: section %s does not apply; skipping it", config_file, line_no, traceSection(section))
+				} else {
+					c.trace("%s:%d: section %s applies", config_file, line_no, traceSection(section))
+				}
 			}
 			continue
 		}
 		if ignoreSection {
 			//Show("Ignoring section = %s", section)
+			c.trace("%s:%d: %s: skipped", config_file, line_no, traceSection(section))
 			continue
 		}
 
-		slice = strings.SplitN(line, "=", 2)
+		slice := strings.SplitN(line, "=", 2)
 		if len(slice) != 2 {
-			return Error("Bad line (%d) in config file %s", line_no, config_file)
+			err = Error("Bad line (%d) in config file %s", line_no, config_file)
+			if err = fn(configEntry{Line: line_no, Section: section, Err: err}); err != nil {
+				return err
+			}
+			continue
 		}
 		option_name := strings.TrimRight(slice[0], " \t")
-		option_name = strings.ToLower(option_name)
+		append_value := strings.HasSuffix(option_name, "+")
+		option_name = strings.TrimRight(strings.TrimSuffix(option_name, "+"), " \t")
+		option_name = c.optionKey(option_name)
 		option_value := strings.TrimLeft(slice[1], " \t")
 		// Show ("option_name: \"%s\"", option_name)
 		// Show ("option_value: \"%s\"", option_value)
+		final := false
+		if strings.HasSuffix(option_value, ConfFinalSuffix) {
+			option_value = strings.TrimRight(strings.TrimSuffix(option_value, ConfFinalSuffix), " \t")
+			final = true
+		}
+		if unquoted, err := strconv.Unquote(option_value); err == nil && strings.HasPrefix(option_value, `"`) {
+			option_value = unquoted
+		}
 
-		option, ok := Config[option_name]
-		if !ok {
-			return Error("Unknown option \"%s\" in config file %s", option_name, config_file)
-		}
-		// Show ("Current value: %s", option)
-		// Show ("option_type: %s", option.Type)
-		// Show ("option_file: %b", option.ConfigFile)
-		if !option.ConfigFile {
-			return Error("Illegal option \"%s\" in config file %s", option_name, config_file)
-		}
-		option.Source = "file:" + config_file
-		switch option.Type {
-		case "string":
-			*option.StringValue = option_value
-		case "int":
-			*option.IntValue, err = strconv.Atoi(option_value)
-			if err != nil {
-				return Error("Unknown value \"%s\" specified for integer option \"%s\" in file %s",
-					option_value, option_name, config_file)
-			}
-		case "uint":
-			var var_uint uint64
-			if var_uint, err = strconv.ParseUint(option_value, 10, 64); err != nil {
-				return Error("Unknown value \"%s\" specified for uint option \"%s\" in file %s",
-					option_value, option_name, config_file)
-			}
-			*option.UintValue = uint(var_uint)
-		case "bool":
-			option_value = strings.ToLower(option_value)
-			match, _ := regexp.MatchString("^(t|true|yes|1)$", option_value)
+		entry := configEntry{Line: line_no, Section: section, Name: option_name, Value: option_value,
+			Final: final, Append: append_value}
+		if err = fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Set an option to a value read from a config file, marking it final if so
+  specified, or, for lists, appending to it if so specified ("+=").
+\*****************************************************************************/
+
+func (c *Configurator) setFileOption(option_name string, option_value string, config_file string, line_no int, final bool, append_value bool) (err error) {
+	c.writeLock()
+	defer c.lock.Unlock()
+
+	option, ok := c.Config[option_name]
+	if !ok {
+		return Error("Unknown option \"%s\" in config file %s", option_name, config_file)
+	}
+	// Show ("Current value: %s", option)
+	// Show ("option_type: %s", option.Type)
+	if option.Sources&SourceFile == 0 {
+		return Error("Illegal option \"%s\" in config file %s: %s", option_name, config_file,
+			sourceViolation(option, SourceFile))
+	}
+	if option.Final {
+		Warn("Ignoring final option \"%s\" in config file %s (line %d); set by %s",
+			option_name, config_file, line_no, option.Source)
+		c.trace("%s:%d: => %s: ignored (final, set by %s)", config_file, line_no, option.Name, option.Source)
+		return nil
+	}
+	if append_value && option.Type != "list" {
+		return Error("Illegal \"+=\" for %s option \"%s\" in config file %s (line %d)",
+			option.Type, option_name, config_file, line_no)
+	}
+	previous := optionValue(option)
+	option.Source = "file:" + config_file
+	if err = setOptionValue(option, option_name, option_value); err != nil {
+		return Error("%s in file %s", err, config_file)
+	}
+	if append_value {
+		*option.ListValue = append(previous.([]string), *option.ListValue...)
+	}
+	option.Final = final
+	recordAssignment(option, line_no)
+	option.History[len(option.History)-1].Append = append_value
+	if final {
+		c.trace("%s:%d: => %s = %s (final)", config_file, line_no, option.Name, formatValue(optionValue(option)))
+	} else {
+		c.trace("%s:%d: => %s = %s", config_file, line_no, option.Name, formatValue(optionValue(option)))
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Record the current value and Source of an option in its History.  For
+  config files, line is the line number of the assignment.
+\*****************************************************************************/
+
+func recordAssignment(option *Option, line int) {
+	option.History = append(option.History,
+		Assignment{Source: option.Source, Line: line, Value: optionValue(option)})
+}
+
+/*****************************************************************************\
+  Return the history of assignments to an option, from its default to its
+  current value.
+\*****************************************************************************/
+
+func (c *Configurator) OptionHistory(name string) ([]Assignment, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
+	if !ok {
+		return nil, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	return option.History, nil
+}
+
+/*****************************************************************************\
+  Set the value of an option from its string representation, as found in a
+  config file.
+\*****************************************************************************/
+
+func setOptionValue(option *Option, option_name string, option_value string) (err error) {
+	switch option.Type {
+	case "string":
+		*option.StringValue = option_value
+	case "int":
+		var var_int int
+		if var_int, err = strconv.Atoi(option_value); err != nil {
+			return Error("Unknown value \"%s\" specified for integer option \"%s\"",
+				option_value, option_name)
+		}
+		*option.IntValue = var_int
+	case "uint":
+		var var_uint uint64
+		if var_uint, err = strconv.ParseUint(option_value, 10, 64); err != nil {
+			return Error("Unknown value \"%s\" specified for uint option \"%s\"",
+				option_value, option_name)
+		}
+		*option.UintValue = uint(var_uint)
+	case "list":
+		*option.ListValue = parseList(option_value)
+	case "bool":
+		option_value = strings.ToLower(option_value)
+		match, _ := regexp.MatchString("^(t|true|yes|1)$", option_value)
+		if match {
+			*option.BoolValue = true
+		} else {
+			match, _ = regexp.MatchString("^(f|false|no|0)$", option_value)
 			if match {
-				*option.BoolValue = true
+				*option.BoolValue = false
 			} else {
-				match, _ = regexp.MatchString("^(f|false|no|0)$", option_value)
-				if match {
-					*option.BoolValue = false
-				} else {
-					return Error("Unknown value \"%s\" specified for boolean option \"%s\" in file %s",
-						option_value, option_name, config_file)
-				}
+				return Error("Unknown value \"%s\" specified for boolean option \"%s\"",
+					option_value, option_name)
 			}
 		}
 	}
-	if err = file.Close(); err != nil {
-		return Error("Error closing config file \"%s\": %s", config_file, err)
-	}
 	return nil
 }
 
+/*****************************************************************************\
+  Define the flag for an option in the flag set, bound to the option value.
+\*****************************************************************************/
+
+func defineFlag(flag_set *pflag.FlagSet, name string, option *Option) {
+	shortopt := option.ShortOpt
+	desc := option.Desc
+
+	switch option.Type {
+	case "string":
+		// Show ("Config option value: %v", *option.StringValue)
+		if shortopt != "" {
+			flag_set.StringVarP(option.StringValue, name, shortopt, *option.StringValue, desc)
+		} else {
+			flag_set.StringVar(option.StringValue, name, *option.StringValue, desc)
+		}
+	case "bool":
+		// Show ("Config option value: %v", *option.BoolValue)
+		if shortopt != "" {
+			flag_set.BoolVarP(option.BoolValue, name, shortopt, *option.BoolValue, desc)
+		} else {
+			flag_set.BoolVar(option.BoolValue, name, *option.BoolValue, desc)
+		}
+	case "int":
+		// Show ("Config option value: %v", *option.IntValue)
+		if shortopt != "" {
+			flag_set.IntVarP(option.IntValue, name, shortopt, *option.IntValue, desc)
+		} else {
+			flag_set.IntVar(option.IntValue, name, *option.IntValue, desc)
+		}
+	case "uint":
+		// Show ("Config option value: %v", *option.UintValue)
+		if shortopt != "" {
+			flag_set.UintVarP(option.UintValue, name, shortopt, *option.UintValue, desc)
+		} else {
+			flag_set.UintVar(option.UintValue, name, *option.UintValue, desc)
+		}
+	case "list":
+		if shortopt != "" {
+			flag_set.StringSliceVarP(option.ListValue, name, shortopt, *option.ListValue, desc)
+		} else {
+			flag_set.StringSliceVar(option.ListValue, name, *option.ListValue, desc)
+		}
+	}
+	// Options not allowed on the command line are still defined, hidden,
+	// so that using them is reported as such rather than as unknown.
+	if option.Sources&SourceCommandLine == 0 {
+		flag_set.MarkHidden(name)
+	}
+	if option.noValue != "" {
+		flag_set.Lookup(name).NoOptDefVal = option.noValue
+	}
+}
+
 /*****************************************************************************\
   Process the command line for options
 \*****************************************************************************/
 
-func ProcessCommandLine() ([]string, error) {
-	var shortopt, desc string
+func (c *Configurator) ProcessCommandLine(args []string) ([]string, error) {
+
+	c.writeLock()
+	defer c.lock.Unlock()
 
-	for name, option := range Config {
+	for name, option := range c.Config {
 		// Show ("Config name: %s", name)
-		shortopt = option.ShortOpt
-		desc = option.Desc
-
-		switch option.Type {
-		case "string":
-			// Show ("Config option value: %v", *option.StringValue)
-			if shortopt != "" {
-				pflag.StringVarP(option.StringValue, name, shortopt, *option.StringValue, desc)
-			} else {
-				pflag.StringVar(option.StringValue, name, *option.StringValue, desc)
-			}
-		case "bool":
-			// Show ("Config option value: %v", *option.BoolValue)
-			if shortopt != "" {
-				pflag.BoolVarP(option.BoolValue, name, shortopt, *option.BoolValue, desc)
-			} else {
-				pflag.BoolVar(option.BoolValue, name, *option.BoolValue, desc)
-			}
-		case "int":
-			// Show ("Config option value: %v", *option.IntValue)
-			if shortopt != "" {
-				pflag.IntVarP(option.IntValue, name, shortopt, *option.IntValue, desc)
-			} else {
-				pflag.IntVar(option.IntValue, name, *option.IntValue, desc)
-			}
-		case "uint":
-			// Show ("Config option value: %v", *option.UintValue)
-			if shortopt != "" {
-				pflag.UintVarP(option.UintValue, name, shortopt, *option.UintValue, desc)
-			} else {
-				pflag.UintVar(option.UintValue, name, *option.UintValue, desc)
-			}
+		// Flags are already defined if the command line was processed before.
+		if c.FlagSet.Lookup(name) != nil {
+			continue
 		}
+		defineFlag(c.FlagSet, name, option)
 	}
 
-	// Case Insensitive:
-	pflag.CommandLine.SetNormalizeFunc(flagCaseInsensitive)
+	// Case Insensitive, unless in case sensitive mode:
+	if !c.CaseSensitive {
+		c.FlagSet.SetNormalizeFunc(flagCaseInsensitive)
+	}
 
 	// Parse the command line:
-	pflag.Parse()
+	if c.stopAtArgument() {
+		c.FlagSet.SetInterspersed(false)
+	}
+	if c.invoked != "" {
+		c.setCommandUsage()
+	}
+	if err := c.FlagSet.Parse(args); err != nil {
+		if c.invoked != "" {
+			return nil, Error("%s: %v", c.commandLine(), err)
+		}
+		return nil, err
+	}
 
 	// Now check which options were actually set via the command line:
-	for name, option := range Config {
-		if pflag.CommandLine.Changed(name) {
+	for name, option := range c.Config {
+		if c.FlagSet.Changed(name) && option.Sources&SourceCommandLine == 0 {
+			return nil, Error("Illegal option \"--%s\": %s", name, sourceViolation(option, SourceCommandLine))
+		} else if c.FlagSet.Changed(name) && option.Final {
+			return nil, Error("Option \"--%s\" may not be overridden; it is final, set by %s", name, option.Source)
+		} else if c.FlagSet.Changed(name) {
 			option.Source = "CommandLine"
+			recordAssignment(option, 0)
 		}
 	}
-	return pflag.Args(), nil
+	if err := c.applyOptionOverrides(); err != nil {
+		return nil, err
+	}
+	return c.FlagSet.Args(), nil
 }
 
 /*****************************************************************************\
@@ -516,24 +899,83 @@ func flagCaseInsensitive(f *pflag.FlagSet, name string) pflag.NormalizedName {
 	return pflag.NormalizedName(string(new_name))
 }
 
+/*****************************************************************************\
+  Check that an option being defined does not conflict with one already
+  defined: by name, with a different type or short option, or by short
+  option.  An option may be redefined identically (i.e. to change its
+  default or description), but not once its flag has been defined.  As
+  conflicts are programming errors, they panic; except that the package's
+  own options of convenience short options (see yieldShortOpt) give them
+  up to the program's.  Called with the lock held.
+\*****************************************************************************/
+
+func (c *Configurator) checkRegistration(lc string, option_type string, shortopt string) {
+	if option, ok := c.Config[lc]; ok {
+		if option.Type != option_type || option.ShortOpt != shortopt {
+			panic(Error("programming error: option \"%s\" redefined as %s (-%s); was %s (-%s)",
+				lc, option_type, shortopt, option.Type, option.ShortOpt))
+		} else if c.FlagSet.Lookup(lc) != nil {
+			panic(Error("programming error: option \"%s\" redefined after the command line was processed", lc))
+		}
+	}
+	if shortopt == "" {
+		return
+	}
+	for name, option := range c.Config {
+		if option.ShortOpt == shortopt && name != lc {
+			if option.yieldShort && c.FlagSet.Lookup(name) == nil {
+				option.ShortOpt = ""
+				continue
+			}
+			panic(Error("programming error: short option -%s of \"%s\" already used by \"%s\"",
+				shortopt, lc, name))
+		}
+	}
+}
+
+/*****************************************************************************\
+  Let the short option of an option defined by the package go to any option
+  of the program's later defined with it: i.e. -o, of Option, for programs
+  with their own -o.
+\*****************************************************************************/
+
+func (c *Configurator) yieldShortOpt(name string) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	if option, ok := c.Config[c.optionKey(name)]; ok {
+		option.yieldShort = true
+	}
+}
+
 /*****************************************************************************\
   Define an option of type string.
 \*****************************************************************************/
 
-func SetStringOpt(name string, shortopt string, file bool, value string, desc string) {
+func (c *Configurator) SetStringOpt(name string, shortopt string, file bool, value string, desc string) {
+	c.writeLock()
+	defer c.lock.Unlock()
 	var my_value string = value
-	lc := strings.ToLower(name)
-	Config[lc] = &Option{Type: "string", ShortOpt: shortopt, ConfigFile: file,
-		Desc: desc, StringValue: &my_value, Source: "Default"}
+	lc := c.optionKey(name)
+	c.checkRegistration(lc, "string", shortopt)
+	option := &Option{Name: name, Type: "string", ShortOpt: shortopt, Sources: fileSources(file),
+		Desc: desc, StringValue: &my_value, Default: value, Source: "Default"}
+	recordAssignment(option, 0)
+	c.Config[lc] = option
 }
 
 /*****************************************************************************\
   Retrieve an option value of type string.
 \*****************************************************************************/
 
-func GetStringOpt(name string) (value string, err error) {
-	lc := strings.ToLower(name)
-	option, ok := Config[lc]
+func (c *Configurator) GetStringOpt(name string) (value string, err error) {
+	c.noteRead(name)
+	if value, frozen, err := frozenGet[string](c, name, "GetStringOpt"); frozen {
+		return value, err
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
 	if !ok {
 		return value, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
 	}
@@ -548,20 +990,31 @@ func GetStringOpt(name string) (value string, err error) {
   Define an option of type bool.
 \*****************************************************************************/
 
-func SetBoolOpt(name string, shortopt string, file bool, value bool, desc string) {
+func (c *Configurator) SetBoolOpt(name string, shortopt string, file bool, value bool, desc string) {
+	c.writeLock()
+	defer c.lock.Unlock()
 	var my_value bool = value
-	lc := strings.ToLower(name)
-	Config[lc] = &Option{Type: "bool", ShortOpt: shortopt, ConfigFile: file,
-		Desc: desc, BoolValue: &my_value, Source: "Default"}
+	lc := c.optionKey(name)
+	c.checkRegistration(lc, "bool", shortopt)
+	option := &Option{Name: name, Type: "bool", ShortOpt: shortopt, Sources: fileSources(file),
+		Desc: desc, BoolValue: &my_value, Default: value, Source: "Default"}
+	recordAssignment(option, 0)
+	c.Config[lc] = option
 }
 
 /*****************************************************************************\
   Retrieve an option value of type bool.
 \*****************************************************************************/
 
-func GetBoolOpt(name string) (value bool, err error) {
-	lc := strings.ToLower(name)
-	option, ok := Config[lc]
+func (c *Configurator) GetBoolOpt(name string) (value bool, err error) {
+	c.noteRead(name)
+	if value, frozen, err := frozenGet[bool](c, name, "GetBoolOpt"); frozen {
+		return value, err
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
 	if !ok {
 		return value, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
 	}
@@ -576,20 +1029,31 @@ func GetBoolOpt(name string) (value bool, err error) {
   Define an option of type int.
 \*****************************************************************************/
 
-func SetIntOpt(name string, shortopt string, file bool, value int, desc string) {
+func (c *Configurator) SetIntOpt(name string, shortopt string, file bool, value int, desc string) {
+	c.writeLock()
+	defer c.lock.Unlock()
 	var my_value int = value
-	lc := strings.ToLower(name)
-	Config[lc] = &Option{Type: "int", ShortOpt: shortopt, ConfigFile: file,
-		Desc: desc, IntValue: &my_value, Source: "Default"}
+	lc := c.optionKey(name)
+	c.checkRegistration(lc, "int", shortopt)
+	option := &Option{Name: name, Type: "int", ShortOpt: shortopt, Sources: fileSources(file),
+		Desc: desc, IntValue: &my_value, Default: value, Source: "Default"}
+	recordAssignment(option, 0)
+	c.Config[lc] = option
 }
 
 /*****************************************************************************\
   Retrieve an option value of type int.
 \*****************************************************************************/
 
-func GetIntOpt(name string) (value int, err error) {
-	lc := strings.ToLower(name)
-	option, ok := Config[lc]
+func (c *Configurator) GetIntOpt(name string) (value int, err error) {
+	c.noteRead(name)
+	if value, frozen, err := frozenGet[int](c, name, "GetIntOpt"); frozen {
+		return value, err
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
 	if !ok {
 		return value, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
 	}
@@ -604,20 +1068,31 @@ func GetIntOpt(name string) (value int, err error) {
   Define an option of type uint.
 \*****************************************************************************/
 
-func SetUintOpt(name string, shortopt string, file bool, value uint, desc string) {
+func (c *Configurator) SetUintOpt(name string, shortopt string, file bool, value uint, desc string) {
+	c.writeLock()
+	defer c.lock.Unlock()
 	var my_value uint = value
-	lc := strings.ToLower(name)
-	Config[lc] = &Option{Type: "uint", ShortOpt: shortopt, ConfigFile: file,
-		Desc: desc, UintValue: &my_value, Source: "Default"}
+	lc := c.optionKey(name)
+	c.checkRegistration(lc, "uint", shortopt)
+	option := &Option{Name: name, Type: "uint", ShortOpt: shortopt, Sources: fileSources(file),
+		Desc: desc, UintValue: &my_value, Default: value, Source: "Default"}
+	recordAssignment(option, 0)
+	c.Config[lc] = option
 }
 
 /*****************************************************************************\
   Retrieve an option value of type uint.
 \*****************************************************************************/
 
-func GetUintOpt(name string) (value uint, err error) {
-	lc := strings.ToLower(name)
-	option, ok := Config[lc]
+func (c *Configurator) GetUintOpt(name string) (value uint, err error) {
+	c.noteRead(name)
+	if value, frozen, err := frozenGet[uint](c, name, "GetUintOpt"); frozen {
+		return value, err
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
 	if !ok {
 		return value, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
 	}
@@ -628,54 +1103,390 @@ func GetUintOpt(name string) (value uint, err error) {
 	return *option.UintValue, nil
 }
 
+/*****************************************************************************\
+  Retrieve an option value of any type, i.e.: GetOpt[int]("Port").
+\*****************************************************************************/
+
+func GetOpt[T any](name string) (T, error) {
+	return GetOptFrom[T](std, name)
+}
+
+func GetOptFrom[T any](c *Configurator, name string) (value T, err error) {
+	option_value, found, _ := c.Lookup(name)
+	if !found {
+		return value, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	value, ok := option_value.(T)
+	if !ok {
+		return value, Error("GetOpt: bad call for %T option \"%s\".", option_value, name)
+	}
+	return value, nil
+}
+
+/*****************************************************************************\
+  Retrieve an option value for the package's own use, as GetOpt does, but
+  without counting it as read (see OptionUsage): the program may not define
+  the option.
+\*****************************************************************************/
+
+func pkgOpt[T any](name string) (value T, err error) {
+	option_value, found, _ := std.lookup(name)
+	if !found {
+		return value, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	value, ok := option_value.(T)
+	if !ok {
+		return value, Error("pkgOpt: bad call for %T option \"%s\".", option_value, name)
+	}
+	return value, nil
+}
+
+/*****************************************************************************\
+  Look up an option, returning its value, whether it exists, and its Source.
+\*****************************************************************************/
+
+func (c *Configurator) Lookup(name string) (value interface{}, found bool, source string) {
+	c.noteRead(name)
+	return c.lookup(name)
+}
+
+// Look up an option as Lookup does, without counting it as read.
+func (c *Configurator) lookup(name string) (value interface{}, found bool, source string) {
+	if snapshot := c.getSnapshot(); snapshot != nil {
+		option, ok := (*snapshot)[c.optionKey(name)]
+		return option.Value, ok, option.Source
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
+	if !ok {
+		return nil, false, ""
+	}
+	return optionValue(option), true, option.Source
+}
+
+/*****************************************************************************\
+  Check if an option was explicitly set, by a config file, the command line
+  or the program, rather than left at its default.
+\*****************************************************************************/
+
+func (c *Configurator) IsSet(name string) bool {
+	return c.SetBy(name) != ""
+}
+
+/*****************************************************************************\
+  Return the Source which explicitly set an option (i.e. "CommandLine" or
+  "file:/etc/opt/ibapi/ibapi.conf"), or "" if it is at its default.
+\*****************************************************************************/
+
+func (c *Configurator) SetBy(name string) string {
+	_, found, source := c.Lookup(name)
+	if !found || isDefaultSource(source) {
+		return ""
+	}
+	return source
+}
+
+/*****************************************************************************\
+  Change the default value of an option already defined, i.e. to change the
+  defaults of the standard options defined by PackageInit.  Call before
+  ConfigureOptions.  The value must be of the option's type.
+\*****************************************************************************/
+
+func (c *Configurator) SetDefault(name string, value interface{}) error {
+	c.writeLock()
+	defer c.lock.Unlock()
+
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
+	if !ok {
+		return Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	if !setTypedValue(option, value) {
+		return Error("SetDefault: bad %T value for %s option \"%s\".", value, option.Type, name)
+	}
+	option.Default = value
+	option.Source = "Default"
+	option.History = nil
+	recordAssignment(option, 0)
+	return nil
+}
+
+/*****************************************************************************\
+  Return a scratch option of the specified type, for checking or converting
+  values without setting any option.
+\*****************************************************************************/
+
+func scratchOption(option_type string) *Option {
+	return &Option{Type: option_type, StringValue: new(string), BoolValue: new(bool),
+		IntValue: new(int), UintValue: new(uint), ListValue: new([]string)}
+}
+
+/*****************************************************************************\
+  Set the value of an option from a value of the option's type.  Return
+  false if the value is not of the option's type.
+\*****************************************************************************/
+
+func setTypedValue(option *Option, value interface{}) (ok bool) {
+	switch typed := value.(type) {
+	case string:
+		if ok = option.Type == "string"; ok {
+			*option.StringValue = typed
+		}
+	case bool:
+		if ok = option.Type == "bool"; ok {
+			*option.BoolValue = typed
+		}
+	case int:
+		if ok = option.Type == "int"; ok {
+			*option.IntValue = typed
+		}
+	case uint:
+		if ok = option.Type == "uint"; ok {
+			*option.UintValue = typed
+		}
+	case []string:
+		if ok = option.Type == "list"; ok {
+			*option.ListValue = append([]string(nil), typed...)
+		}
+	}
+	return ok
+}
+
+/*****************************************************************************\
+  Set the value of an option at runtime (i.e. a derived value, or a test
+  fixture), labeling its Source as specified (i.e. "Derived").  The value
+  must be of the option's type, or a string, which is parsed as if read from
+  a config file.
+\*****************************************************************************/
+
+func (c *Configurator) SetOptValue(name string, value interface{}, source string) error {
+	c.writeLock()
+	defer c.lock.Unlock()
+
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
+	if !ok {
+		return Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	} else if option.Final {
+		return Error("SetOptValue: option \"%s\" is final, set by %s.", name, option.Source)
+	} else if option.derive != nil {
+		return Error("SetOptValue: option \"%s\" is derived.", name)
+	}
+	if string_value, is_string := value.(string); is_string && option.Type != "string" {
+		if err := setOptionValue(option, lc, string_value); err != nil {
+			return err
+		}
+	} else if !setTypedValue(option, value) {
+		return Error("SetOptValue: bad %T value for %s option \"%s\".", value, option.Type, name)
+	}
+	option.Source = source
+	recordAssignment(option, 0)
+	return nil
+}
+
+/*****************************************************************************\
+  Retrieve option values, exiting with a programming error if the option is
+  not registered as the expected type.  For options registered by the program
+  itself, which cannot fail to exist unless the program is buggy.
+\*****************************************************************************/
+
+func MustGetStringOpt(name string) string {
+	return MustGetOpt[string](name)
+}
+
+func MustGetBoolOpt(name string) bool {
+	return MustGetOpt[bool](name)
+}
+
+func MustGetIntOpt(name string) int {
+	return MustGetOpt[int](name)
+}
+
+func MustGetUintOpt(name string) uint {
+	return MustGetOpt[uint](name)
+}
+
+func MustGetListOpt(name string) []string {
+	return MustGetOpt[[]string](name)
+}
+
+func MustGetOpt[T any](name string) T {
+	value, err := GetOpt[T](name)
+	if err != nil {
+		Fatal("programming error: option \"%s\" not registered as %T", name, value)
+	}
+	return value
+}
+
+/*****************************************************************************\
+  Return the current value of an option.
+\*****************************************************************************/
+
+func optionValue(option *Option) interface{} {
+	switch option.Type {
+	case "string":
+		return *option.StringValue
+	case "int":
+		return *option.IntValue
+	case "uint":
+		return *option.UintValue
+	case "bool":
+		return *option.BoolValue
+	case "list":
+		return append([]string(nil), *option.ListValue...)
+	}
+	return nil
+}
+
 /*****************************************************************************\
   Print out our configuration settings and values.
 \*****************************************************************************/
 
-func ShowConfig() {
-	var format, showname string
-	if Debug {
-		json_data, _ := json.MarshalIndent(Config, "", " ")
+func (c *Configurator) ShowConfig() {
+	c.showConfig(false)
+}
+
+/*****************************************************************************\
+  Print out only the configuration settings whose values differ from their
+  defaults, along with where they were set.
+\*****************************************************************************/
+
+func (c *Configurator) ShowChangedConfig() {
+	c.showConfig(true)
+}
+
+/*****************************************************************************\
+  Check if the value of an option differs from its default.
+\*****************************************************************************/
+
+func OptionChanged(option *Option) bool {
+	return !valuesEqual(optionValue(option), option.Default)
+}
+
+func (c *Configurator) showConfig(changed_only bool) {
+	var showname, source string
+	if JSONOutput() {
+		options := []OptionInfo{}
+		c.EachOption(func(info OptionInfo) bool {
+			if !changed_only || !valuesEqual(info.Value, info.Default) {
+				options = append(options, info)
+			}
+			return true
+		})
+		showJSON(options)
+	} else if Debug {
+		options := c.Config
+		if changed_only {
+			options = make(Options)
+			for name, option := range c.Config {
+				if OptionChanged(option) {
+					options[name] = option
+				}
+			}
+		}
+		json_data, _ := json.MarshalIndent(options, "", " ")
 		Println("Configuration Details:\n%s\n", json_data)
 	} else {
-		format = "  %-20s "
-		Println("Configurations Settings:")
+		table := NewTable()
+		table.Indent = "  "
+		if changed_only {
+			Println("Changed Configuration Settings:")
+		} else {
+			Println("Configurations Settings:")
+		}
 		// Let's sort the options by name
-		sorted_keys := make([]string, 0, len(Config))
-		for name := range Config {
+		sorted_keys := make([]string, 0, len(c.Config))
+		for name := range c.Config {
 			sorted_keys = append(sorted_keys, name)
 		}
 		sort.Strings(sorted_keys)
 		for _, name := range sorted_keys {
-			option := Config[name]
+			option := c.Config[name]
+			if changed_only && !OptionChanged(option) {
+				continue
+			}
 			if option.ShortOpt == "" {
-				showname = name
+				showname = option.Name
 			} else {
-				showname = name + " (-" + option.ShortOpt + ")"
+				showname = option.Name + " (-" + option.ShortOpt + ")"
+			}
+			source = option.Source
+			if changed_only {
+				source += "; default: " + formatDefault(option)
 			}
 			switch option.Type {
 			case "string":
-				if len(*option.StringValue+option.Source) > 60 {
-					Println(format+" \"%s\"", showname, *option.StringValue)
-					Println(format+" (%s)", " ", option.Source)
-				} else {
-					Println(format+" \"%s\"  (%s)", showname, *option.StringValue, option.Source)
-				}
+				table.Add(showname, "\""+*option.StringValue+"\"", "("+source+")")
 			case "int":
-				Println(format+" %d  (%s)", showname, *option.IntValue, option.Source)
+				table.Add(showname, *option.IntValue, "("+source+")")
 			case "uint":
-				Println(format+" %d  (%s)", showname, *option.UintValue, option.Source)
+				table.Add(showname, *option.UintValue, "("+source+")")
 			case "bool":
-				Println(format+" %v  (%s)", showname, *option.BoolValue, option.Source)
+				table.Add(showname, *option.BoolValue, "("+source+")")
+			case "list":
+				source = strings.Replace(source, option.Source, valueSources(option), 1)
+				table.Add(showname, "\""+formatValue(*option.ListValue)+"\"", "("+source+")")
 			}
 		}
+		table.Write()
 	}
 }
 
-func ShowVersion() {
-	Println("Version info for %s:", ProgramName)
-	Println("  PkgName: %s", PkgName)
-	Println("  PkgVersion: %s", PkgVersion)
-	Println("  PackageEtc: %s", PackageEtc)
-	Println("  LocalEtc: %s", LocalEtc)
+/*****************************************************************************\
+  Show the version info: the package's, then that of the Go modules and
+  external commands the program depends on (see SetVersionCommand).
+\*****************************************************************************/
+
+func (c *Configurator) ShowVersion() {
+	if JSONOutput() {
+		showJSON(struct {
+			Program    string
+			PkgName    string
+			PkgVersion string
+			PackageEtc string
+			LocalEtc   string
+			Modules    []Dependency
+			Commands   []Dependency
+		}{c.ProgramName, c.PkgName, c.PkgVersion, c.PackageEtc, c.LocalEtc,
+			ModuleDependencies(), CommandDependencies()})
+		return
+	}
+	Println("Version info for %s:", c.ProgramName)
+	Println("  PkgName: %s", c.PkgName)
+	Println("  PkgVersion: %s", c.PkgVersion)
+	Println("  PackageEtc: %s", c.PackageEtc)
+	Println("  LocalEtc: %s", c.LocalEtc)
+	if modules := ModuleDependencies(); len(modules) > 0 {
+		Println("Built with:")
+		for _, dep := range modules {
+			Println("  %s %s", dep.Name, dep.Version)
+		}
+	}
+	if commands := CommandDependencies(); len(commands) > 0 {
+		Println("Commands:")
+		for _, dep := range commands {
+			Println("  %s: %s", dep.Name, strings.TrimSpace(dep.Path+" "+dep.Version))
+		}
+	}
+}
+
+/*****************************************************************************\
+  Check if JSON output was requested (OutputFormat=json), in which case the
+  standard Help, Version and ShowConfig modes show JSON documents.
+\*****************************************************************************/
+
+func JSONOutput() bool {
+	format, _ := pkgOpt[string]("OutputFormat")
+	return strings.EqualFold(format, "json")
+}
+
+func showJSON(document interface{}) {
+	json_data, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		Warn("Failure encoding JSON output: %v", err)
+		return
+	}
+	Println("%s", json_data)
 }
@@ -0,0 +1,599 @@
+package sitepkg
+
+/*****************************************************************************\
+  The Configurator holds the configuration state of a program: its package
+  metadata, option registry, config dirs and command line flag set.  Most
+  programs need only the default Configurator, used by the package level
+  functions (SetStringOpt, ConfigureOptions, etc), which are thin wrappers
+  around its methods.  Additional Configurators, created by NewConfigurator,
+  let one process host multiple tools, and let tests run in parallel.
+
+  The package level variables (PkgName, Config, ConfigDirs, etc) mirror the
+  state of the default Configurator; programs may still set ProgramName,
+  ConfigDirs, etc, after PackageInit, as ConfigureOptions reads them back.
+  Option renames and config migrations are per Configurator.  The output
+  state (writers, routes, log destinations and file, mail, warnings), the
+  cleanups, the command context and detections are per process, shared by
+  all the Configurators.
+\*****************************************************************************/
+
+import (
+	"context"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+)
+
+type Configurator struct {
+	PkgName         string
+	PkgVersion      string
+	Package         string
+	PackageDir      string
+	PackageEtc      string
+	LocalEtc        string
+	ProgramName     string
+	Config          Options
+	ConfigDirs      []string
+	ConfigFilesRead []string
+	FlagSet         *pflag.FlagSet
+	Tenant          string
+	CaseSensitive   bool
+	Trace           bool
+	Root            string
+	lock            sync.RWMutex
+	frozen          freezeState
+	usage           optionUsage
+	commandDefaults map[string][]commandDefault
+
+	conditionalDefaults []conditionalDefault
+	commands            map[string]*Command
+	aliases             map[string]string
+	invoked             string
+	plugin              string
+	pathSet             bool
+	constraints         []constraint
+	layers              []string
+	noIntersperse       bool
+	singleDash          bool
+	commandOptions      map[string]bool
+	optionRenames       map[string]rename
+	sectionRenames      map[string]rename
+	renameWarned        map[string]bool
+	migrations          []configMigration
+	schemaVersion       int
+}
+
+var std = &Configurator{Config: make(Options), FlagSet: pflag.CommandLine}
+
+var Config = std.Config
+var ConfigDirs []string
+var ConfigFilesRead []string
+
+/*****************************************************************************\
+  Create a new Configurator for the specified package.  Unlike the default
+  Configurator, its flag set returns command line errors rather than exiting.
+\*****************************************************************************/
+
+func NewConfigurator(pkg_name string, pkg_version string) *Configurator {
+	c := &Configurator{Config: make(Options)}
+	c.setPackage(pkg_name, pkg_version)
+	c.FlagSet = pflag.NewFlagSet(c.ProgramName, pflag.ContinueOnError)
+	return c
+}
+
+/*****************************************************************************\
+  Return the default Configurator.
+\*****************************************************************************/
+
+func DefaultConfigurator() *Configurator {
+	return std
+}
+
+/*****************************************************************************\
+  Set the package metadata.
+\*****************************************************************************/
+
+func (c *Configurator) setPackage(pkg_name string, pkg_version string) {
+	c.PkgName = pkg_name
+	c.PkgVersion = pkg_version
+	c.Package = c.PkgName + "-" + c.PkgVersion
+	c.PackageDir = c.RootPath("/usr/site/" + c.Package)
+	c.PackageEtc = c.PackageDir + "/etc"
+	c.LocalEtc = c.RootPath("/etc/opt/" + c.PkgName)
+	c.ProgramName = path.Base(os.Args[0])
+}
+
+/*****************************************************************************\
+  Set the list of directories searched for config (and other package) files,
+  from lowest priority to highest.
+\*****************************************************************************/
+
+func (c *Configurator) setConfigDirs() {
+	if c.ConfigDirs != nil {
+		return
+	}
+	c.ConfigDirs = []string{c.PackageEtc, c.LocalEtc, c.LocalEtc + "-" + c.PkgVersion}
+	if home, err := os.UserHomeDir(); err != nil {
+		Warn("Failure getting home dir: %v", err)
+	} else {
+		c.ConfigDirs = append(c.ConfigDirs, home+"/."+c.PkgName, home+"/."+c.Package)
+	}
+}
+
+/*****************************************************************************\
+  Read in options from any AND ALL config files found, then from the
+  environment (for options allowing it; see SetOptSources), then parse the
+  specified command line arguments (not including the program name) for any
+  overrides, or as reordered by SetConfigLayers.  Return the remaining,
+  non-option, arguments.
+\*****************************************************************************/
+
+func (c *Configurator) ConfigureOptions(args []string) ([]string, error) {
+
+	args = c.findCommand(c.normalizeArgs(c.resolveTenantAliases(args), ""))
+	args = c.normalizeArgs(args, c.invoked)
+	option_args := c.optionArgs(args)
+	c.preScanRoot(option_args)
+	c.setConfigDirs()
+
+	// The tenant determines which config file sections apply, so get it now.
+	if _, ok := c.Config[c.optionKey("Tenant")]; ok {
+		tenant := preScanOption(option_args, "Tenant")
+		if tenant == "" {
+			tenant = c.passedOption("Tenant")
+		}
+		if err := checkTenant(tenant); err != nil {
+			return args, err
+		}
+		c.Tenant = tenant
+	}
+	if _, ok := c.Config[c.optionKey("TraceConfig")]; ok && preScanFlag(option_args, "TraceConfig") {
+		c.Trace = true
+	}
+
+	err := c.readLayers(func() (err error) {
+		args, err = c.ProcessCommandLine(args)
+		return err
+	})
+	return args, err
+}
+
+/*****************************************************************************\
+  Apply the conditional and per command defaults, read each of the config
+  source layers in turn, calling command_line for the command line layer,
+  then compute the derived options and check the constraints.
+\*****************************************************************************/
+
+func (c *Configurator) readLayers(command_line func() error) (err error) {
+
+	c.applyConditionalDefaults()
+	c.applyCommandDefaults()
+	layers := c.ConfigLayers()
+	c.trace("config layers: %s", strings.Join(layers, ", "))
+	for _, layer := range layers {
+		switch layer {
+		case LayerSystem, LayerUser:
+			configFiles, err := c.layerFiles(layer)
+			if err != nil {
+				return WrapError(ConfigError, err)
+			}
+			for _, config_file := range configFiles {
+				if err := c.ReadConfigFile(config_file); err != nil {
+					return CategoryError(ConfigError, "%s!", err)
+				}
+				c.ConfigFilesRead = append(c.ConfigFilesRead, config_file)
+			}
+		case LayerEnv:
+			if err = c.readEnvironment(); err != nil {
+				return WrapError(ConfigError, err)
+			}
+		case LayerCommandLine:
+			if err = command_line(); err != nil {
+				return WrapError(UsageError, err)
+			}
+		}
+	}
+	if err = c.deriveOptions(); err != nil {
+		return err
+	}
+	return c.CheckConstraints()
+}
+
+/*****************************************************************************\
+  Return the config files which exist for the program, in the order read:
+  those of each enabled file layer (see SetConfigLayers), in turn.
+\*****************************************************************************/
+
+func (c *Configurator) configFiles() (config_files []string, err error) {
+	for _, layer := range c.ConfigLayers() {
+		if layer != LayerSystem && layer != LayerUser {
+			continue
+		}
+		layer_files, err := c.layerFiles(layer)
+		if err != nil {
+			return nil, err
+		}
+		config_files = append(config_files, layer_files...)
+	}
+	return config_files, nil
+}
+
+/*****************************************************************************\
+  Return the config files which exist for the program in the config dirs of
+  the specified layer, in the order read: the package's file, then those for
+  each of the command paths, each from each of the config dirs.
+\*****************************************************************************/
+
+func (c *Configurator) layerFiles(layer string) (config_files []string, err error) {
+
+	var filenames, commandPaths []string
+
+	if c.PkgName != c.ProgramName {
+		filenames = append(filenames, c.PkgName+".conf")
+	}
+	if commandPaths = c.GetCommandPaths(); len(commandPaths) == 0 {
+		return nil, Error("bug: failure getting command paths")
+	}
+	for _, p := range commandPaths {
+		filenames = append(filenames, p+".conf")
+	}
+
+	for _, filename := range filenames {
+		for _, pathname := range c.ConfigDirs {
+			if c.configDirLayer(pathname) != layer {
+				continue
+			}
+			config_file := pathname + "/" + filename
+			if _, err := os.Stat(config_file); err == nil {
+				c.trace("%s: found", config_file)
+				config_files = append(config_files, config_file)
+			} else if !os.IsNotExist(err) {
+				return nil, Error("Error stat'ing config file %s: %s", config_file, err)
+			} else {
+				c.trace("%s: not found", config_file)
+			}
+		}
+	}
+	return config_files, nil
+}
+
+/*****************************************************************************\
+  Return the key under which an option is stored: its name in lower case,
+  unless in case sensitive mode.
+\*****************************************************************************/
+
+func (c *Configurator) optionKey(name string) string {
+	if c.CaseSensitive {
+		return name
+	}
+	return strings.ToLower(name)
+}
+
+/*****************************************************************************\
+  Make option names case sensitive, on the command line and in config files,
+  for sites with legacy configs using distinct CamelCase names.  Call before
+  PackageInit.
+\*****************************************************************************/
+
+func SetCaseSensitive(case_sensitive bool) {
+	std.CaseSensitive = case_sensitive
+}
+
+// The package level variables as last updated by syncGlobals.
+var syncedGlobals map[*string]string
+var syncedConfigDirs []string
+
+/*****************************************************************************\
+  Update the package level variables from the default Configurator, after
+  updating it from those the program changed since (i.e. ProgramName or
+  ConfigDirs, set after PackageInit).
+\*****************************************************************************/
+
+func syncGlobals() {
+	globals := map[*string]*string{
+		&PkgName:     &std.PkgName,
+		&PkgVersion:  &std.PkgVersion,
+		&Package:     &std.Package,
+		&PackageDir:  &std.PackageDir,
+		&PackageEtc:  &std.PackageEtc,
+		&LocalEtc:    &std.LocalEtc,
+		&ProgramName: &std.ProgramName,
+		&Tenant:      &std.Tenant,
+	}
+	if syncedGlobals == nil {
+		syncedGlobals = make(map[*string]string)
+	}
+	for global, field := range globals {
+		if synced, ok := syncedGlobals[global]; ok && *global != synced {
+			*field = *global
+		}
+		*global = *field
+		syncedGlobals[global] = *field
+	}
+	if !valuesEqual(ConfigDirs, syncedConfigDirs) {
+		std.ConfigDirs = ConfigDirs
+	}
+	ConfigDirs = std.ConfigDirs
+	syncedConfigDirs = ConfigDirs
+	Config = std.Config
+	ConfigFilesRead = std.ConfigFilesRead
+}
+
+/*****************************************************************************\
+  Package level wrappers for the methods of the default Configurator.
+\*****************************************************************************/
+
+func ReadConfigFile(config_file string) error {
+	return std.ReadConfigFile(config_file)
+}
+
+func ProcessCommandLine() ([]string, error) {
+	return std.ProcessCommandLine(os.Args[1:])
+}
+
+func SetStringOpt(name string, shortopt string, file bool, value string, desc string) {
+	std.SetStringOpt(name, shortopt, file, value, desc)
+}
+
+func GetStringOpt(name string) (string, error) {
+	return std.GetStringOpt(name)
+}
+
+func SetBoolOpt(name string, shortopt string, file bool, value bool, desc string) {
+	std.SetBoolOpt(name, shortopt, file, value, desc)
+}
+
+func GetBoolOpt(name string) (bool, error) {
+	return std.GetBoolOpt(name)
+}
+
+func SetIntOpt(name string, shortopt string, file bool, value int, desc string) {
+	std.SetIntOpt(name, shortopt, file, value, desc)
+}
+
+func GetIntOpt(name string) (int, error) {
+	return std.GetIntOpt(name)
+}
+
+func SetUintOpt(name string, shortopt string, file bool, value uint, desc string) {
+	std.SetUintOpt(name, shortopt, file, value, desc)
+}
+
+func GetUintOpt(name string) (uint, error) {
+	return std.GetUintOpt(name)
+}
+
+func SetListOpt(name string, shortopt string, file bool, value []string, desc string) {
+	std.SetListOpt(name, shortopt, file, value, desc)
+}
+
+func GetListOpt(name string) ([]string, error) {
+	return std.GetListOpt(name)
+}
+
+func SetInterspersed(interspersed bool) {
+	std.SetInterspersed(interspersed)
+}
+
+func SetSingleDashLongOptions(accept bool) {
+	std.SetSingleDashLongOptions(accept)
+}
+
+func ArgsLenAtDash() int {
+	return std.ArgsLenAtDash()
+}
+
+func SetConfigLayers(layers ...string) error {
+	return std.SetConfigLayers(layers...)
+}
+
+func ConfigLayers() []string {
+	return std.ConfigLayers()
+}
+
+func SetConstraint(expr string, msg string) {
+	std.SetConstraint(expr, msg)
+}
+
+func CheckConstraints() error {
+	return std.CheckConstraints()
+}
+
+func SetDerivedOpt(name string, option_type string, desc string, fn func() (interface{}, error)) {
+	std.SetDerivedOpt(name, option_type, desc, fn)
+}
+
+func SetDefault(name string, value interface{}) error {
+	return std.SetDefault(name, value)
+}
+
+func SetOptValue(name string, value interface{}, source string) error {
+	return std.SetOptValue(name, value, source)
+}
+
+func Lookup(name string) (interface{}, bool, string) {
+	return std.Lookup(name)
+}
+
+func IsSet(name string) bool {
+	return std.IsSet(name)
+}
+
+func SetBy(name string) string {
+	return std.SetBy(name)
+}
+
+func OptionHistory(name string) ([]Assignment, error) {
+	return std.OptionHistory(name)
+}
+
+func SetOptSources(name string, sources OptionSource) error {
+	return std.SetOptSources(name, sources)
+}
+
+func SetOptNoValue(name string, value string) error {
+	return std.SetOptNoValue(name, value)
+}
+
+func RenameOption(old_name string, new_name string, version string) {
+	std.RenameOption(old_name, new_name, version)
+}
+
+func RenameSection(old_name string, new_name string, version string) {
+	std.RenameSection(old_name, new_name, version)
+}
+
+func RegisterMigration(version int, desc string, transform ConfigTransform) {
+	std.RegisterMigration(version, desc, transform)
+}
+
+func SetConfigSchemaVersion(version int) {
+	std.SetConfigSchemaVersion(version)
+}
+
+func ConfigSchemaVersion() int {
+	return std.ConfigSchemaVersion()
+}
+
+func MigrateConfigFile(config_file string) (int, error) {
+	return std.MigrateConfigFile(config_file)
+}
+
+func EnvVarName(name string) string {
+	return std.EnvVarName(name)
+}
+
+func Freeze() {
+	std.Freeze()
+}
+
+func Reload(fn func() error) error {
+	return std.Reload(fn)
+}
+
+func SetOptDefaultForCommand(command string, name string, value interface{}) error {
+	return std.SetOptDefaultForCommand(command, name, value)
+}
+
+func SetOptDefaultWhen(detector string, name string, value interface{}) error {
+	return std.SetOptDefaultWhen(detector, name, value)
+}
+
+func GetSubtree(prefix string) map[string]interface{} {
+	return std.GetSubtree(prefix)
+}
+
+func OptionNames() []string {
+	return std.OptionNames()
+}
+
+func GetOptionInfo(name string) (OptionInfo, error) {
+	return std.OptionInfo(name)
+}
+
+func EachOption(fn func(info OptionInfo) bool) {
+	std.EachOption(fn)
+}
+
+func ShowConfig() {
+	std.ShowConfig()
+}
+
+func ShowChangedConfig() {
+	std.ShowChangedConfig()
+}
+
+func ShowVersion() {
+	std.ShowVersion()
+}
+
+func SetRoot(root string) {
+	std.SetRoot(root)
+	syncGlobals()
+}
+
+func RootPath(path string) string {
+	return std.RootPath(path)
+}
+
+func FindPackageFile(filename string) (string, error) {
+	return std.FindPackageFile(filename)
+}
+
+func GetCommandPaths() []string {
+	return std.GetCommandPaths()
+}
+
+func AddCommand(path string, run func(args []string) error, desc string) *Command {
+	return std.AddCommand(path, run, desc)
+}
+
+func AddCommandAlias(alias string, path string) {
+	std.AddCommandAlias(alias, path)
+}
+
+func Subcommands(path string) []*Command {
+	return std.Subcommands(path)
+}
+
+func SetCommandPath(path string) {
+	std.SetCommandPath(path)
+}
+
+func InvokedCommand() *Command {
+	return std.InvokedCommand()
+}
+
+func RunCommand(args []string) error {
+	return std.RunCommand(args)
+}
+
+/*****************************************************************************\
+  Use the specified flag set, rather than pflag.CommandLine, for processing
+  the command line with the default Configurator.  Options already defined as
+  flags in the previous flag set are not carried over.
+\*****************************************************************************/
+
+func SetFlagSet(flag_set *pflag.FlagSet) {
+	std.lock.Lock()
+	defer std.lock.Unlock()
+	std.FlagSet = flag_set
+}
+
+/*****************************************************************************\
+  Reset the default Configurator, and the rest of the package state, to its
+  state before PackageInit: no options, config dirs or files, and a fresh
+  pflag.CommandLine; no cleanups, config migrations or renames, and the
+  output reset (see resetOutput).  Intended for tests, which may then call
+  PackageInit, register options and call ConfigureOptions again.
+
+  What survives: the Default writers as set by the program (see
+  resetOutput), the signal handler (which runs the cleanups registered
+  since), and the cached host name and stdin reader.
+\*****************************************************************************/
+
+func ResetConfig() {
+	pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+	std = &Configurator{Config: make(Options), FlagSet: pflag.CommandLine}
+	syncedGlobals, syncedConfigDirs = nil, nil
+	PkgName, PkgVersion, Package, PackageDir, PackageEtc, LocalEtc, ProgramName, Tenant = "", "", "", "", "", "", "", ""
+	ConfigDirs = nil
+	syncGlobals()
+	SetLogLevel(LevelWarn)
+	setCommandParent(context.Background())
+	cleanups = nil
+	exitStatus = 0
+	completions = make(map[string]*optionCompletion)
+	secretAccounts = nil
+	optionsConfigured = false
+	versionCommands = nil
+	selfTests = nil
+	breakers = make(map[string]*CircuitBreaker)
+	detected = make(map[string]bool)
+	historyOptions = nil
+	PodMap = make(map[string]string)
+	resetOutput()
+}
@@ -0,0 +1,235 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for writing configuration files.  Existing files are updated
+  "losslessly": comments, blank lines, ordering and unrelated settings and
+  sections are all preserved, and only the assignments being changed are
+  touched.
+\*****************************************************************************/
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var configCommentRegexp = regexp.MustCompile("[ \t]+#.*$")
+
+/*****************************************************************************\
+  Split the value of an assignment (the text following the "=") from any
+  trailing comment.  A double quoted value may contain " #": a comment may
+  only follow its closing quote (and any "!final").
+\*****************************************************************************/
+
+func splitConfigComment(text string) (value string, comment string) {
+	trimmed := strings.TrimLeft(text, " \t")
+	if quoted, err := strconv.QuotedPrefix(trimmed); err == nil && strings.HasPrefix(trimmed, `"`) {
+		rest := text[len(text)-len(trimmed)+len(quoted):]
+		comment = configCommentRegexp.FindString(rest)
+		if tail := strings.TrimSpace(strings.TrimSuffix(rest, comment)); tail == "" || tail == ConfFinalSuffix {
+			return strings.TrimSuffix(text, comment), comment
+		}
+	}
+	comment = configCommentRegexp.FindString(text)
+	return strings.TrimSuffix(text, comment), comment
+}
+
+/*****************************************************************************\
+  Quote a value, as a Go string, if it would not otherwise be read back from
+  a config file as is: if it contains a " #" (read as a comment) or a line
+  break, has leading or trailing blanks, starts with a quote or ends in
+  "!final".
+\*****************************************************************************/
+
+func quoteConfigValue(value string) string {
+	if configCommentRegexp.MatchString(value) || strings.ContainsAny(value, "\r\n") ||
+		strings.TrimSpace(value) != value || strings.HasPrefix(value, `"`) ||
+		strings.HasSuffix(value, ConfFinalSuffix) {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+/*****************************************************************************\
+  Update the specified config file, setting the specified options (name to
+  value) within the specified section ("" for the top of the file, before any
+  section).  Existing assignments are replaced in place, keeping any trailing
+  comment; new assignments are added at the end of the section, and the
+  section is created if need be.  The file and its directory are created if
+  they do not exist.
+\*****************************************************************************/
+
+func UpdateConfigFile(config_file string, section string, values map[string]string) error {
+
+	var lines, output []string
+	var current string
+	var mode os.FileMode = 0644
+
+	if config_file == "" {
+		return Error("Bad call: filename not defined.")
+	}
+	if info, err := os.Stat(config_file); err == nil {
+		mode = info.Mode().Perm()
+		data, err := os.ReadFile(config_file)
+		if err != nil {
+			return Error("Error reading config file \"%s\": %v", config_file, err)
+		}
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		if len(data) == 0 {
+			lines = nil
+		}
+	} else if !os.IsNotExist(err) {
+		return Error("Error stat'ing config file %s: %s", config_file, err)
+	}
+
+	// The assignments pending, by option key, and the names to write them as.
+	pending := make(map[string]string)
+	names := make(map[string]string)
+	for name, value := range values {
+		pending[std.optionKey(name)] = value
+		names[std.optionKey(name)] = name
+	}
+	assignments := func() []string {
+		named := make(map[string]string)
+		for key, value := range pending {
+			named[names[key]] = value
+		}
+		return formatAssignments(named)
+	}
+
+	// Add any pending assignments to the end of the section just finished,
+	// ahead of any trailing blank lines.
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		end := len(output)
+		for end > 0 && strings.TrimSpace(output[end-1]) == "" {
+			end--
+		}
+		trailing := append([]string{}, output[end:]...)
+		output = append(output[:end], assignments()...)
+		output = append(output, trailing...)
+		pending = make(map[string]string)
+	}
+
+	for _, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "[") {
+			if current == section {
+				flush()
+			}
+			current = strings.TrimSuffix(strings.TrimPrefix(configCommentRegexp.Split(trimmed, 2)[0], "["), "]")
+			output = append(output, line)
+			continue
+		}
+		if current == section && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			if slice := strings.SplitN(line, "=", 2); len(slice) == 2 {
+				name := std.optionKey(strings.TrimSpace(slice[0]))
+				if value, ok := pending[name]; ok {
+					_, comment := splitConfigComment(slice[1])
+					line = strings.TrimRight(slice[0], " \t") + " = " + quoteConfigValue(value) + comment
+					delete(pending, name)
+				}
+			}
+		}
+		output = append(output, line)
+	}
+	if current == section {
+		flush()
+	}
+	if len(pending) > 0 {
+		if len(output) > 0 {
+			output = append(output, "")
+		}
+		output = append(output, "["+section+"]")
+		output = append(output, assignments()...)
+	}
+	return writeFileAtomic(config_file, []byte(strings.Join(output, "\n")+"\n"), mode)
+}
+
+func formatAssignments(values map[string]string) (lines []string) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, name+" = "+quoteConfigValue(values[name]))
+	}
+	return lines
+}
+
+/*****************************************************************************\
+  Write a file via a temporary file and a rename, so that readers never see a
+  partially written file.
+\*****************************************************************************/
+
+func writeFileAtomic(filename string, data []byte, mode os.FileMode) error {
+
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Error("Error creating directory \"%s\": %v", dir, err)
+	}
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return Error("Error creating temporary file in \"%s\": %v", dir, err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err = temp.Write(data); err != nil {
+		temp.Close()
+		return Error("Error writing file \"%s\": %v", temp.Name(), err)
+	} else if err = temp.Chmod(mode); err != nil {
+		temp.Close()
+		return Error("Error setting mode of file \"%s\": %v", temp.Name(), err)
+	} else if err = temp.Close(); err != nil {
+		return Error("Error closing file \"%s\": %v", temp.Name(), err)
+	} else if err = os.Rename(temp.Name(), filename); err != nil {
+		return Error("Error renaming \"%s\" to \"%s\": %v", temp.Name(), filename, err)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Write the current values of the file settable options to the specified
+  config file, replacing the file if it exists.  If only_changed is set, only
+  the options whose values differ from their defaults are written.  If the
+  invoked command has subcommands, the options are written to the section of
+  the final subcommand (i.e.: "[host:add]"), so that the saved settings apply
+  only to that command.
+\*****************************************************************************/
+
+func WriteConfigFile(config_file string, only_changed bool) error {
+
+	var lines []string
+
+	if config_file == "" {
+		return Error("Bad call: filename not defined.")
+	}
+	commandPaths := GetCommandPaths()
+	if len(commandPaths) == 0 {
+		return Error("bug: failure getting command paths")
+	}
+
+	lines = append(lines, "#", "# Settings saved by "+ProgramName+" ("+Package+").", "#")
+	if version := std.ConfigSchemaVersion(); version > 0 {
+		lines = append(lines, ConfVersionHeader+" = "+strconv.Itoa(version))
+	}
+	if len(commandPaths) > 1 {
+		lines = append(lines, "", "["+commandPaths[len(commandPaths)-1]+"]")
+	}
+
+	values := make(map[string]string)
+	for _, option := range Config {
+		if option.Sources&SourceFile == 0 || (only_changed && !OptionChanged(option)) {
+			continue
+		}
+		values[option.Name] = formatValue(optionValue(option))
+	}
+	lines = append(lines, formatAssignments(values)...)
+	return writeFileAtomic(config_file, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
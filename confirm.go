@@ -0,0 +1,35 @@
+package sitepkg
+
+/*****************************************************************************\
+  Confirmation of destructive operations.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*****************************************************************************\
+  Ask the user to confirm an operation: i.e. Confirm("Delete host %s.", host)
+  prompts "Delete host x. Are you sure? [y/N] ".  Return nil if confirmed, or
+  an error if not.  If the --Yes option is set, the operation is confirmed
+  without asking; otherwise, if stdin is not a terminal, it is declined.
+\*****************************************************************************/
+
+func Confirm(format string, a ...interface{}) error {
+	operation := strings.TrimSpace(fmt.Sprintf(format, a...))
+	if yes, _ := pkgOpt[bool]("Yes"); yes {
+		ShowDebug("Confirmed by --Yes: %s", operation)
+		return nil
+	} else if !IsInteractive() {
+		return Error("%s", strings.TrimSpace(operation+" Not confirmed: not interactive (use --Yes to confirm)"))
+	}
+	answer, err := Prompt("%s ", strings.TrimSpace(operation+" Are you sure? [y/N]"))
+	if err != nil {
+		return err
+	}
+	if answer = strings.ToLower(answer); answer == "y" || answer == "yes" {
+		return nil
+	}
+	return Error("%s", strings.TrimSpace(operation+" Not confirmed."))
+}
@@ -0,0 +1,379 @@
+package sitepkg
+
+/*****************************************************************************\
+  Option constraints: declarative cross-option checks, evaluated after the
+  options are configured, so that tools needn't scatter them through main().
+  A constraint is a small expression over option values:
+
+    Server => Port                    Port is required if Server is set
+    Retries <= MaxRetries
+    OutputFormat == "text" || !Color
+    (Add || Delete) && !(Add && Delete)
+
+  Options are referred to by name, and compared (==, !=, <, <=, >, >=) with
+  each other or with numbers, "strings" and true/false.  Used as conditions
+  (with !, &&, || and =>, "implies"), options are true if set to other than
+  the zero value: false, 0, "" or an empty list.  Constraints referring to
+  options not defined (i.e. those of other commands) are not checked.
+\*****************************************************************************/
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type constraint struct {
+	expr string
+	msg  string
+	eval constraintExpr
+}
+
+type constraintExpr func(c *Configurator) (interface{}, error)
+
+var errUndefinedOption = errors.New("undefined option")
+
+/*****************************************************************************\
+  Register a constraint.  If it is not satisfied, ConfigureOptions returns an
+  error with the specified message, or, if msg is "", one quoting the
+  constraint.  A constraint which does not parse is a programming error.
+\*****************************************************************************/
+
+func (c *Configurator) SetConstraint(expr string, msg string) {
+	parser := &constraintParser{tokens: tokenizeConstraint(expr)}
+	eval, err := parser.parse()
+	if err != nil {
+		panic(Error("programming error: bad constraint \"%s\": %v", expr, err))
+	}
+	c.writeLock()
+	defer c.lock.Unlock()
+	c.constraints = append(c.constraints, constraint{expr: expr, msg: msg, eval: eval})
+}
+
+/*****************************************************************************\
+  Check the constraints, returning an error for the first not satisfied.
+\*****************************************************************************/
+
+func (c *Configurator) CheckConstraints() error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for _, entry := range c.constraints {
+		value, err := entry.eval(c)
+		if err == errUndefinedOption {
+			continue
+		} else if err != nil {
+			return Error("Constraint \"%s\": %v", entry.expr, err)
+		} else if !truthy(value) {
+			if entry.msg != "" {
+				return Error("%s", entry.msg)
+			}
+			return Error("Constraint \"%s\" not satisfied", entry.expr)
+		}
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Check if a value is true as a condition: not its type's zero value.
+\*****************************************************************************/
+
+func truthy(value interface{}) bool {
+	switch typed := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return typed
+	case float64:
+		return typed != 0
+	case string:
+		return typed != ""
+	case []string:
+		return len(typed) > 0
+	}
+	return true
+}
+
+/*****************************************************************************\
+  Split a constraint into tokens: names, numbers, quoted strings, operators
+  and parentheses.  A token which is none of these is passed as is, and
+  rejected by the parser.
+\*****************************************************************************/
+
+func tokenizeConstraint(expr string) (tokens []string) {
+	operators := []string{"=>", "==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")"}
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '"' || r == '\'':
+			for i++; i < len(runes) && runes[i] != r; i++ {
+			}
+			i++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-':
+			for i++; i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) ||
+				runes[i] == '_' || runes[i] == '.'); i++ {
+			}
+		default:
+			i++
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[start:]), op) {
+					i = start + len([]rune(op))
+					break
+				}
+			}
+		}
+		if i > len(runes) {
+			i = len(runes)
+		}
+		tokens = append(tokens, string(runes[start:i]))
+	}
+	return tokens
+}
+
+/*****************************************************************************\
+  A recursive descent parser for constraints, returning a function which
+  evaluates the constraint.  In order of increasing precedence:
+    implies := or [ "=>" implies ]
+    or      := and { "||" and }
+    and     := not { "&&" not }
+    not     := "!" not | compare
+    compare := operand [ ("==" | "!=" | "<" | "<=" | ">" | ">=") operand ]
+    operand := name | number | string | true | false | "(" implies ")"
+\*****************************************************************************/
+
+type constraintParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *constraintParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *constraintParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *constraintParser) parse() (constraintExpr, error) {
+	if len(p.tokens) == 0 {
+		return nil, Error("empty constraint")
+	}
+	eval, err := p.parseImplies()
+	if err == nil && p.pos < len(p.tokens) {
+		err = Error("unexpected \"%s\"", p.peek())
+	}
+	return eval, err
+}
+
+func (p *constraintParser) parseImplies() (constraintExpr, error) {
+	left, err := p.parseOr()
+	if err != nil || p.peek() != "=>" {
+		return left, err
+	}
+	p.next()
+	right, err := p.parseImplies()
+	if err != nil {
+		return nil, err
+	}
+	return func(c *Configurator) (interface{}, error) {
+		value, err := left(c)
+		if err != nil || !truthy(value) {
+			return true, err
+		}
+		value, err = right(c)
+		return truthy(value), err
+	}, nil
+}
+
+func (p *constraintParser) parseOr() (constraintExpr, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.peek() == "||" {
+		p.next()
+		var right constraintExpr
+		if right, err = p.parseAnd(); err == nil {
+			left = logicalExpr(left, right, true)
+		}
+	}
+	return left, err
+}
+
+func (p *constraintParser) parseAnd() (constraintExpr, error) {
+	left, err := p.parseNot()
+	for err == nil && p.peek() == "&&" {
+		p.next()
+		var right constraintExpr
+		if right, err = p.parseNot(); err == nil {
+			left = logicalExpr(left, right, false)
+		}
+	}
+	return left, err
+}
+
+// Return the expression "left || right" (or) or "left && right".
+func logicalExpr(left constraintExpr, right constraintExpr, or bool) constraintExpr {
+	return func(c *Configurator) (interface{}, error) {
+		value, err := left(c)
+		if err != nil || truthy(value) == or {
+			return truthy(value), err
+		}
+		value, err = right(c)
+		return truthy(value), err
+	}
+}
+
+func (p *constraintParser) parseNot() (constraintExpr, error) {
+	if p.peek() != "!" {
+		return p.parseCompare()
+	}
+	p.next()
+	operand, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	return func(c *Configurator) (interface{}, error) {
+		value, err := operand(c)
+		return !truthy(value), err
+	}, nil
+}
+
+func (p *constraintParser) parseCompare() (constraintExpr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	op := p.peek()
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	return func(c *Configurator) (interface{}, error) {
+		left_value, err := left(c)
+		if err != nil {
+			return nil, err
+		}
+		right_value, err := right(c)
+		if err != nil {
+			return nil, err
+		}
+		return compareValues(op, left_value, right_value)
+	}, nil
+}
+
+func (p *constraintParser) parseOperand() (constraintExpr, error) {
+	token := p.next()
+	switch {
+	case token == "":
+		return nil, Error("unexpected end of constraint")
+	case token == "(":
+		eval, err := p.parseImplies()
+		if err == nil && p.next() != ")" {
+			err = Error("missing \")\"")
+		}
+		return eval, err
+	case token == "true" || token == "false":
+		value := token == "true"
+		return func(c *Configurator) (interface{}, error) { return value, nil }, nil
+	case strings.HasPrefix(token, "\"") || strings.HasPrefix(token, "'"):
+		if len(token) < 2 || token[len(token)-1] != token[0] {
+			return nil, Error("unterminated string %s", token)
+		}
+		value := token[1 : len(token)-1]
+		return func(c *Configurator) (interface{}, error) { return value, nil }, nil
+	case unicode.IsDigit(rune(token[0])) || token[0] == '-':
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, Error("bad number \"%s\"", token)
+		}
+		return func(c *Configurator) (interface{}, error) { return value, nil }, nil
+	case unicode.IsLetter(rune(token[0])) || token[0] == '_':
+		name := token
+		return func(c *Configurator) (interface{}, error) {
+			option, ok := c.Config[c.optionKey(name)]
+			if !ok {
+				return nil, errUndefinedOption
+			}
+			return constraintValue(optionValue(option)), nil
+		}, nil
+	}
+	return nil, Error("unexpected \"%s\"", token)
+}
+
+/*****************************************************************************\
+  Return an option value for comparison: numbers as float64.
+\*****************************************************************************/
+
+func constraintValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case int:
+		return float64(typed)
+	case uint:
+		return float64(typed)
+	}
+	return value
+}
+
+func compareValues(op string, left interface{}, right interface{}) (interface{}, error) {
+	if op == "==" || op == "!=" {
+		if reflect.TypeOf(left) != reflect.TypeOf(right) {
+			return nil, Error("cannot compare %s and %s", constraintType(left), constraintType(right))
+		}
+		return reflect.DeepEqual(left, right) == (op == "=="), nil
+	}
+	var cmp int
+	switch typed := left.(type) {
+	case float64:
+		other, ok := right.(float64)
+		if !ok {
+			return nil, Error("cannot compare number and %s", constraintType(right))
+		} else if typed < other {
+			cmp = -1
+		} else if typed > other {
+			cmp = 1
+		}
+	case string:
+		other, ok := right.(string)
+		if !ok {
+			return nil, Error("cannot compare string and %s", constraintType(right))
+		}
+		cmp = strings.Compare(typed, other)
+	default:
+		return nil, Error("cannot order %s values", constraintType(left))
+	}
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func constraintType(value interface{}) string {
+	switch value.(type) {
+	case float64:
+		return "number"
+	case []string:
+		return "list"
+	}
+	return fmt.Sprintf("%T", value)
+}
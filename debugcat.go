@@ -0,0 +1,101 @@
+package sitepkg
+
+/*****************************************************************************\
+  Debug categories.  Debug messages may be tagged with a category, so that
+  those of a big tool can be enabled selectively:
+
+    sitepkg.ShowDebugCategory("http", "GET %s: %d", url, status)
+
+  Programs defining the debug options (see SetDebugOpts) then take:
+
+    --Debug               all debug messages
+    --Debug=config,http   those of the categories "config" and "http", and
+                          those without a category (of ShowDebug, etc)
+
+  A program's own Debug option, if a bool, enables all categories.  Tagged
+  messages are shown as "DEBUG: http: GET ...".
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The debug categories enabled, or nil for all.
+var debugCategories map[string]bool
+
+/*****************************************************************************\
+  Define the debug options: Debug, a list of debug categories, or, given
+  without one, all.
+\*****************************************************************************/
+
+func SetDebugOpts() {
+	std.definePkgOpts(func() {
+		SetStringOpt("Debug", "", true, "", "Debug mode: all debug messages, or, as --Debug=config,http, those of the categories")
+		SetOptNoValue("Debug", "all")
+	})
+}
+
+/*****************************************************************************\
+  Enable the debug messages of the specified categories only, or, if none
+  are specified, of all.  Enabling debug messages is up to the log level
+  (see SetLogLevel).
+\*****************************************************************************/
+
+func SetDebugCategories(categories ...string) {
+	if len(categories) == 0 {
+		debugCategories = nil
+		return
+	}
+	debugCategories = make(map[string]bool)
+	for _, category := range categories {
+		debugCategories[strings.ToLower(category)] = true
+	}
+}
+
+/*****************************************************************************\
+  Check if debug messages of the specified category are shown.
+\*****************************************************************************/
+
+func DebugCategoryEnabled(category string) bool {
+	if !logWanted(LevelDebug) {
+		return false
+	}
+	return debugCategories == nil || debugCategories[strings.ToLower(category)]
+}
+
+func ShowDebugCategory(category string, format string, a ...interface{}) {
+	if DebugCategoryEnabled(category) {
+		logFields(LevelDebug, nil, category+": "+fmt.Sprintf(format, a...))
+	}
+}
+
+/*****************************************************************************\
+  Set the debug categories from the Debug option, whether a bool or a list
+  of categories, and return whether debug mode is on.
+\*****************************************************************************/
+
+func debugFromOptions() bool {
+	SetDebugCategories()
+	if debug, err := pkgOpt[bool]("Debug"); err == nil {
+		return debug
+	}
+	value, err := pkgOpt[string]("Debug")
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "false", "no", "0", "none":
+		return false
+	case "all", "true", "yes", "1":
+		return true
+	}
+	var categories []string
+	for _, category := range strings.Split(value, ",") {
+		if category = strings.TrimSpace(category); category != "" {
+			categories = append(categories, category)
+		}
+	}
+	SetDebugCategories(categories...)
+	return true
+}
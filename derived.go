@@ -0,0 +1,82 @@
+package sitepkg
+
+/*****************************************************************************\
+  Derived options: read-only options whose values are computed from other
+  options by a registered function, i.e. ApiUrl from Server, Port and TLS.
+  They are (re)computed after the command line is parsed and after each
+  Reload, and are shown by ShowConfig with Source "Derived".  They may not be
+  set by any source.
+\*****************************************************************************/
+
+import (
+	"sort"
+)
+
+const DerivedSource = "Derived"
+
+/*****************************************************************************\
+  Define a derived option of the specified type ("string", "bool", "int",
+  "uint" or "list").  fn must return a value of that type; it may read any
+  options, but should not depend on other derived options, as the order in
+  which they are computed is not defined.
+\*****************************************************************************/
+
+func (c *Configurator) SetDerivedOpt(name string, option_type string, desc string, fn func() (interface{}, error)) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	switch option_type {
+	case "string", "bool", "int", "uint", "list":
+	default:
+		panic(Error("programming error: bad type \"%s\" for derived option \"%s\"", option_type, name))
+	}
+	lc := c.optionKey(name)
+	c.checkRegistration(lc, option_type, "")
+	option := scratchOption(option_type)
+	option.Name = name
+	option.Desc = desc
+	option.Default = optionValue(option)
+	option.Source = "Default"
+	option.derive = fn
+	recordAssignment(option, 0)
+	c.Config[lc] = option
+}
+
+/*****************************************************************************\
+  Compute the values of the derived options.
+\*****************************************************************************/
+
+func (c *Configurator) deriveOptions() error {
+
+	var names []string
+
+	c.lock.RLock()
+	for lc, option := range c.Config {
+		if option.derive != nil {
+			names = append(names, lc)
+		}
+	}
+	c.lock.RUnlock()
+	sort.Strings(names)
+
+	for _, lc := range names {
+		// The function reads other options, so call it without the lock.
+		c.lock.RLock()
+		option := c.Config[lc]
+		c.lock.RUnlock()
+		value, err := option.derive()
+		if err != nil {
+			return Error("Failure deriving option \"%s\": %v", option.Name, err)
+		}
+		c.writeLock()
+		ok := setTypedValue(option, value)
+		if ok {
+			option.Source = DerivedSource
+			recordAssignment(option, 0)
+		}
+		c.lock.Unlock()
+		if !ok {
+			panic(Error("programming error: bad %T value for derived %s option \"%s\"", value, option.Type, option.Name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package sitepkg
+
+/*****************************************************************************\
+  Conditional defaults.  Detectors report on the context a program runs in
+  (inside a container, under cron, on the corporate network, etc), and
+  SetOptDefaultWhen registers a default for an option that applies when a
+  detector reports true, so that tools adapt without user configuration.
+  Such defaults are applied before the per command defaults and the config
+  files, and are shown with the Source "default(if container)".
+
+  The "container" and "cron" detectors are built in; others, such as
+  "corpnet", are site specific and registered by the program.
+\*****************************************************************************/
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+type conditionalDefault struct {
+	detector string
+	name     string
+	value    interface{}
+}
+
+var detectors = map[string]func() bool{
+	"container": inContainer,
+	"cron":      underCron,
+}
+var detected = make(map[string]bool)
+var detectLock sync.Mutex
+
+/*****************************************************************************\
+  Register a detector, replacing any of the same name.
+\*****************************************************************************/
+
+func RegisterDetector(name string, fn func() bool) {
+	detectLock.Lock()
+	defer detectLock.Unlock()
+	detectors[name] = fn
+	delete(detected, name)
+}
+
+/*****************************************************************************\
+  Check if the named detector reports true.  Each detector is run at most
+  once; unknown detectors report false.
+\*****************************************************************************/
+
+func Detected(name string) bool {
+	detectLock.Lock()
+	defer detectLock.Unlock()
+	if result, ok := detected[name]; ok {
+		return result
+	}
+	fn, ok := detectors[name]
+	if !ok {
+		return false
+	}
+	detected[name] = fn()
+	ShowDebug("Detected %s: %v", name, detected[name])
+	return detected[name]
+}
+
+/*****************************************************************************\
+  Register a default for an option when the named detector reports true.
+  The value must be of the option's type, or a string, which is parsed as if
+  read from a config file.  If several apply, the first registered wins.
+\*****************************************************************************/
+
+func (c *Configurator) SetOptDefaultWhen(detector string, name string, value interface{}) error {
+	c.writeLock()
+	defer c.lock.Unlock()
+	lc := c.optionKey(name)
+	default_value, err := c.defaultValue(lc, name, value, "SetOptDefaultWhen")
+	if err != nil {
+		return err
+	}
+	c.conditionalDefaults = append(c.conditionalDefaults,
+		conditionalDefault{detector: detector, name: lc, value: default_value})
+	return nil
+}
+
+/*****************************************************************************\
+  Apply the conditional defaults whose detectors report true.
+\*****************************************************************************/
+
+func (c *Configurator) applyConditionalDefaults() {
+	// Detectors may read options, so run them without the lock.
+	c.lock.RLock()
+	entries := append([]conditionalDefault(nil), c.conditionalDefaults...)
+	c.lock.RUnlock()
+	for _, entry := range entries {
+		Detected(entry.detector)
+	}
+
+	c.writeLock()
+	defer c.lock.Unlock()
+	applied := make(map[string]bool)
+	for _, entry := range entries {
+		if applied[entry.name] || !Detected(entry.detector) {
+			continue
+		}
+		option := c.Config[entry.name]
+		setTypedValue(option, entry.value)
+		option.Default = entry.value
+		option.Source = "default(if " + entry.detector + ")"
+		recordAssignment(option, 0)
+		applied[entry.name] = true
+	}
+}
+
+/*****************************************************************************\
+  The built in detectors.
+\*****************************************************************************/
+
+func inContainer() bool {
+	for _, file := range []string{"/.dockerenv", "/run/.containerenv"} {
+		if _, err := os.Stat(file); err == nil {
+			return true
+		}
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	cgroup, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return false
+	}
+	for _, marker := range []string{"docker", "kubepods", "containerd", "lxc"} {
+		if strings.Contains(string(cgroup), marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cron runs jobs without a terminal and with a minimal environment.
+func underCron() bool {
+	return !IsInteractive() && os.Getenv("TERM") == "" && os.Getenv("SSH_CONNECTION") == ""
+}
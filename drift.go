@@ -0,0 +1,89 @@
+package sitepkg
+
+/*****************************************************************************\
+  Config drift detection.  The checksums, modification times and owners of
+  the config and secret files read are recorded in the state dir, and on the
+  next run, a file changed by another user (i.e. owned by another user), or
+  whose owner has changed, is warned about: an early warning of unexpected
+  configuration changes on shared admin hosts.
+\*****************************************************************************/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+type fileState struct {
+	SHA256  string
+	ModTime time.Time
+	Owner   string
+}
+
+var driftLock sync.Mutex
+
+/*****************************************************************************\
+  Check the specified files against their state recorded by the last run,
+  warning of changes by other users, then record their current state.
+  Failures to read or record the state are only shown in Debug mode.
+\*****************************************************************************/
+
+func CheckFileDrift(files ...string) {
+	driftLock.Lock()
+	defer driftLock.Unlock()
+
+	dir, err := StateDir()
+	if err != nil || len(files) == 0 {
+		return
+	}
+	state_file := dir + "/files.json"
+	states := make(map[string]fileState)
+	if data, err := os.ReadFile(state_file); err == nil {
+		if err = json.Unmarshal(data, &states); err != nil {
+			ShowDebug("Bad file state in %s: %v", state_file, err)
+		}
+	}
+
+	user := InvokingUser()
+	for _, file := range files {
+		current, err := getFileState(file)
+		if err != nil {
+			ShowDebug("Failure checking %s: %v", file, err)
+			continue
+		}
+		if last, ok := states[file]; ok {
+			if last.Owner != current.Owner {
+				Warn("The owner of %s has changed since the last run, from %s to %s",
+					file, last.Owner, current.Owner)
+			} else if last.SHA256 != current.SHA256 && current.Owner != "" && current.Owner != user {
+				Warn("%s (owned by %s) has changed since the last run; modified %s",
+					file, current.Owner, current.ModTime.Format(time.RFC3339))
+			}
+		}
+		states[file] = current
+	}
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(state_file, append(data, '\n'), 0600)
+	}
+	if err != nil {
+		ShowDebug("Failure recording file state: %v", err)
+	}
+}
+
+func getFileState(file string) (fileState, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return fileState{}, err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fileState{}, err
+	}
+	sum := sha256.Sum256(data)
+	return fileState{SHA256: hex.EncodeToString(sum[:]), ModTime: info.ModTime(), Owner: fileOwner(info)}, nil
+}
@@ -0,0 +1,81 @@
+package sitepkg
+
+/*****************************************************************************\
+  An "ensure" framework for idempotent actions.  Rather than blindly applying
+  a change, an action declares a check function, reporting whether the object
+  is already in the desired state (and its current state), and an apply
+  function, which puts it in the desired state.  Ensure runs the check, then
+  applies the action only if needed, recording the outcome in a ChangeSet.
+\*****************************************************************************/
+
+type EnsureResult string
+
+const (
+	EnsureUnchanged EnsureResult = "unchanged"
+	EnsureChanged   EnsureResult = "changed"
+	EnsureFailed    EnsureResult = "failed"
+)
+
+type EnsureAction struct {
+	// The object acted upon (i.e.: "host www.example.com").
+	Object string
+	// Return whether the object is in the desired state, and its current
+	// state (nil if it does not exist).
+	Check func() (ok bool, current interface{}, err error)
+	// Put the object in the desired state, returning its new state.
+	Apply func() (interface{}, error)
+}
+
+/*****************************************************************************\
+  Ensure the object of the action is in the desired state, recording the
+  outcome in cs: unchanged, added (if its current state was nil), modified,
+  or failed.  If the DryRun option exists and is set, the action is not
+  applied, but the change that would be made is recorded.
+\*****************************************************************************/
+
+func Ensure(cs *ChangeSet, action EnsureAction) (EnsureResult, error) {
+
+	ok, current, err := action.Check()
+	if err != nil {
+		err = Error("Failure checking %s: %v", action.Object, err)
+		cs.Record(Change{Type: ChangeFailed, Object: action.Object, Error: err.Error()})
+		return EnsureFailed, err
+	} else if ok {
+		cs.Record(Change{Type: ChangeUnchanged, Object: action.Object, Before: current})
+		return EnsureUnchanged, nil
+	}
+
+	var after interface{} = "(desired state)"
+	if dry_run, _ := pkgOpt[bool]("DryRun"); !dry_run {
+		if after, err = action.Apply(); err != nil {
+			err = Error("Failure applying %s: %v", action.Object, err)
+			cs.Record(Change{Type: ChangeFailed, Object: action.Object, Before: current, Error: err.Error()})
+			return EnsureFailed, err
+		}
+	}
+	if current == nil {
+		cs.Added(action.Object, after)
+	} else {
+		cs.Modified(action.Object, current, after)
+	}
+	return EnsureChanged, nil
+}
+
+/*****************************************************************************\
+  Ensure each of the actions, continuing past failures.  Return an error if
+  any failed.
+\*****************************************************************************/
+
+func EnsureAll(cs *ChangeSet, actions []EnsureAction) error {
+	var failed int
+	for _, action := range actions {
+		if result, err := Ensure(cs, action); result == EnsureFailed {
+			failed++
+			Warn("%v", err)
+		}
+	}
+	if failed > 0 {
+		return Error("%d of %d actions failed.", failed, len(actions))
+	}
+	return nil
+}
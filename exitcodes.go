@@ -0,0 +1,182 @@
+package sitepkg
+
+/*****************************************************************************\
+  Exit code conventions.  Errors may be given a category (see CategoryError
+  and WrapError), and ExitWithError exits with the code registered for the
+  category of the error, so that the tools of a site agree on what their
+  exit codes mean.  The default codes are those of sysexits(3):
+
+    UsageError        64   bad command line
+    DataError         65   bad input data
+    NotFound          66   host, record, file, ... not found
+    RemoteError       69   remote service unavailable or failed
+    InternalError     70   internal (software) error
+    IOError           74   I/O error
+    TemporaryError    75   temporary failure; try again later
+    PermissionDenied  77   permission denied
+    ConfigError       78   bad configuration
+
+  Uncategorized errors exit with 1.  Errors configuring the options are
+  categorized: those of the command line as UsageError, and those of the
+  config files and environment as ConfigError.
+\*****************************************************************************/
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type ErrorCategory string
+
+const (
+	UsageError       ErrorCategory = "UsageError"
+	DataError        ErrorCategory = "DataError"
+	NotFound         ErrorCategory = "NotFound"
+	RemoteError      ErrorCategory = "RemoteError"
+	InternalError    ErrorCategory = "InternalError"
+	IOError          ErrorCategory = "IOError"
+	TemporaryError   ErrorCategory = "TemporaryError"
+	PermissionDenied ErrorCategory = "PermissionDenied"
+	ConfigError      ErrorCategory = "ConfigError"
+)
+
+const DefaultExitCode = 1
+
+var exitCodes = defaultExitCodes()
+var exitCodesLock sync.RWMutex
+
+func defaultExitCodes() map[ErrorCategory]int {
+	return map[ErrorCategory]int{
+		UsageError:       64,
+		DataError:        65,
+		NotFound:         66,
+		RemoteError:      69,
+		InternalError:    70,
+		IOError:          74,
+		TemporaryError:   75,
+		PermissionDenied: 77,
+		ConfigError:      78,
+	}
+}
+
+/*****************************************************************************\
+  An error with a category.  The category of an error is that of the first
+  CategorizedError in its chain (see errors.As).
+\*****************************************************************************/
+
+type CategorizedError struct {
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *CategorizedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CategorizedError) Unwrap() error {
+	return e.Err
+}
+
+/*****************************************************************************\
+  Return a new error of the specified category, as for Error.
+\*****************************************************************************/
+
+func CategoryError(category ErrorCategory, format string, a ...interface{}) error {
+	return &CategorizedError{Category: category, Err: Error(format, a...)}
+}
+
+/*****************************************************************************\
+  Return the error given the specified category, or nil if err is nil.  An
+  error already categorized keeps its category.
+\*****************************************************************************/
+
+func WrapError(category ErrorCategory, err error) error {
+	var categorized *CategorizedError
+	if err == nil || errors.As(err, &categorized) {
+		return err
+	}
+	return &CategorizedError{Category: category, Err: err}
+}
+
+/*****************************************************************************\
+  Return the category of the error, or "" if it has none.
+\*****************************************************************************/
+
+func Category(err error) ErrorCategory {
+	var categorized *CategorizedError
+	if errors.As(err, &categorized) {
+		return categorized.Category
+	}
+	return ""
+}
+
+/*****************************************************************************\
+  Check if the error is of the specified category.
+\*****************************************************************************/
+
+func IsCategory(err error, category ErrorCategory) bool {
+	return err != nil && Category(err) == category
+}
+
+/*****************************************************************************\
+  Set the exit code for an error category: to override a default code, or
+  to register a site specific category.  Codes must be 1 to 125.
+\*****************************************************************************/
+
+func SetExitCode(category ErrorCategory, code int) {
+	if category == "" {
+		panic(Error("programming error: empty error category"))
+	} else if code < 1 || code > 125 {
+		panic(Error("programming error: bad exit code %d for category %s", code, category))
+	}
+	exitCodesLock.Lock()
+	defer exitCodesLock.Unlock()
+	exitCodes[category] = code
+}
+
+/*****************************************************************************\
+  Return the exit code for the error: 0 if err is nil, the code registered
+  for its category, or DefaultExitCode.
+\*****************************************************************************/
+
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	exitCodesLock.RLock()
+	defer exitCodesLock.RUnlock()
+	if code, ok := exitCodes[Category(err)]; ok {
+		return code
+	}
+	return DefaultExitCode
+}
+
+/*****************************************************************************\
+  Return the registered error categories and their exit codes, one per
+  line, for documenting a program's exit codes.
+\*****************************************************************************/
+
+func ExitCodes() []string {
+	exitCodesLock.RLock()
+	defer exitCodesLock.RUnlock()
+	var lines []string
+	for category, code := range exitCodes {
+		lines = append(lines, fmt.Sprintf("%3d  %s", code, category))
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+/*****************************************************************************\
+  Exit the program with the exit code for the error (see ExitCode), after
+  showing it; if err is nil, exit with 0.
+\*****************************************************************************/
+
+func ExitWithError(err error) {
+	if err == nil {
+		Exit(0)
+	}
+	Exit(ExitCode(err), err)
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package sitepkg
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+)
+
+/*****************************************************************************\
+  Return the login name (or, failing that, the uid) of a file's owner.
+\*****************************************************************************/
+
+func fileOwner(info os.FileInfo) string {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	uid := strconv.FormatUint(uint64(stat.Uid), 10)
+	if owner, err := user.LookupId(uid); err == nil {
+		return owner.Username
+	}
+	return uid
+}
@@ -0,0 +1,13 @@
+package sitepkg
+
+import (
+	"os"
+)
+
+/*****************************************************************************\
+  File owners are not available on Windows.
+\*****************************************************************************/
+
+func fileOwner(info os.FileInfo) string {
+	return ""
+}
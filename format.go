@@ -0,0 +1,219 @@
+package sitepkg
+
+/*****************************************************************************\
+  Structured output.  The OutputFormat option (text, json, csv or table)
+  selects how results are written, and a Formatter writes them in it, so
+  that tools emit their results consistently:
+
+    formatter := sitepkg.NewFormatter("Host", "Address", "TTL")
+    formatter.Add("www.example.com", "10.1.1.1", 300)
+    err := formatter.Write()
+
+  The formats:
+
+    text    one line per row, its values separated by spaces; in Verbose
+            mode, preceded by a header line of the column names
+    table   the rows in aligned columns, under a header line
+    csv     RFC 4180 records, after a header record
+    json    an array of objects keyed by column name, in column order,
+            whatever the mode
+
+  plus any registered with RegisterOutputFormat.  Header lines are omitted
+  in Quiet mode, or with NoHeaders, i.e. for scripting.  See also Record,
+  for results built field by field.
+\*****************************************************************************/
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+	FormatTable = "table"
+)
+
+var outputFormats = []string{FormatText, FormatJSON, FormatCSV, FormatTable}
+
+// Write the rows of the columns in an output format.
+type OutputWriter func(w io.Writer, columns []string, rows [][]interface{}) error
+
+var outputWriters = make(map[string]OutputWriter)
+
+type Formatter struct {
+	Columns []string
+	rows    [][]interface{}
+}
+
+/*****************************************************************************\
+  Return the output format, from the OutputFormat option, lowercased:
+  "text" if the option is not defined, or an error if it is not a known
+  format.
+\*****************************************************************************/
+
+func GetOutputFormat() (string, error) {
+	format, err := pkgOpt[string]("OutputFormat")
+	if err != nil || format == "" {
+		return FormatText, nil
+	}
+	format = strings.ToLower(format)
+	if in_list, _ := InList(outputFormats, format); !in_list {
+		return FormatText, CategoryError(UsageError, "Bad output format \"%s\": expected one of %s", format, strings.Join(outputFormats, ", "))
+	}
+	return format, nil
+}
+
+/*****************************************************************************\
+  Register an output format, i.e. "yaml" or "ldif", written by the specified
+  function.  Registering a format twice, or a builtin one, is a programming
+  error.
+\*****************************************************************************/
+
+func RegisterOutputFormat(name string, writer OutputWriter) {
+	name = strings.ToLower(name)
+	if in_list, _ := InList(outputFormats, name); in_list {
+		panic(Error("programming error: RegisterOutputFormat: format \"%s\" already defined", name))
+	}
+	outputFormats = append(outputFormats, name)
+	outputWriters[name] = writer
+}
+
+// Check if header lines are wanted: not in Quiet mode, nor with NoHeaders.
+func outputHeaders() bool {
+	no_headers, _ := pkgOpt[bool]("NoHeaders")
+	return !Quiet && !no_headers
+}
+
+/*****************************************************************************\
+  Create a Formatter for rows of the specified columns.
+\*****************************************************************************/
+
+func NewFormatter(columns ...string) *Formatter {
+	return &Formatter{Columns: columns}
+}
+
+/*****************************************************************************\
+  Add a row.  A row of other than one value per column is a programming
+  error.
+\*****************************************************************************/
+
+func (f *Formatter) Add(values ...interface{}) {
+	if len(values) != len(f.Columns) {
+		panic(Error("programming error: Formatter.Add: %d values for %d columns", len(values), len(f.Columns)))
+	}
+	f.rows = append(f.rows, values)
+}
+
+/*****************************************************************************\
+  Write the rows in the output format to DefaultPrint, or, with Fwrite, to
+  the specified writer.
+\*****************************************************************************/
+
+func (f *Formatter) Write() error {
+	return f.Fwrite(DefaultPrint)
+}
+
+func (f *Formatter) Fwrite(w io.Writer) error {
+	format, err := GetOutputFormat()
+	if err != nil {
+		return err
+	} else if writer, ok := outputWriters[format]; ok {
+		return writer(w, f.Columns, f.rows)
+	}
+	switch format {
+	case FormatJSON:
+		return f.writeJSON(w)
+	case FormatCSV:
+		return f.writeCSV(w)
+	case FormatTable:
+		return f.writeTable(w)
+	}
+	return f.writeText(w)
+}
+
+func (f *Formatter) writeText(w io.Writer) error {
+	if Verbose && outputHeaders() {
+		Fprintln(w, "%s", strings.Join(f.Columns, " "))
+	}
+	for _, row := range f.rows {
+		Fprintln(w, "%s", strings.Join(formatCells(row), " "))
+	}
+	return nil
+}
+
+func (f *Formatter) writeTable(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if outputHeaders() {
+		Fprintln(table, "%s", strings.Join(f.Columns, "\t"))
+	}
+	for _, row := range f.rows {
+		Fprintln(table, "%s", strings.Join(formatCells(row), "\t"))
+	}
+	return table.Flush()
+}
+
+func (f *Formatter) writeCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if outputHeaders() {
+		writer.Write(f.Columns)
+	}
+	for _, row := range f.rows {
+		writer.Write(formatCells(row))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Written as json.MarshalIndent would, but with the keys in column order.
+func (f *Formatter) writeJSON(w io.Writer) error {
+	if len(f.rows) == 0 {
+		Fprintln(w, "[]")
+		return nil
+	}
+	var json_data bytes.Buffer
+	json_data.WriteString("[")
+	for n, row := range f.rows {
+		if n > 0 {
+			json_data.WriteString(",")
+		}
+		json_data.WriteString("\n  {")
+		for i, column := range f.Columns {
+			if i > 0 {
+				json_data.WriteString(",")
+			}
+			name, _ := json.Marshal(column)
+			value, err := json.MarshalIndent(row[i], "    ", "  ")
+			if err != nil {
+				return Error("Failure encoding JSON output: %v", err)
+			}
+			json_data.WriteString("\n    " + string(name) + ": " + string(value))
+		}
+		json_data.WriteString("\n  }")
+	}
+	json_data.WriteString("\n]")
+	Fprintln(w, "%s", json_data.String())
+	return nil
+}
+
+// Return the values of a row as strings; lists are comma separated.
+func formatCells(row []interface{}) []string {
+	cells := make([]string, len(row))
+	for i, value := range row {
+		switch typed := value.(type) {
+		case nil:
+			cells[i] = ""
+		case []string:
+			cells[i] = strings.Join(typed, ",")
+		default:
+			cells[i] = fmt.Sprintf("%v", value)
+		}
+	}
+	return cells
+}
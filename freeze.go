@@ -0,0 +1,113 @@
+package sitepkg
+
+/*****************************************************************************\
+  Freezing the configuration.  After Freeze, typically called right after
+  ConfigureOptions, the options are immutable: any further Set call panics,
+  catching code that changes the configuration at runtime, except within a
+  Reload.  Getters then read a snapshot of the option values, without
+  locking, for hot paths.
+\*****************************************************************************/
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type frozenOption struct {
+	Type   string
+	Value  interface{}
+	Source string
+}
+
+type configSnapshot map[string]frozenOption
+
+type freezeState struct {
+	snapshot  atomic.Value // *configSnapshot, nil unless frozen
+	reloading int32
+	reload    sync.Mutex
+}
+
+/*****************************************************************************\
+  Freeze the configuration.
+\*****************************************************************************/
+
+func (c *Configurator) Freeze() {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	snapshot := make(configSnapshot, len(c.Config))
+	for name, option := range c.Config {
+		snapshot[name] = frozenOption{Type: option.Type, Value: optionValue(option), Source: option.Source}
+	}
+	c.frozen.snapshot.Store(&snapshot)
+}
+
+/*****************************************************************************\
+  Check if the configuration is frozen.
+\*****************************************************************************/
+
+func (c *Configurator) IsFrozen() bool {
+	return c.getSnapshot() != nil
+}
+
+func (c *Configurator) getSnapshot() *configSnapshot {
+	snapshot, _ := c.frozen.snapshot.Load().(*configSnapshot)
+	return snapshot
+}
+
+/*****************************************************************************\
+  Change a frozen configuration: call fn, which may set options (i.e. reread
+  config files on SIGHUP), recompute the derived options and check the
+  constraints, then freeze the configuration again.  Getters see the
+  previous values until fn returns.
+\*****************************************************************************/
+
+func (c *Configurator) Reload(fn func() error) error {
+	c.frozen.reload.Lock()
+	defer c.frozen.reload.Unlock()
+	atomic.StoreInt32(&c.frozen.reloading, 1)
+	defer atomic.StoreInt32(&c.frozen.reloading, 0)
+	if err := fn(); err != nil {
+		return err
+	}
+	if err := c.deriveOptions(); err != nil {
+		return err
+	} else if err := c.CheckConstraints(); err != nil {
+		return err
+	}
+	if c.IsFrozen() {
+		c.Freeze()
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Take the write lock for changing the options, panicking if the
+  configuration is frozen (outside of a Reload).
+\*****************************************************************************/
+
+func (c *Configurator) writeLock() {
+	if c.IsFrozen() && atomic.LoadInt32(&c.frozen.reloading) == 0 {
+		panic(Error("programming error: configuration changed after Freeze"))
+	}
+	c.lock.Lock()
+}
+
+/*****************************************************************************\
+  Retrieve an option value from the frozen snapshot.  Return whether the
+  configuration is frozen; if not, the caller reads the options as usual.
+\*****************************************************************************/
+
+func frozenGet[T any](c *Configurator, name string, caller string) (value T, frozen bool, err error) {
+	snapshot := c.getSnapshot()
+	if snapshot == nil {
+		return value, false, nil
+	}
+	option, ok := (*snapshot)[c.optionKey(name)]
+	if !ok {
+		return value, true, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	if value, ok = option.Value.(T); !ok {
+		return value, true, Error("%s: bad call for %s \"%s\".", caller, option.Type, name)
+	}
+	return value, true, nil
+}
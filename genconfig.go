@@ -0,0 +1,78 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for generating a template configuration file from the registered
+  options, to give new users of a program a documented starting point.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+/*****************************************************************************\
+  Write a fully commented template config file to w.  Each option is listed
+  with its description, type and default value; options that may be set in a
+  config file are shown as commented out assignments of their defaults, so
+  the user need only uncomment and edit the ones of interest.  If section is
+  not empty, the options are placed under that section (i.e.: "host:add"),
+  otherwise, if the invoked command has subcommands, under the section of the
+  final subcommand.
+\*****************************************************************************/
+
+func GenConfig(w io.Writer, section string) error {
+
+	commandPaths := GetCommandPaths()
+	if len(commandPaths) == 0 {
+		return Error("bug: failure getting command paths")
+	}
+	if section == "" && len(commandPaths) > 1 {
+		section = commandPaths[len(commandPaths)-1]
+	}
+
+	Fprintln(w, "#")
+	Fprintln(w, "# Configuration file for %s (%s).", ProgramName, Package)
+	Fprintln(w, "#")
+	Fprintln(w, "# Save as %s.conf in any of the following directories:", commandPaths[0])
+	for _, dir := range ConfigDirs {
+		Fprintln(w, "#   %s", dir)
+	}
+	Fprintln(w, "#")
+	if version := std.ConfigSchemaVersion(); version > 0 {
+		Fprintln(w, "%s = %d", ConfVersionHeader, version)
+	}
+	if section != "" {
+		Fprintln(w, "\n[%s]", section)
+	}
+
+	sorted_keys := make([]string, 0, len(Config))
+	for name := range Config {
+		sorted_keys = append(sorted_keys, name)
+	}
+	sort.Strings(sorted_keys)
+
+	for _, name := range sorted_keys {
+		option := Config[name]
+		Fprintln(w, "")
+		if option.Desc != "" {
+			Fprintln(w, "# %s - %s", option.Name, option.Desc)
+		} else {
+			Fprintln(w, "# %s", option.Name)
+		}
+		Fprintln(w, "# Type: %s; Default: %s", option.Type, formatDefault(option))
+		if option.Sources&SourceFile == 0 {
+			Fprintln(w, "# (May be set by the %s only.)", option.Sources)
+			continue
+		}
+		Fprintln(w, "#%s = %s", option.Name, quoteConfigValue(formatValue(option.Default)))
+	}
+	return nil
+}
+
+func formatDefault(option *Option) string {
+	if option.Type == "string" || option.Type == "list" {
+		return fmt.Sprintf("\"%s\"", formatValue(option.Default))
+	}
+	return formatValue(option.Default)
+}
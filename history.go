@@ -0,0 +1,188 @@
+package sitepkg
+
+/*****************************************************************************\
+  Option history.  For the options selected with RecordOptionHistory, each
+  run records the effective value, if changed since last recorded, in the
+  state dir (option-history.jsonl), so that --OptionHistory NAME can show
+  how the setting has evolved on this host: when, by whom, and from where.
+\*****************************************************************************/
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+type OptionHistoryEntry struct {
+	Time    time.Time
+	User    string
+	Program string
+	Option  string
+	Value   interface{}
+	Source  string
+}
+
+var historyOptions []string
+var historyLock sync.Mutex
+
+/*****************************************************************************\
+  Select options whose effective values are recorded by each run.
+\*****************************************************************************/
+
+func RecordOptionHistory(names ...string) {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+	historyOptions = append(historyOptions, names...)
+}
+
+func optionHistoryFile() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return dir + "/option-history.jsonl", nil
+}
+
+/*****************************************************************************\
+  Return the recorded history of an option (or of all options, if name is
+  ""), oldest first.
+\*****************************************************************************/
+
+func RecordedOptionHistory(name string) ([]OptionHistoryEntry, error) {
+	history_file, err := optionHistoryFile()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(history_file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, Error("Error opening option history \"%s\": %v", history_file, err)
+	}
+	defer file.Close()
+
+	var entries []OptionHistoryEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry OptionHistoryEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			ShowDebug("Bad option history entry in %s: %v", history_file, err)
+			continue
+		}
+		if name == "" || std.optionKey(entry.Option) == std.optionKey(name) {
+			entries = append(entries, entry)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, Error("Error reading option history \"%s\": %v", history_file, err)
+	}
+	return entries, nil
+}
+
+/*****************************************************************************\
+  Record the effective values of the selected options which have changed
+  since last recorded.
+\*****************************************************************************/
+
+func recordOptionHistory() error {
+	historyLock.Lock()
+	defer historyLock.Unlock()
+	if len(historyOptions) == 0 {
+		return nil
+	}
+	history, err := RecordedOptionHistory("")
+	if err != nil {
+		return err
+	}
+	last := make(map[string]OptionHistoryEntry)
+	for _, entry := range history {
+		last[std.optionKey(entry.Option)] = entry
+	}
+
+	var lines []byte
+	now := time.Now()
+	for _, name := range historyOptions {
+		// Not via Lookup, which would count as a read of the option.
+		value, found, source := std.lookup(name)
+		if !found {
+			continue
+		}
+		// Compare as recorded, i.e. with numbers as decoded from JSON.
+		data, err := json.Marshal(value)
+		if err != nil {
+			return Error("Failure encoding option \"%s\": %v", name, err)
+		}
+		var recorded interface{}
+		json.Unmarshal(data, &recorded)
+		if previous, ok := last[std.optionKey(name)]; ok &&
+			reflect.DeepEqual(previous.Value, recorded) && previous.Source == source {
+			continue
+		}
+		entry := OptionHistoryEntry{Time: now, User: Identity(), Program: ProgramName,
+			Option: name, Value: value, Source: source}
+		if data, err = json.Marshal(entry); err != nil {
+			return Error("Failure encoding option history: %v", err)
+		}
+		lines = append(append(lines, data...), '\n')
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+
+	history_file, err := optionHistoryFile()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(history_file), 0700); err != nil {
+		return Error("Error creating directory \"%s\": %v", filepath.Dir(history_file), err)
+	}
+	file, err := os.OpenFile(history_file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return Error("Error opening option history \"%s\": %v", history_file, err)
+	}
+	if _, err = file.Write(lines); err != nil {
+		file.Close()
+		return Error("Error writing option history \"%s\": %v", history_file, err)
+	}
+	return file.Close()
+}
+
+/*****************************************************************************\
+  Show the recorded history of an option.
+\*****************************************************************************/
+
+func ShowOptionHistory(name string) error {
+	history, err := RecordedOptionHistory(name)
+	if err != nil {
+		return err
+	}
+	if JSONOutput() {
+		if history == nil {
+			history = []OptionHistoryEntry{}
+		}
+		showJSON(history)
+		return nil
+	} else if len(history) == 0 {
+		Println("No history recorded for option \"%s\".", name)
+		return nil
+	}
+	for _, entry := range history {
+		value := entry.Value
+		// Lists are decoded from JSON as []interface{}.
+		if list, ok := value.([]interface{}); ok {
+			items := []string{}
+			for _, item := range list {
+				items = append(items, fmt.Sprint(item))
+			}
+			value = items
+		}
+		Println("%s  %-12s  %-12s  %s = %s  (%s)", entry.Time.Format(time.RFC3339), entry.User,
+			entry.Program, entry.Option, formatValue(value), entry.Source)
+	}
+	return nil
+}
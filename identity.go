@@ -0,0 +1,72 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for delegated administration: the RunAs and OnBehalfOf options
+  let an administrator act as, or on behalf of, another identity.  Programs
+  should record the identities (see Identity) in any audit records they
+  write, and pass ImpersonationHeaders along with any REST requests.
+\*****************************************************************************/
+
+import (
+	"net/http"
+	"os"
+	"os/user"
+)
+
+const RunAsHeader = "X-Run-As"
+const OnBehalfOfHeader = "X-On-Behalf-Of"
+
+/*****************************************************************************\
+  Return the login name of the user invoking the program.
+\*****************************************************************************/
+
+func InvokingUser() string {
+	if current, err := user.Current(); err == nil {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+/*****************************************************************************\
+  Return the identity the program is acting as: the RunAs user if specified,
+  otherwise the invoking user.
+\*****************************************************************************/
+
+func EffectiveUser() string {
+	if run_as, _ := pkgOpt[string]("RunAs"); run_as != "" {
+		return run_as
+	}
+	return InvokingUser()
+}
+
+/*****************************************************************************\
+  Return a description of the identities involved, suitable for audit
+  records: i.e. "jdoe", "jdoe as admin", or "jdoe as admin on behalf of bob".
+\*****************************************************************************/
+
+func Identity() string {
+	identity := InvokingUser()
+	if run_as, _ := pkgOpt[string]("RunAs"); run_as != "" {
+		identity += " as " + run_as
+	}
+	if on_behalf_of, _ := pkgOpt[string]("OnBehalfOf"); on_behalf_of != "" {
+		identity += " on behalf of " + on_behalf_of
+	}
+	return identity
+}
+
+/*****************************************************************************\
+  Return the HTTP headers conveying the RunAs and OnBehalfOf identities, if
+  any, to a REST API.
+\*****************************************************************************/
+
+func ImpersonationHeaders() http.Header {
+	headers := make(http.Header)
+	if run_as, _ := pkgOpt[string]("RunAs"); run_as != "" {
+		headers.Set(RunAsHeader, run_as)
+	}
+	if on_behalf_of, _ := pkgOpt[string]("OnBehalfOf"); on_behalf_of != "" {
+		headers.Set(OnBehalfOfHeader, on_behalf_of)
+	}
+	return headers
+}
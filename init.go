@@ -1,10 +1,5 @@
 package sitepkg
 
-import (
-	"os"
-	"path"
-)
-
 /*****************************************************************************\
   Provide a look and feel of a /usr/site package.  Configure the settings
   common to all /usr/site utility packages.
@@ -20,22 +15,54 @@ var ProgramName string
 var Verbose, Quiet, Quieter, Debug bool
 
 func PackageInit(pkg_name string, pkg_version string) error {
-	PkgName = pkg_name
-	PkgVersion = pkg_version
-	Package = PkgName + "-" + PkgVersion
-	PackageDir = "/usr/site/" + Package
-	PackageEtc = PackageDir + "/etc"
-	LocalEtc = "/etc/opt/" + PkgName
-	ProgramName = path.Base(os.Args[0])
-	SetBoolOpt("Help", "h", false, false, "Help! Show usage")
-	SetBoolOpt("Verbose", "v", true, false, "Verbose mode")
-	SetBoolOpt("Quiet", "q", true, false, "Quiet mode")
-	SetBoolOpt("Quieter", "", true, false, "Quieter mode")
-	SetBoolOpt("ShowConfig", "", false, false, "Show configuration settings and value, and exit.")
-	SetBoolOpt("Page", "", true, true, "Enable paging when showing usage (-h)")
-	SetStringOpt("Pager", "", true, "", "Specify a pager command for paging usage information")
-	//SetStringOpt ("MailList", "m", true, "", "Specify an email address to which to email any output.")
-	//SetStringOpt ("LogFile", "", true, "", "Specify a log file to which to write any output.")
-	SetBoolOpt("Version", "", false, false, "Show version info.")
+	std.setPackage(pkg_name, pkg_version)
+	syncGlobals()
+	handleSignals()
+	std.definePkgOpts(func() {
+		SetBoolOpt("Help", "h", false, false, "Help! Show usage")
+		SetBoolOpt("Verbose", "v", true, false, "Verbose mode")
+		SetBoolOpt("Quiet", "q", true, false, "Quiet mode")
+		SetBoolOpt("Quieter", "", true, false, "Quieter mode")
+		SetStringOpt("LogLevel", "", true, "", "Specify the log level: trace, debug, info, warn or error (default warn)")
+		SetStringOpt("LogFormat", "", true, "text", "Specify the log format: text or json (JSON lines)")
+		SetStringOpt("Color", "", true, "auto", "Color warnings, errors and debug messages: auto (when output is a terminal), always or never")
+		SetStringOpt("MessagePrefix", "", true, "", "Specify the prefix of messages: a template of {program}, {host}, {fqdn}, {pid} and {command} (default {program}), or none")
+		SetStringOpt("Timestamps", "", true, "", "Prefix messages with a timestamp: rfc3339, syslog, epoch or a Go time layout")
+		SetBoolOpt("ShowConfig", "", false, false, "Show configuration settings and value, and exit.")
+		SetBoolOpt("ShowChanged", "", false, false, "Show configuration settings that differ from their defaults, and exit.")
+		SetBoolOpt("GenConfig", "", false, false, "Generate a commented template config file, and exit.")
+		SetBoolOpt("Configure", "", false, false, "Run the interactive configuration wizard, and exit.")
+		SetBoolOpt("CheckConfig", "", false, false, "Check all the config files for errors, and exit.")
+		SetBoolOpt("TraceConfig", "", false, false, "Trace the reading of the config files: each line, its section, and the resulting assignment.")
+		SetBoolOpt("Deprecations", "", false, false, "Show the deprecated option and section names used in the config files, as JSON, and exit.")
+		SetBoolOpt("MigrateConfig", "", false, false, "Update the config files to the current option names and config schema, and exit.")
+		SetStringOpt("Completion", "", false, "", "Generate a completion script for the specified shell (bash), and exit.")
+		SetBoolOpt("Page", "", true, true, "Enable paging when showing usage (-h)")
+		SetStringOpt("Pager", "", true, "", "Specify a pager command for paging usage information")
+		SetStringOpt("OutputFormat", "", true, "text", "Specify the output format: text, json, csv or table")
+		SetBoolOpt("NoHeaders", "", true, false, "Omit the header lines of table, csv and (verbose) text output")
+		SetStringOpt("MailList", "m", true, "", "Specify an email address (or comma separated addresses) to which to email any output.")
+		SetBoolOpt("MailOnError", "", true, false, "Email the output only if the program exits with an error")
+		SetStringOpt("MailServer", "", true, "", "Specify the SMTP server (host[:port]) by which to email the output (default sendmail)")
+		SetStringOpt("MailFrom", "", true, "", "Specify the sender address of emailed output (default user@host)")
+		SetStringOpt("LogFile", "", true, "", "Specify a log file to which to write any output.")
+		SetIntOpt("LogFileMaxSize", "", true, 0, "Specify the size, in MB, at which to rotate the log file (0 for no limit)")
+		SetStringOpt("LogFileMaxAge", "", true, "", "Specify the age (i.e. 24h, 7d) at which to rotate the log file")
+		SetIntOpt("LogFileKeep", "", true, 5, "Specify the number of rotated log files to keep")
+		SetBoolOpt("LogFileCompress", "", true, false, "Compress rotated log files")
+		SetStringOpt("OptionHistory", "", false, "", "Show the recorded history of the specified option on this host, and exit.")
+		SetBoolOpt("Version", "", false, false, "Show version info.")
+		SetBoolOpt("SupportInfo", "", false, false, "Show a report of version, configuration and environment info for support tickets, and exit.")
+		SetBoolOpt("Interactive", "", false, false, "Run an interactive shell of the program's commands")
+		SetStringOpt("Timeout", "", true, "", "Specify a timeout for the run, i.e. 30s or 5m (default none)")
+		SetBoolOpt("Yes", "", false, false, "Confirm destructive operations without asking")
+		SetListOpt("Option", "o", false, nil, "Override a config file option: name=value (repeatable)")
+		std.yieldShortOpt("MailList")
+		std.yieldShortOpt("Option")
+		SetStringOpt("Root", "", false, "", "Specify an alternate root directory (i.e. an image or chroot) for the package paths")
+		SetStringOpt("Tenant", "", false, "", "Specify the tenant (grid, view, etc) to operate against (alias --view)")
+		SetStringOpt("RunAs", "", false, "", "Specify an identity to act as (delegated administration)")
+		SetStringOpt("OnBehalfOf", "", false, "", "Specify an identity on whose behalf to act")
+	})
 	return nil
 }
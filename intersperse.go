@@ -0,0 +1,98 @@
+package sitepkg
+
+/*****************************************************************************\
+  Flag and argument interspersion.  By default, options may follow the
+  (non-option) arguments, as in "prog host add h.example.com --ttl 300".
+  Wrapper style tools, passing everything after their own options to an
+  inner command, turn this off, so that option parsing stops at the first
+  argument (after any subcommand; see AddCommand):
+
+    wrap --Verbose run ssh -v host     inner command: ssh -v host
+
+  Tools with other commands may turn it off for the wrapper command only:
+
+    sitepkg.AddCommand("run", run, "Run a command").SetInterspersed(false)
+
+  Either way, parsing stops at "--", which is dropped; ArgsLenAtDash tells
+  whether, and where, it was given.
+\*****************************************************************************/
+
+import (
+	"strings"
+)
+
+/*****************************************************************************\
+  Set whether options may follow the arguments.  Call before
+  ConfigureOptions.
+\*****************************************************************************/
+
+func (c *Configurator) SetInterspersed(interspersed bool) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	c.noIntersperse = !interspersed
+	c.FlagSet.SetInterspersed(interspersed)
+}
+
+/*****************************************************************************\
+  Set whether options may follow the arguments of the command (and its
+  subcommands).
+\*****************************************************************************/
+
+func (command *Command) SetInterspersed(interspersed bool) *Command {
+	command.noIntersperse = !interspersed
+	return command
+}
+
+/*****************************************************************************\
+  Check if option parsing stops at the first argument: if so set, or set for
+  the invoked command or one of its parents.
+\*****************************************************************************/
+
+func (c *Configurator) stopAtArgument() bool {
+	return c.noIntersperse || c.plugin != "" || c.commandStopsAtArgument(c.invoked)
+}
+
+func (c *Configurator) commandStopsAtArgument(path string) bool {
+	words := strings.Split(path, ":")
+	for i := range words {
+		if command, ok := c.commands[strings.Join(words[:i+1], ":")]; ok && command.noIntersperse {
+			return true
+		}
+	}
+	return false
+}
+
+/*****************************************************************************\
+  Return the number of arguments, of those returned by ConfigureOptions,
+  which preceded "--", or -1 if "--" was not given.
+\*****************************************************************************/
+
+func (c *Configurator) ArgsLenAtDash() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.FlagSet.ArgsLenAtDash()
+}
+
+/*****************************************************************************\
+  Return the arguments which will be parsed as options: those before "--",
+  or, if options may not follow the arguments, before the first argument.
+  For finding options before the command line has been parsed.
+\*****************************************************************************/
+
+func (c *Configurator) optionArgs(args []string) []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return args[:i]
+		} else if strings.HasPrefix(arg, "-") && arg != "-" {
+			if c.takesValue(arg) {
+				i++
+			}
+		} else if c.stopAtArgument() {
+			return args[:i]
+		}
+	}
+	return args
+}
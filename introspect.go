@@ -0,0 +1,100 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for inspecting the registered options, so programs can build
+  their own reports, completions or validation without reaching into the
+  Config map.  OptionNames returns the names as stored (in lower case, unless
+  in case sensitive mode); OptionInfo includes the name as defined.
+\*****************************************************************************/
+
+import (
+	"sort"
+	"strings"
+)
+
+type OptionInfo struct {
+	Name     string
+	Type     string
+	Value    interface{}
+	Default  interface{}
+	Desc     string
+	ShortOpt string
+	Sources  OptionSource
+	Group    string
+	Source   string
+}
+
+func newOptionInfo(option *Option) OptionInfo {
+	return OptionInfo{
+		Name:     option.Name,
+		Type:     option.Type,
+		Value:    optionValue(option),
+		Default:  option.Default,
+		Desc:     option.Desc,
+		ShortOpt: option.ShortOpt,
+		Sources:  option.Sources,
+		Group:    option.Group,
+		Source:   option.Source,
+	}
+}
+
+/*****************************************************************************\
+  Return the names of all the options, sorted.
+\*****************************************************************************/
+
+func (c *Configurator) OptionNames() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	names := make([]string, 0, len(c.Config))
+	for name := range c.Config {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*****************************************************************************\
+  Return a snapshot of the details of the specified option.
+\*****************************************************************************/
+
+func (c *Configurator) OptionInfo(name string) (OptionInfo, error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
+	if !ok {
+		return OptionInfo{}, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	return newOptionInfo(option), nil
+}
+
+/*****************************************************************************\
+  Call fn with the details of each option, in name order, until fn returns
+  false.  fn may itself get or set options.
+\*****************************************************************************/
+
+func (c *Configurator) EachOption(fn func(info OptionInfo) bool) {
+	for _, name := range c.OptionNames() {
+		if info, err := c.OptionInfo(name); err == nil && !fn(info) {
+			return
+		}
+	}
+}
+
+/*****************************************************************************\
+  Return the values of the namespaced options under the prefix, keyed by
+  their names (as defined) less the prefix: i.e. GetSubtree("db") returns
+  {"host": ..., "port": ...} for the options "db.host" and "db.port".
+\*****************************************************************************/
+
+func (c *Configurator) GetSubtree(prefix string) map[string]interface{} {
+	subtree := make(map[string]interface{})
+	key_prefix := c.optionKey(prefix) + "."
+	c.EachOption(func(info OptionInfo) bool {
+		if strings.HasPrefix(c.optionKey(info.Name), key_prefix) {
+			subtree[info.Name[len(key_prefix):]] = info.Value
+		}
+		return true
+	})
+	return subtree
+}
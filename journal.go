@@ -0,0 +1,165 @@
+package sitepkg
+
+/*****************************************************************************\
+  The systemd journal.  Programs defining the journal options (see
+  SetJournalOpts) write their messages, when running under systemd (as
+  detected via JOURNAL_STREAM), to the journal via its native protocol,
+  with structured fields: MESSAGE, PRIORITY (from the level; see LogLevel),
+  SYSLOG_IDENTIFIER (the program name), and any fields given with Showf or
+  Logf, uppercased (i.e. "host" as HOST):
+
+    journalctl -t ibapi HOST=www.example.com
+
+  Print and Show output, and info messages, are sent at the info priority;
+  other messages only as per the log level.  Messages are also written to
+  any log destinations (see AddLogDestination), and, if the journal cannot
+  be written, to the console.  SetSlogLogger, if called later, takes
+  precedence.
+\*****************************************************************************/
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+const JournalSocket = "/run/systemd/journal/socket"
+
+var journalConn *net.UnixConn
+var journalLock sync.Mutex
+
+/*****************************************************************************\
+  Define the journal options: Journal, whether to write to the journal when
+  running under systemd.
+\*****************************************************************************/
+
+func SetJournalOpts() {
+	std.definePkgOpts(func() {
+		SetBoolOpt("Journal", "", true, true, "Write messages to the systemd journal when running under systemd")
+	})
+}
+
+/*****************************************************************************\
+  Check if the program is running under systemd with its stderr connected to
+  the journal: JOURNAL_STREAM is set to the device and inode of stderr.
+\*****************************************************************************/
+
+func UnderJournal() bool {
+	stream := os.Getenv("JOURNAL_STREAM")
+	if stream == "" {
+		return false
+	}
+	device, inode, ok := stderrDeviceInode()
+	return ok && stream == device+":"+inode
+}
+
+/*****************************************************************************\
+  Write messages to the journal, whether or not running under systemd.
+\*****************************************************************************/
+
+func UseJournal() error {
+	journalLock.Lock()
+	defer journalLock.Unlock()
+	if journalConn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: JournalSocket, Net: "unixgram"})
+		if err != nil {
+			return Error("Failure connecting to the journal: %v", err)
+		}
+		journalConn = conn
+	}
+	logSink = journalSink
+	return nil
+}
+
+/*****************************************************************************\
+  Use the journal if the Journal option is set (see SetJournalOpts) and the
+  program is running under systemd.
+\*****************************************************************************/
+
+func setJournalFromOptions() {
+	if journal, err := pkgOpt[bool]("Journal"); err != nil || !journal || !UnderJournal() {
+		return
+	}
+	if err := UseJournal(); err != nil {
+		ShowDebug("%v", err)
+	}
+}
+
+func journalSink(level LogLevel, message string, fields Fields) {
+	if level != LevelInfo && !consoleEnabled(level) {
+		writeDestinationLogs(level, message, fields)
+		return
+	}
+	if err := sendJournal(level, message, fields); err != nil {
+		ShowDebug("Failure writing to the journal: %v", err)
+		writeConsoleLog(level, message, fields)
+	}
+	writeDestinationLogs(level, message, fields)
+}
+
+/*****************************************************************************\
+  Send a message to the journal, as a datagram of fields.
+\*****************************************************************************/
+
+func sendJournal(level LogLevel, message string, fields Fields) error {
+	var entry bytes.Buffer
+
+	writeJournalField(&entry, "MESSAGE", message)
+	writeJournalField(&entry, "PRIORITY", journalPriority(level))
+	writeJournalField(&entry, "SYSLOG_IDENTIFIER", ProgramName)
+	for _, name := range sortedFieldNames(fields) {
+		if field := journalFieldName(name); field != "" {
+			writeJournalField(&entry, field, fmt.Sprintf("%v", fields[name]))
+		}
+	}
+	journalLock.Lock()
+	defer journalLock.Unlock()
+	if journalConn == nil {
+		return Error("not connected")
+	}
+	_, err := journalConn.Write(entry.Bytes())
+	return err
+}
+
+// Write a field: NAME=value, or, for values with newlines, the name, then
+// the length of the value (64 bit, little endian) and the value.
+func writeJournalField(entry *bytes.Buffer, name string, value string) {
+	if !strings.Contains(value, "\n") {
+		entry.WriteString(name + "=" + value + "\n")
+		return
+	}
+	entry.WriteString(name + "\n")
+	binary.Write(entry, binary.LittleEndian, uint64(len(value)))
+	entry.WriteString(value + "\n")
+}
+
+// Return a field name as the journal requires: uppercase letters, digits
+// and underscores, starting with a letter.
+func journalFieldName(name string) string {
+	field := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9'):
+			return r
+		}
+		return '_'
+	}, name)
+	return strings.TrimLeft(field, "_0123456789")
+}
+
+func journalPriority(level LogLevel) string {
+	switch level {
+	case LevelTrace, LevelDebug:
+		return "7"
+	case LevelInfo:
+		return "6"
+	case LevelWarn:
+		return "4"
+	}
+	return "3"
+}
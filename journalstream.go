@@ -0,0 +1,25 @@
+//go:build !windows
+
+package sitepkg
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+/*****************************************************************************\
+  Return the device and inode numbers of stderr, as strings.
+\*****************************************************************************/
+
+func stderrDeviceInode() (device string, inode string, ok bool) {
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return "", "", false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", "", false
+	}
+	return strconv.FormatUint(uint64(stat.Dev), 10), strconv.FormatUint(uint64(stat.Ino), 10), true
+}
@@ -0,0 +1,9 @@
+package sitepkg
+
+/*****************************************************************************\
+  There is no systemd journal on Windows.
+\*****************************************************************************/
+
+func stderrDeviceInode() (device string, inode string, ok bool) {
+	return "", "", false
+}
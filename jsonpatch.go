@@ -0,0 +1,154 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for computing the differences between two JSON documents, as an
+  RFC 7386 merge patch or an RFC 6902 JSON patch, so that tools can update
+  only the changed fields of an API object rather than overwrite it.
+\*****************************************************************************/
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type JSONPatchOp struct {
+	Op    string
+	Path  string
+	Value interface{}
+}
+
+/*****************************************************************************\
+  Marshal an operation, including the value (even if null) only for the
+  operations which take one.
+\*****************************************************************************/
+
+func (op JSONPatchOp) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(map[string]interface{}{"op": op.Op, "path": op.Path})
+	}
+	return json.Marshal(map[string]interface{}{"op": op.Op, "path": op.Path, "value": op.Value})
+}
+
+func unmarshalPair(original []byte, modified []byte) (interface{}, interface{}, error) {
+	var orig_doc, mod_doc interface{}
+	if err := unmarshalNumbers(original, &orig_doc); err != nil {
+		return nil, nil, Error("Bad original JSON document: %v", err)
+	}
+	if err := unmarshalNumbers(modified, &mod_doc); err != nil {
+		return nil, nil, Error("Bad modified JSON document: %v", err)
+	}
+	return orig_doc, mod_doc, nil
+}
+
+// Unmarshal a JSON document keeping its numbers as json.Number, so that
+// integers beyond 2^53 are neither rounded nor written as floats.
+func unmarshalNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	} else if _, err = decoder.Token(); err != io.EOF {
+		return Error("invalid data after the document")
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/*****************************************************************************\
+  Return the RFC 7386 merge patch which transforms original into modified.
+  Note that a merge patch cannot set a value to null, nor patch arrays other
+  than by replacing them.
+\*****************************************************************************/
+
+func MergePatch(original []byte, modified []byte) ([]byte, error) {
+	orig_doc, mod_doc, err := unmarshalPair(original, modified)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(orig_doc, mod_doc))
+}
+
+func mergePatch(original interface{}, modified interface{}) interface{} {
+	orig_map, orig_ok := original.(map[string]interface{})
+	mod_map, mod_ok := modified.(map[string]interface{})
+	if !orig_ok || !mod_ok {
+		return modified
+	}
+	patch := make(map[string]interface{})
+	for key, orig_value := range orig_map {
+		if _, ok := mod_map[key]; !ok {
+			patch[key] = nil
+		} else if !reflect.DeepEqual(orig_value, mod_map[key]) {
+			patch[key] = mergePatch(orig_value, mod_map[key])
+		}
+	}
+	for key, mod_value := range mod_map {
+		if _, ok := orig_map[key]; !ok {
+			patch[key] = mod_value
+		}
+	}
+	return patch
+}
+
+/*****************************************************************************\
+  Return the RFC 6902 JSON patch which transforms original into modified.
+  Objects are compared member by member; arrays and other values that differ
+  are replaced as a whole.
+\*****************************************************************************/
+
+func JSONPatch(original []byte, modified []byte) ([]byte, error) {
+	orig_doc, mod_doc, err := unmarshalPair(original, modified)
+	if err != nil {
+		return nil, err
+	}
+	ops := jsonPatch(nil, "", orig_doc, mod_doc)
+	if ops == nil {
+		ops = []JSONPatchOp{}
+	}
+	return json.Marshal(ops)
+}
+
+func jsonPatch(ops []JSONPatchOp, path string, original interface{}, modified interface{}) []JSONPatchOp {
+	if reflect.DeepEqual(original, modified) {
+		return ops
+	}
+	orig_map, orig_ok := original.(map[string]interface{})
+	mod_map, mod_ok := modified.(map[string]interface{})
+	if !orig_ok || !mod_ok {
+		return append(ops, JSONPatchOp{Op: "replace", Path: path, Value: modified})
+	}
+	for _, key := range sortedKeys(orig_map) {
+		if _, ok := mod_map[key]; !ok {
+			ops = append(ops, JSONPatchOp{Op: "remove", Path: path + "/" + escapePointer(key)})
+		}
+	}
+	for _, key := range sortedKeys(mod_map) {
+		key_path := path + "/" + escapePointer(key)
+		if orig_value, ok := orig_map[key]; !ok {
+			ops = append(ops, JSONPatchOp{Op: "add", Path: key_path, Value: mod_map[key]})
+		} else {
+			ops = jsonPatch(ops, key_path, orig_value, mod_map[key])
+		}
+	}
+	return ops
+}
+
+/*****************************************************************************\
+  Escape an object key for use in an RFC 6901 JSON pointer.
+\*****************************************************************************/
+
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
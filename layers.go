@@ -0,0 +1,83 @@
+package sitepkg
+
+/*****************************************************************************\
+  Config source layers.  Options are set from their defaults, then from each
+  layer in turn, later layers overriding earlier ones.  By default:
+
+    system  config files in the system dirs (PackageEtc, LocalEtc, ...)
+    user    config files in the user's home dir
+    env     the environment (see SetOptSources)
+    cli     the command line
+
+  SetConfigLayers reorders the layers, i.e. for daemons whose environment
+  must beat the user's config, or disables those omitted.  The command line
+  may be reordered, but not disabled.  Within the file layers, files are
+  read in the order described for configFiles.
+\*****************************************************************************/
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	LayerSystem      = "system"
+	LayerUser        = "user"
+	LayerEnv         = "env"
+	LayerCommandLine = "cli"
+)
+
+var DefaultConfigLayers = []string{LayerSystem, LayerUser, LayerEnv, LayerCommandLine}
+
+/*****************************************************************************\
+  Set the order of the config source layers, from lowest to highest
+  precedence.  Call before ConfigureOptions.
+\*****************************************************************************/
+
+func (c *Configurator) SetConfigLayers(layers ...string) error {
+	c.writeLock()
+	defer c.lock.Unlock()
+	seen := make(map[string]bool)
+	for _, layer := range layers {
+		if known, _ := InList(DefaultConfigLayers, layer); !known {
+			return Error("Unknown config layer \"%s\"; layers: %s", layer, strings.Join(DefaultConfigLayers, ", "))
+		} else if seen[layer] {
+			return Error("Config layer \"%s\" given more than once", layer)
+		}
+		seen[layer] = true
+	}
+	if !seen[LayerCommandLine] {
+		return Error("Config layer \"%s\" may not be disabled", LayerCommandLine)
+	}
+	c.layers = append([]string(nil), layers...)
+	return nil
+}
+
+/*****************************************************************************\
+  Return the config source layers, from lowest to highest precedence.
+\*****************************************************************************/
+
+func (c *Configurator) ConfigLayers() []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.layers == nil {
+		return append([]string(nil), DefaultConfigLayers...)
+	}
+	return append([]string(nil), c.layers...)
+}
+
+/*****************************************************************************\
+  Return the layer of a config dir: user for those in the home dir, and
+  system for the rest, including those in the alternate root (see SetRoot).
+\*****************************************************************************/
+
+func (c *Configurator) configDirLayer(dir string) string {
+	if c.Root != "" && strings.HasPrefix(dir, c.Root+"/") {
+		return LayerSystem
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" && home != "/" &&
+		(dir == home || strings.HasPrefix(dir, strings.TrimRight(home, "/")+"/")) {
+		return LayerUser
+	}
+	return LayerSystem
+}
@@ -0,0 +1,113 @@
+package sitepkg
+
+/*****************************************************************************\
+  List options: options whose values are lists of strings, given as comma
+  separated values on the command line and in config files.  In config files,
+  "Servers = a, b" replaces the list so far, and "Servers += c" appends to it,
+  i.e. to add to the servers of a site wide config file.  ShowConfig shows the
+  merged list, with the sources contributing to it.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+/*****************************************************************************\
+  Define an option of type list.
+\*****************************************************************************/
+
+func (c *Configurator) SetListOpt(name string, shortopt string, file bool, value []string, desc string) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	my_value := append([]string(nil), value...)
+	lc := c.optionKey(name)
+	c.checkRegistration(lc, "list", shortopt)
+	option := &Option{Name: name, Type: "list", ShortOpt: shortopt, Sources: fileSources(file),
+		Desc: desc, ListValue: &my_value, Default: append([]string(nil), value...), Source: "Default"}
+	recordAssignment(option, 0)
+	c.Config[lc] = option
+}
+
+/*****************************************************************************\
+  Retrieve an option value of type list.
+\*****************************************************************************/
+
+func (c *Configurator) GetListOpt(name string) (value []string, err error) {
+	c.noteRead(name)
+	if value, frozen, err := frozenGet[[]string](c, name, "GetListOpt"); frozen {
+		return append([]string(nil), value...), err
+	}
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	lc := c.optionKey(name)
+	option, ok := c.Config[lc]
+	if !ok {
+		return value, Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	option_type := option.Type
+	if option_type != "list" {
+		return value, Error("GetListOpt: bad call for %s \"%s\".", option_type, name)
+	}
+	return append([]string(nil), *option.ListValue...), nil
+}
+
+/*****************************************************************************\
+  Parse a comma separated list, ignoring empty items.
+\*****************************************************************************/
+
+func parseList(value string) (list []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+/*****************************************************************************\
+  Format a value as it would be given in a config file.
+\*****************************************************************************/
+
+func formatValue(value interface{}) string {
+	switch typed := value.(type) {
+	case []string:
+		return strings.Join(typed, ", ")
+	case []interface{}:
+		items := make([]string, len(typed))
+		for i, item := range typed {
+			items[i] = fmt.Sprintf("%v", item)
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+/*****************************************************************************\
+  Compare option values, which, for lists, are not comparable with ==.
+\*****************************************************************************/
+
+func valuesEqual(a interface{}, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+/*****************************************************************************\
+  Return the sources contributing to an option's value: for lists, those of
+  the assignment that last replaced the list and of the appends since, i.e.
+  "file:/etc/opt/ibapi/ibapi.conf + file:/home/me/.ibapi/ibapi.conf".
+\*****************************************************************************/
+
+func valueSources(option *Option) string {
+	var sources []string
+	for i := len(option.History) - 1; i >= 0; i-- {
+		sources = append([]string{option.History[i].Source}, sources...)
+		if !option.History[i].Append {
+			break
+		}
+	}
+	if len(sources) == 0 {
+		return option.Source
+	}
+	return strings.Join(sources, " + ")
+}
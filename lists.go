@@ -0,0 +1,217 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for reading and writing our common site list file formats: plain
+  lists, host lists and network lists.  All formats share the same basic
+  layout: one entry per line, with blank lines and "#" comment lines ignored,
+  and an optional trailing comment separated from the entry by white space.
+  For host and network lists, the first field of an entry is the host name or
+  network (CIDR), and any remaining white space separated fields are tags:
+
+    # Our DNS servers.
+    ns1.example.com   dns primary      # in the main data center
+    ns2.example.com   dns secondary
+    10.10.0.0/16      campus           # campus backbone
+\*****************************************************************************/
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type ListEntry struct {
+	Line    int
+	Text    string
+	Comment string
+}
+
+type HostRecord struct {
+	Name    string
+	Tags    []string
+	Comment string
+}
+
+type NetworkRecord struct {
+	Network *net.IPNet
+	Tags    []string
+	Comment string
+}
+
+/*****************************************************************************\
+  Read the entries of a list file, keeping each entry's line number and any
+  trailing comment.  This is what ReadListFromFile is built upon.
+\*****************************************************************************/
+
+func ReadListEntriesFromFile(filename string) (entries []ListEntry, err error) {
+
+	var line_no int
+
+	if filename == "" {
+		return entries, Error("Bad call: filename not defined.")
+	} else if exists, err := FileExists(filename); err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, Error("No such file \"%s\".", filename)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, Error("Error opening file \"%s\": %v", filename, err)
+	}
+	defer file.Close()
+
+	// Define a trailing comment: one or more spaces/tabs followed by '#.*':
+	comment := regexp.MustCompile("[ \t]+#")
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line_no++
+		// Remove leading spaces and tabs:
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		// Skip comment (#) lines:
+		if strings.HasPrefix(line, "#") {
+			continue
+		} else if line == "" {
+			continue
+		}
+		entry := ListEntry{Line: line_no}
+		// Split line into a slice of at most 2 strings, spitting by our regexp:
+		slice := comment.Split(line, 2)
+		entry.Text = strings.TrimRight(slice[0], " \t")
+		if len(slice) == 2 {
+			entry.Comment = strings.TrimSpace(slice[1])
+		}
+		entries = append(entries, entry)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, Error("Error reading file \"%s\": %v", filename, err)
+	}
+	return entries, nil
+}
+
+/*****************************************************************************\
+  Read a host list file.  The host names are lowercased, and a trailing dot
+  is removed.
+\*****************************************************************************/
+
+func ReadHostList(filename string) (hosts []HostRecord, err error) {
+
+	entries, err := ReadListEntriesFromFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		fields := strings.Fields(entry.Text)
+		name := strings.TrimSuffix(strings.ToLower(fields[0]), ".")
+		if name == "" {
+			return nil, Error("Bad host name \"%s\" at line %d of file %s", fields[0], entry.Line, filename)
+		}
+		hosts = append(hosts, HostRecord{Name: name, Tags: fields[1:], Comment: entry.Comment})
+	}
+	return hosts, nil
+}
+
+/*****************************************************************************\
+  Read a network list file.  Each network must be in CIDR notation, and must
+  not have any host bits set (i.e.: 10.10.0.0/16, not 10.10.1.1/16).
+\*****************************************************************************/
+
+func ReadNetworkList(filename string) (networks []NetworkRecord, err error) {
+
+	entries, err := ReadListEntriesFromFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		fields := strings.Fields(entry.Text)
+		ip, network, err := net.ParseCIDR(fields[0])
+		if err != nil {
+			return nil, Error("Bad network \"%s\" at line %d of file %s", fields[0], entry.Line, filename)
+		} else if !ip.Equal(network.IP) {
+			return nil, Error("Network \"%s\" at line %d of file %s has host bits set (should be %s)",
+				fields[0], entry.Line, filename, network)
+		}
+		networks = append(networks, NetworkRecord{Network: network, Tags: fields[1:], Comment: entry.Comment})
+	}
+	return networks, nil
+}
+
+/*****************************************************************************\
+  Check if the record has the specified tag.
+\*****************************************************************************/
+
+func (record HostRecord) HasTag(tag string) bool {
+	return hasTag(record.Tags, tag)
+}
+
+func (record NetworkRecord) HasTag(tag string) bool {
+	return hasTag(record.Tags, tag)
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+/*****************************************************************************\
+  Write a host list file, in the format read by ReadHostList.
+\*****************************************************************************/
+
+func WriteHostList(filename string, hosts []HostRecord) error {
+	var lines []string
+	for _, host := range hosts {
+		lines = append(lines, formatListLine(host.Name, host.Tags, host.Comment))
+	}
+	return writeListFile(filename, lines)
+}
+
+/*****************************************************************************\
+  Write a network list file, in the format read by ReadNetworkList.
+\*****************************************************************************/
+
+func WriteNetworkList(filename string, networks []NetworkRecord) error {
+	var lines []string
+	for _, network := range networks {
+		if network.Network == nil {
+			return Error("Bad call: network not defined.")
+		}
+		lines = append(lines, formatListLine(network.Network.String(), network.Tags, network.Comment))
+	}
+	return writeListFile(filename, lines)
+}
+
+func formatListLine(name string, tags []string, comment string) string {
+	line := name
+	if len(tags) > 0 {
+		line += "  " + strings.Join(tags, " ")
+	}
+	if comment != "" {
+		line += "  # " + comment
+	}
+	return line
+}
+
+func writeListFile(filename string, lines []string) error {
+
+	if filename == "" {
+		return Error("Bad call: filename not defined.")
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return Error("Error creating file \"%s\": %v", filename, err)
+	}
+	for _, line := range lines {
+		Fprintln(file, "%s", line)
+	}
+	if err = file.Close(); err != nil {
+		return Error("Error closing file \"%s\": %s", filename, err)
+	}
+	return nil
+}
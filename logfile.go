@@ -0,0 +1,336 @@
+package sitepkg
+
+/*****************************************************************************\
+  The log file.  If the LogFile option is set, all output (that of Print,
+  Show, Warn, the leveled logging functions, etc) is also written to the
+  log file, which is rotated:
+
+    LogFileMaxSize   when it reaches the size, in MB (0, the default, for
+                     no size limit)
+    LogFileMaxAge    when it is older than the duration (i.e. "24h", "7d";
+                     "", the default, for no age limit)
+
+  keeping LogFileKeep (default 5) rotated files, as file.1 (the most recent)
+  to file.N, compressed (file.1.gz, etc) if LogFileCompress is set.  Rotated
+  files are compressed in the background, so that logging does not wait on
+  it; Close waits for any compression still running.
+
+  Several processes may share a log file: lines are appended (O_APPEND), a
+  lock file (file.lock) serializes rotation, whose time it records, and
+  processes reopen the file once another has rotated it.
+\*****************************************************************************/
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotationCheckInterval = time.Minute
+
+type LogRotation struct {
+	MaxSize  int64
+	MaxAge   time.Duration
+	Keep     int
+	Compress bool
+}
+
+type RotatingFile struct {
+	Filename  string
+	Rotation  LogRotation
+	file      *os.File
+	lastCheck time.Time
+	lock      sync.Mutex
+	compress  sync.WaitGroup
+}
+
+var logFile *RotatingFile
+
+/*****************************************************************************\
+  Open a log file for appending, rotating it first if due.
+\*****************************************************************************/
+
+func OpenRotatingFile(filename string, rotation LogRotation) (*RotatingFile, error) {
+	rf := &RotatingFile{Filename: filename, Rotation: rotation}
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	if err := rf.rotateIfDue(0, true); err != nil {
+		rf.file.Close()
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return Error("Failure opening log file %s: %v", rf.Filename, err)
+	}
+	rf.file = file
+	return nil
+}
+
+/*****************************************************************************\
+  Append to the log file, reopening it if another process rotated it, and
+  rotating it if due.
+\*****************************************************************************/
+
+func (rf *RotatingFile) Write(data []byte) (int, error) {
+	rf.lock.Lock()
+	defer rf.lock.Unlock()
+	if rf.file == nil {
+		return 0, Error("Log file %s is closed", rf.Filename)
+	}
+	if rf.rotated() {
+		rf.file.Close()
+		if err := rf.open(); err != nil {
+			rf.file = nil
+			return 0, err
+		}
+	}
+	if err := rf.rotateIfDue(int64(len(data)), false); err != nil {
+		// Not Warn: the output lock is held, and it would write to the log file.
+		os.Stderr.WriteString(MessagePrefix() + "Warning: " + err.Error() + "\n")
+	}
+	return rf.file.Write(data)
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.lock.Lock()
+	defer rf.compress.Wait()
+	defer rf.lock.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+// Check if the file open is no longer the log file: it was rotated.
+func (rf *RotatingFile) rotated() bool {
+	path_info, err := os.Stat(rf.Filename)
+	if err != nil {
+		return true
+	}
+	file_info, err := rf.file.Stat()
+	return err != nil || !os.SameFile(path_info, file_info)
+}
+
+/*****************************************************************************\
+  Rotate the log file if writing size more bytes would exceed MaxSize, or if
+  it is older than MaxAge (checked at most every rotationCheckInterval,
+  unless forced).  Called with rf.lock held.
+\*****************************************************************************/
+
+func (rf *RotatingFile) rotateIfDue(size int64, force_check bool) error {
+	if !rf.rotationDue(size, force_check) {
+		return nil
+	}
+	unlock, err := lockFile(rf.Filename + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	// Another process may have rotated it while we waited for the lock.
+	if rf.rotated() {
+		rf.file.Close()
+		return rf.open()
+	} else if !rf.rotationDue(size, true) {
+		return nil
+	}
+	rotate_err := rf.rotate()
+	if !rf.rotated() {
+		return rotate_err
+	}
+	rf.file.Close()
+	now := time.Now()
+	os.Chtimes(rf.Filename+".lock", now, now)
+	if err = rf.open(); err != nil {
+		return err
+	}
+	return rotate_err
+}
+
+func (rf *RotatingFile) rotationDue(size int64, force_check bool) bool {
+	info, err := rf.file.Stat()
+	if err != nil || info.Size() == 0 {
+		return false
+	}
+	if rf.Rotation.MaxSize > 0 && info.Size()+size > rf.Rotation.MaxSize {
+		return true
+	}
+	if rf.Rotation.MaxAge <= 0 || (!force_check && time.Since(rf.lastCheck) < rotationCheckInterval) {
+		return false
+	}
+	rf.lastCheck = time.Now()
+	lock_info, err := os.Stat(rf.Filename + ".lock")
+	if os.IsNotExist(err) {
+		// Age is counted from the first rotation check.
+		if file, err := os.OpenFile(rf.Filename+".lock", os.O_CREATE|os.O_WRONLY, 0644); err == nil {
+			file.Close()
+		}
+		return false
+	}
+	return err == nil && time.Since(lock_info.ModTime()) > rf.Rotation.MaxAge
+}
+
+/*****************************************************************************\
+  Shift the rotated files (file.N is removed), then rename the log file as
+  file.1, and, if so configured, start compressing it: in a goroutine, as
+  the rotation and output locks are held.
+\*****************************************************************************/
+
+func (rf *RotatingFile) rotate() error {
+	keep := rf.Rotation.Keep
+	if keep < 1 {
+		keep = 1
+	}
+	rotated := func(n int) string {
+		name := rf.Filename + "." + strconv.Itoa(n)
+		if _, err := os.Stat(name + ".gz"); err == nil {
+			return name + ".gz"
+		}
+		return name
+	}
+	os.Remove(rotated(keep))
+	for n := keep - 1; n >= 1; n-- {
+		from := rotated(n)
+		to := rf.Filename + "." + strconv.Itoa(n+1)
+		if strings.HasSuffix(from, ".gz") {
+			to += ".gz"
+		}
+		if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+			return Error("Failure rotating log file %s: %v", from, err)
+		}
+	}
+	first := rf.Filename + ".1"
+	if err := os.Rename(rf.Filename, first); err != nil {
+		return Error("Failure rotating log file %s: %v", rf.Filename, err)
+	}
+	if rf.Rotation.Compress {
+		rf.compress.Add(1)
+		go func() {
+			defer rf.compress.Done()
+			if err := rf.compressRotated(keep); err != nil {
+				// Not Warn: the output may be teed to the log file.
+				os.Stderr.WriteString(MessagePrefix() + "Warning: " + err.Error() + "\n")
+			}
+		}()
+	}
+	return nil
+}
+
+// Compress the rotated files not yet compressed, holding the lock file, so
+// that no rotation (of this process or another) shifts them meanwhile.  A
+// file rotated again before its compression started is compressed as
+// file.2, etc.
+func (rf *RotatingFile) compressRotated(keep int) error {
+	unlock, err := lockFile(rf.Filename + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	for n := 1; n <= keep; n++ {
+		name := rf.Filename + "." + strconv.Itoa(n)
+		if _, err = os.Stat(name); err != nil {
+			continue
+		} else if err = compressFile(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Compress a file as file.gz, removing the file.  The file.gz appears only
+// once complete.
+func compressFile(filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return Error("Failure compressing %s: %v", filename, err)
+	}
+	defer in.Close()
+	temp := filename + ".gz.tmp"
+	out, err := os.OpenFile(temp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return Error("Failure compressing %s: %v", filename, err)
+	}
+	gz := gzip.NewWriter(out)
+	if _, err = io.Copy(gz, in); err == nil {
+		err = gz.Close()
+	}
+	if close_err := out.Close(); err == nil {
+		err = close_err
+	}
+	if err == nil {
+		err = os.Rename(temp, filename+".gz")
+	}
+	if err != nil {
+		os.Remove(temp)
+		return Error("Failure compressing %s: %v", filename, err)
+	}
+	return os.Remove(filename)
+}
+
+/*****************************************************************************\
+  Open the log file per the LogFile options, and tee the output to it.  If
+  LogFile is not set (or not defined), or the log file is already open, do
+  nothing.
+\*****************************************************************************/
+
+func setLogFileFromOptions() error {
+	filename, _ := pkgOpt[string]("LogFile")
+	if filename == "" || (logFile != nil && logFile.Filename == filename) {
+		return nil
+	}
+	var rotation LogRotation
+	max_size, _ := pkgOpt[int]("LogFileMaxSize")
+	rotation.MaxSize = int64(max_size) * 1024 * 1024
+	if max_age, _ := pkgOpt[string]("LogFileMaxAge"); max_age != "" {
+		age, err := parseAge(max_age)
+		if err != nil {
+			return CategoryError(UsageError, "Option \"--LogFileMaxAge\": %v", err)
+		}
+		rotation.MaxAge = age
+	}
+	rotation.Keep, _ = pkgOpt[int]("LogFileKeep")
+	rotation.Compress, _ = pkgOpt[bool]("LogFileCompress")
+
+	rf, err := OpenRotatingFile(filename, rotation)
+	if err != nil {
+		return CategoryError(ConfigError, "%v", err)
+	}
+	TeeOutput(rf)
+	RegisterCleanup(func() { rf.Close() })
+	return nil
+}
+
+// Parse a duration, allowing days ("7d").
+func parseAge(value string) (time.Duration, error) {
+	if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil && strings.HasSuffix(value, "d") {
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return ParseDuration(value)
+}
+
+/*****************************************************************************\
+  Tee all output (DefaultPrint, DefaultShow, DefaultErr and DefaultDebug)
+  to the specified log file, replacing any log file teed to before.
+\*****************************************************************************/
+
+func TeeOutput(rf *RotatingFile) {
+	if logFile != nil {
+		removeOutputTee(logFile)
+		logFile.Close()
+	}
+	logFile = rf
+	addOutputTee(rf)
+}
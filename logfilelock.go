@@ -0,0 +1,28 @@
+//go:build !windows
+
+package sitepkg
+
+import (
+	"os"
+	"syscall"
+)
+
+/*****************************************************************************\
+  Take an exclusive lock on the specified lock file (created if need be),
+  waiting for it.  Return the function releasing it.
+\*****************************************************************************/
+
+func lockFile(filename string) (func(), error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, Error("Failure opening lock file %s: %v", filename, err)
+	}
+	if err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, Error("Failure locking %s: %v", filename, err)
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
@@ -0,0 +1,17 @@
+package sitepkg
+
+import (
+	"os"
+)
+
+/*****************************************************************************\
+  File locks are not available on Windows: the lock file is only created.
+\*****************************************************************************/
+
+func lockFile(filename string) (func(), error) {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, Error("Failure opening lock file %s: %v", filename, err)
+	}
+	return func() { file.Close() }, nil
+}
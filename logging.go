@@ -0,0 +1,196 @@
+package sitepkg
+
+/*****************************************************************************\
+  Leveled logging.  Tracef, Debugf, Infof, Warnf and Errorf log a message at
+  their level (see LogLevel): to the console if the level is at or above the
+  log level (see SetLogLevel), and to each log destination (see
+  AddLogDestination) whose own threshold it is at or above, so that i.e.
+  debug messages go to a file while only info messages go to the console:
+
+    sitepkg.SetLogLevel(sitepkg.LevelInfo)
+    sitepkg.AddLogDestination("file", log_file, sitepkg.LevelDebug)
+
+  On the console, trace and debug messages go to DefaultDebug ("DEBUG: ..."),
+  info messages to DefaultShow ("prog: ..."), and warnings and errors to
+  DefaultErr ("prog: Warning: ...", "prog: Error: ..."), unless routed
+  elsewhere (see SetRoute).  Destinations get "prog: LEVEL: ..." lines.
+  ShowTrace, ShowDebug, ShowInfo and Warn are equivalent to Tracef, Debugf,
+  Infof and Warnf.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+type logDestination struct {
+	name   string
+	writer io.Writer
+	level  LogLevel
+}
+
+var logDestinations []*logDestination
+var logDestinationsLock sync.RWMutex
+
+func Tracef(format string, a ...interface{}) {
+	logf(LevelTrace, format, a...)
+}
+
+func Debugf(format string, a ...interface{}) {
+	logf(LevelDebug, format, a...)
+}
+
+func Infof(format string, a ...interface{}) {
+	logf(LevelInfo, format, a...)
+}
+
+func Warnf(format string, a ...interface{}) {
+	logf(LevelWarn, format, a...)
+}
+
+func Errorf(format string, a ...interface{}) {
+	logf(LevelError, format, a...)
+}
+
+/*****************************************************************************\
+  Add a log destination: messages at or above the specified level are
+  written to w, whatever the log level of the console.  A destination of
+  the same name is replaced.
+\*****************************************************************************/
+
+func AddLogDestination(name string, w io.Writer, level LogLevel) {
+	logDestinationsLock.Lock()
+	defer logDestinationsLock.Unlock()
+	for _, destination := range logDestinations {
+		if destination.name == name {
+			destination.writer, destination.level = w, level
+			return
+		}
+	}
+	logDestinations = append(logDestinations, &logDestination{name: name, writer: w, level: level})
+}
+
+func RemoveLogDestination(name string) {
+	logDestinationsLock.Lock()
+	defer logDestinationsLock.Unlock()
+	for i, destination := range logDestinations {
+		if destination.name == name {
+			logDestinations = append(logDestinations[:i], logDestinations[i+1:]...)
+			return
+		}
+	}
+}
+
+/*****************************************************************************\
+  Set the threshold of a log destination.
+\*****************************************************************************/
+
+func SetLogDestinationLevel(name string, level LogLevel) error {
+	logDestinationsLock.Lock()
+	defer logDestinationsLock.Unlock()
+	for _, destination := range logDestinations {
+		if destination.name == name {
+			destination.level = level
+			return nil
+		}
+	}
+	return Error("No such log destination \"%s\"", name)
+}
+
+/*****************************************************************************\
+  Log a message at the specified level, to the log sink if set, else to the
+  console and the log destinations.  On the console, the Debug and Verbose
+  globals, if set directly, also enable debug and info messages.
+\*****************************************************************************/
+
+func logf(level LogLevel, format string, a ...interface{}) {
+	logFields(level, nil, fmt.Sprintf(format, a...))
+}
+
+func logFields(level LogLevel, fields Fields, message string) {
+	if logSink != nil {
+		logSink(level, message, fields)
+		return
+	}
+	writeLog(level, message, fields)
+}
+
+func consoleEnabled(level LogLevel) bool {
+	return LogEnabled(level) || (level == LevelDebug && Debug) || (level == LevelInfo && Verbose)
+}
+
+/*****************************************************************************\
+  Check if messages of the specified level are logged anywhere: on the
+  console, or to any of the log destinations.
+\*****************************************************************************/
+
+func logWanted(level LogLevel) bool {
+	if consoleEnabled(level) {
+		return true
+	}
+	logDestinationsLock.RLock()
+	defer logDestinationsLock.RUnlock()
+	for _, destination := range logDestinations {
+		if level >= destination.level {
+			return true
+		}
+	}
+	return false
+}
+
+func writeLog(level LogLevel, message string, fields Fields) {
+	writeConsoleLog(level, message, fields)
+	writeDestinationLogs(level, message, fields)
+}
+
+func writeConsoleLog(level LogLevel, message string, fields Fields) {
+	if !consoleEnabled(level) {
+		return
+	}
+	writer := classWriter(levelClass(level))
+	if logJSON {
+		writeJSONLog(writer, level, message, fields)
+		return
+	}
+	text := message + formatFields(fields)
+	switch level {
+	case LevelTrace:
+		Fprintln(writer, "%s", Colorize(writer, ColorDebug, stampLine("TRACE: "+text)))
+	case LevelDebug:
+		Fprintln(writer, "%s", Colorize(writer, ColorDebug, stampLine("DEBUG: "+text)))
+	case LevelInfo:
+		Fshow(writer, "%s", text)
+	case LevelWarn:
+		Fwarn(writer, "%s", text)
+	default:
+		Fprintln(writer, "%s", Colorize(writer, ColorError, stampLine(MessagePrefix()+"Error: "+text)))
+	}
+}
+
+func levelClass(level LogLevel) MessageClass {
+	switch level {
+	case LevelTrace, LevelDebug:
+		return ClassDebug
+	case LevelInfo:
+		return ClassShow
+	case LevelWarn:
+		return ClassWarn
+	}
+	return ClassError
+}
+
+func writeDestinationLogs(level LogLevel, message string, fields Fields) {
+	logDestinationsLock.RLock()
+	defer logDestinationsLock.RUnlock()
+	for _, destination := range logDestinations {
+		if level < destination.level {
+			continue
+		} else if logJSON {
+			writeJSONLog(destination.writer, level, message, fields)
+		} else {
+			Fprintln(destination.writer, timestampPrefix()+"%s%s: %s%s", MessagePrefix(), strings.ToUpper(level.String()), message, formatFields(fields))
+		}
+	}
+}
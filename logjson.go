@@ -0,0 +1,148 @@
+package sitepkg
+
+/*****************************************************************************\
+  JSON log lines.  With LogFormat=json, the messages of Show, Warn, Log and
+  the leveled logging functions (see Tracef, etc) are written as JSON lines,
+  for ingestion by a log pipeline:
+
+    {"time":"2024-05-01T10:00:00.000-04:00","level":"info","program":"ibapi",
+     "message":"Added host www.example.com","host":"www.example.com"}
+
+  Fields are added with the Showf and Logf variants:
+
+    sitepkg.Showf(sitepkg.Fields{"host": host}, "Added host %s", host)
+
+  In the (default) text format, fields follow the message as key=value.
+  Print and Println output, the program's own output, is never JSON lines.
+\*****************************************************************************/
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+type Fields map[string]interface{}
+
+var logJSON bool
+
+/*****************************************************************************\
+  Set the log format: "text" or "json".  ConfigureOptions sets it from the
+  LogFormat option.
+\*****************************************************************************/
+
+func SetLogFormat(format string) error {
+	switch strings.ToLower(format) {
+	case LogFormatText, "":
+		logJSON = false
+	case LogFormatJSON:
+		logJSON = true
+	default:
+		return Error("Bad log format \"%s\": expected text or json", format)
+	}
+	return nil
+}
+
+func setLogFormatFromOptions() error {
+	format, _ := pkgOpt[string]("LogFormat")
+	if err := SetLogFormat(format); err != nil {
+		return WrapError(UsageError, err)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Show a message, as Show, with fields.
+\*****************************************************************************/
+
+func Showf(fields Fields, format string, a ...interface{}) {
+	message := fmt.Sprintf(format, a...)
+	if logSink != nil {
+		logSink(LevelInfo, message, fields)
+	} else if logJSON {
+		writeJSONLog(classWriter(ClassShow), LevelInfo, message, fields)
+	} else {
+		Fshow(classWriter(ClassShow), "%s%s", message, formatFields(fields))
+	}
+}
+
+/*****************************************************************************\
+  Log a message at the specified level, as Tracef, etc, with fields.
+\*****************************************************************************/
+
+func Logf(level LogLevel, fields Fields, format string, a ...interface{}) {
+	logFields(level, fields, fmt.Sprintf(format, a...))
+}
+
+/*****************************************************************************\
+  Write a JSON log line: time, level, program and message, then the fields
+  (other than those), sorted by name.
+\*****************************************************************************/
+
+func writeJSONLog(w io.Writer, level LogLevel, message string, fields Fields) {
+	var line bytes.Buffer
+	line.WriteString("{")
+	writeJSONField(&line, "time", time.Now().Format("2006-01-02T15:04:05.000Z07:00"))
+	for _, entry := range []struct {
+		name  string
+		value interface{}
+	}{{"level", level.String()}, {"program", ProgramName}, {"message", message}} {
+		line.WriteString(",")
+		writeJSONField(&line, entry.name, entry.value)
+	}
+	for _, name := range sortedFieldNames(fields) {
+		if name == "time" || name == "level" || name == "program" || name == "message" {
+			continue
+		}
+		line.WriteString(",")
+		writeJSONField(&line, name, fields[name])
+	}
+	line.WriteString("}\n")
+	writeOutput(w, line.String())
+}
+
+func writeJSONField(line *bytes.Buffer, name string, value interface{}) {
+	json_name, _ := json.Marshal(name)
+	json_value, err := json.Marshal(value)
+	if err != nil {
+		json_value, _ = json.Marshal(fmt.Sprintf("%v", value))
+	}
+	line.Write(json_name)
+	line.WriteString(":")
+	line.Write(json_value)
+}
+
+/*****************************************************************************\
+  Return the fields as text: " key=value ...", sorted by name, with values
+  containing spaces quoted.
+\*****************************************************************************/
+
+func formatFields(fields Fields) string {
+	var text strings.Builder
+	for _, name := range sortedFieldNames(fields) {
+		value := fmt.Sprintf("%v", fields[name])
+		if value == "" || strings.ContainsAny(value, " \t\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		text.WriteString(" " + name + "=" + value)
+	}
+	return text.String()
+}
+
+func sortedFieldNames(fields Fields) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,134 @@
+package sitepkg
+
+/*****************************************************************************\
+  Log levels.  The LogLevel option (trace, debug, info, warn or error)
+  controls the leveled output helpers: ShowTrace, ShowDebug, ShowInfo and
+  Warn, shown at their level and below.  The default level is warn.  The
+  output of Print and Show is the program's output, and is not leveled.
+
+  The Verbose, Quiet, Quieter and Debug globals follow the level: Debug at
+  debug and below, Verbose at info and below, and Quiet and Quieter at
+  error.  If LogLevel is not set, the Debug and Verbose options set the
+  debug and info levels, and Quiet and Quieter set their globals alone:
+  warnings are still shown.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"strings"
+)
+
+type LogLevel int
+
+const (
+	LevelTrace LogLevel = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var logLevelNames = []string{"trace", "debug", "info", "warn", "error"}
+
+var logLevel = LevelWarn
+
+func (level LogLevel) String() string {
+	if level < LevelTrace || level > LevelError {
+		return fmt.Sprintf("LogLevel(%d)", int(level))
+	}
+	return logLevelNames[level]
+}
+
+/*****************************************************************************\
+  Return the log level of the specified name, case insensitively ("warning"
+  is taken as "warn").
+\*****************************************************************************/
+
+func ParseLogLevel(name string) (LogLevel, error) {
+	lc := strings.ToLower(strings.TrimSpace(name))
+	if lc == "warning" {
+		lc = "warn"
+	}
+	for level, level_name := range logLevelNames {
+		if lc == level_name {
+			return LogLevel(level), nil
+		}
+	}
+	return LevelWarn, Error("Bad log level \"%s\": expected one of %s", name, strings.Join(logLevelNames, ", "))
+}
+
+func GetLogLevel() LogLevel {
+	return logLevel
+}
+
+/*****************************************************************************\
+  Set the log level, and the Verbose, Quiet, Quieter and Debug globals to
+  match.  ConfigureOptions sets it from the options; programs may set it
+  later to override them.
+\*****************************************************************************/
+
+func SetLogLevel(level LogLevel) {
+	logLevel = level
+	Debug = level <= LevelDebug
+	Verbose = level <= LevelInfo
+	Quiet = level >= LevelError
+	Quieter = level >= LevelError
+}
+
+/*****************************************************************************\
+  Check if messages of the specified level are shown.
+\*****************************************************************************/
+
+func LogEnabled(level LogLevel) bool {
+	return level >= logLevel
+}
+
+/*****************************************************************************\
+  Set the log format (see SetLogFormat), the timestamps (see Timestamps),
+  the color mode (see Color), the message prefix (see MessagePrefix), the
+  journal (see SetJournalOpts), the log file (see LogFile), the emailing of
+  the output (see MailList), the debug categories (see SetDebugOpts) and the
+  log level from the options: the log level from LogLevel if set, else the
+  Debug, Verbose, Quiet and Quieter options.  Note that these options may
+  not exist for a given program.
+\*****************************************************************************/
+
+func setLoggingFromOptions() error {
+	if err := setLogFormatFromOptions(); err != nil {
+		return err
+	}
+	if err := setTimestampsFromOptions(); err != nil {
+		return err
+	} else if err := setColorFromOptions(); err != nil {
+		return err
+	} else if err := setPrefixFromOptions(); err != nil {
+		return err
+	}
+	setJournalFromOptions()
+	if err := setLogFileFromOptions(); err != nil {
+		return err
+	}
+	setMailFromOptions()
+	debug := debugFromOptions()
+	if name, _ := pkgOpt[string]("LogLevel"); name != "" {
+		level, err := ParseLogLevel(name)
+		if err != nil {
+			return WrapError(UsageError, err)
+		}
+		SetLogLevel(level)
+		return nil
+	}
+	verbose, _ := pkgOpt[bool]("Verbose")
+	quiet, _ := pkgOpt[bool]("Quiet")
+	quieter, _ := pkgOpt[bool]("Quieter")
+	switch {
+	case debug:
+		SetLogLevel(LevelDebug)
+	case verbose:
+		SetLogLevel(LevelInfo)
+	default:
+		SetLogLevel(LevelWarn)
+		Quiet, Quieter = quiet, quieter
+	}
+	return nil
+}
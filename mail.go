@@ -0,0 +1,131 @@
+package sitepkg
+
+/*****************************************************************************\
+  Emailed output.  If the MailList option is set, all output (that of Print,
+  Show, Warn, the leveled logging functions, etc) is also captured, and at
+  exit (see Exit) emailed to the specified addresses, with a subject giving
+  the program, the host and the exit status:
+
+    ibapi on ns1.example.com: exit status 1
+
+  as cron does, but for all runs, interactive or not.  With MailOnError, the
+  output is emailed only if the exit status is not 0; no email is sent if
+  there is no output.  The output is sent via sendmail, or, if MailServer is
+  set, via that SMTP server.
+\*****************************************************************************/
+
+import (
+	"bytes"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SendmailPath = "/usr/sbin/sendmail"
+
+type mailCapture struct {
+	buffer bytes.Buffer
+	lock   sync.Mutex
+}
+
+var mailOutput *mailCapture
+
+func (capture *mailCapture) Write(data []byte) (int, error) {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+	return capture.buffer.Write(data)
+}
+
+func (capture *mailCapture) String() string {
+	capture.lock.Lock()
+	defer capture.lock.Unlock()
+	return capture.buffer.String()
+}
+
+/*****************************************************************************\
+  Start capturing the output if MailList is set (and not already doing so),
+  and register the cleanup emailing it.
+\*****************************************************************************/
+
+func setMailFromOptions() {
+	mail_list, _ := pkgOpt[string]("MailList")
+	if mail_list == "" || mailOutput != nil {
+		return
+	}
+	mailOutput = &mailCapture{}
+	addOutputTee(mailOutput)
+	RegisterCleanup(mailCapturedOutput)
+}
+
+func mailCapturedOutput() {
+	capture := mailOutput
+	removeOutputTee(capture)
+	mailOutput = nil
+
+	output := capture.String()
+	if on_error, _ := pkgOpt[bool]("MailOnError"); output == "" || (on_error && exitStatus == 0) {
+		return
+	}
+	mail_list, _ := pkgOpt[string]("MailList")
+	var recipients []string
+	for _, address := range strings.Split(mail_list, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+	hostname, _ := os.Hostname()
+	subject := ProgramName + " on " + hostname + ": exit status " + strconv.Itoa(exitStatus)
+	if err := SendMail(recipients, subject, output); err != nil {
+		Fwarn(DefaultErr, "Failure emailing the output to %s: %v", mail_list, err)
+	}
+}
+
+/*****************************************************************************\
+  Email a plain text message, from MailFrom (default user@host), via the
+  MailServer SMTP server if set, else via sendmail.
+\*****************************************************************************/
+
+func SendMail(recipients []string, subject string, body string) error {
+	if len(recipients) == 0 {
+		return Error("No recipients")
+	}
+	from, _ := pkgOpt[string]("MailFrom")
+	if from == "" {
+		hostname, _ := os.Hostname()
+		from = InvokingUser() + "@" + hostname
+	}
+	var message bytes.Buffer
+	message.WriteString("From: " + from + "\r\n")
+	message.WriteString("To: " + strings.Join(recipients, ", ") + "\r\n")
+	message.WriteString("Subject: " + subject + "\r\n")
+	message.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+
+	server, _ := pkgOpt[string]("MailServer")
+	if server == "" {
+		return sendmail(recipients, message.Bytes())
+	}
+	if !strings.Contains(server, ":") {
+		server += ":25"
+	}
+	return smtp.SendMail(server, nil, from, recipients, message.Bytes())
+}
+
+func sendmail(recipients []string, message []byte) error {
+	// Not CommandContext: the output is emailed also on a timeout.
+	cmd := exec.Command(SendmailPath, append([]string{"-oi", "--"}, recipients...)...)
+	cmd.Stdin = bytes.NewReader(message)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if text := strings.TrimSpace(string(output)); text != "" {
+			return Error("%s: %v: %s", SendmailPath, err, text)
+		}
+		return Error("%s: %v", SendmailPath, err)
+	}
+	return nil
+}
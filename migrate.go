@@ -0,0 +1,551 @@
+package sitepkg
+
+/*****************************************************************************\
+  Option and section renames.  When an option (or config file section) is
+  renamed across package versions, declare it with RenameOption (or
+  RenameSection); config files using the old name are still read, with a
+  one-time warning, and --MigrateConfig rewrites the config files to use the
+  new names.
+
+  Changes beyond renames are registered with RegisterMigration, as
+  transforms keyed by the config schema version introducing them:
+
+    sitepkg.RegisterMigration(2, "split Server into Host and Port",
+        sitepkg.SplitValueTransform("Server", ":", "Host", "Port"))
+    sitepkg.RegisterMigration(3, "host:create is now host:add",
+        sitepkg.MoveSectionTransform("host:create", "host:add"))
+
+  --MigrateConfig applies them, in version order, after the renames, keeping
+  a backup of each file changed, and sets the file's schema version with a
+  header at the top of the file:
+
+    config-version = 3
+
+  Files with an older (or no) header are migrated in memory when read, and
+  files with a newer one are read with a warning.
+\*****************************************************************************/
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ConfVersionHeader = "config-version"
+
+type rename struct {
+	newName string
+	version string
+}
+
+/*****************************************************************************\
+  A config file transform: given the lines of a config file, return them
+  transformed, and the number of changes made.  Transforms must leave lines
+  they do not change as is, and do nothing if there is nothing to migrate.
+\*****************************************************************************/
+
+type ConfigTransform func(lines []string) ([]string, int)
+
+type configMigration struct {
+	version   int
+	desc      string
+	transform ConfigTransform
+}
+
+/*****************************************************************************\
+  Declare that the option old_name was renamed new_name in the specified
+  package version.
+\*****************************************************************************/
+
+func (c *Configurator) RenameOption(old_name string, new_name string, version string) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	if c.optionRenames == nil {
+		c.optionRenames = make(map[string]rename)
+	}
+	c.optionRenames[c.optionKey(old_name)] = rename{newName: new_name, version: version}
+}
+
+/*****************************************************************************\
+  Declare that the config file section old_name was renamed new_name in the
+  specified package version, i.e. when a command is renamed.
+\*****************************************************************************/
+
+func (c *Configurator) RenameSection(old_name string, new_name string, version string) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	if c.sectionRenames == nil {
+		c.sectionRenames = make(map[string]rename)
+	}
+	c.sectionRenames[old_name] = rename{newName: new_name, version: version}
+}
+
+/*****************************************************************************\
+  Return the current name for an option or section name read from a config
+  file, warning (once per file and name) if it was renamed.
+\*****************************************************************************/
+
+func (c *Configurator) migrateName(renames map[string]rename, kind string, name string, config_file string) string {
+	key := name
+	if kind == "Option" {
+		key = c.optionKey(name)
+	}
+	renamed, ok := renames[key]
+	if !ok {
+		return name
+	}
+	if key := config_file + "\x00" + kind + "\x00" + name; !c.renameWarned[key] {
+		c.setRenameWarned(key)
+		Warn("%s \"%s\" in config file %s was renamed \"%s\" in version %s; run with --MigrateConfig to update the file",
+			kind, name, config_file, renamed.newName, renamed.version)
+	}
+	return renamed.newName
+}
+
+func (c *Configurator) setRenameWarned(key string) {
+	if c.renameWarned == nil {
+		c.renameWarned = make(map[string]bool)
+	}
+	c.renameWarned[key] = true
+}
+
+/*****************************************************************************\
+  Rewrite a config file to use the new names of any renamed options and
+  sections, then apply the registered migrations, preserving everything else.
+  If changed, the original file is kept as a backup, with a timestamped
+  ".bak" suffix.  Return the number of changes made.
+\*****************************************************************************/
+
+func (c *Configurator) MigrateConfigFile(config_file string) (int, error) {
+
+	var changed int
+
+	info, err := os.Stat(config_file)
+	if err != nil {
+		return 0, Error("Error stat'ing config file %s: %v", config_file, err)
+	}
+	data, err := os.ReadFile(config_file)
+	if err != nil {
+		return 0, Error("Error reading config file %s: %v", config_file, err)
+	}
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		if _, _, _, migrated, ok := c.renamedLine(line); ok {
+			lines[i] = migrated
+			changed++
+		}
+	}
+	version, header, err := configFileVersion(lines)
+	if err != nil {
+		return 0, Error("%s:%d: %v", config_file, header+1, err)
+	}
+	lines, migrated := c.applyMigrations(lines, version)
+	changed += migrated
+	if schema_version := c.ConfigSchemaVersion(); version < schema_version {
+		if header < 0 {
+			lines = append([]string{""}, lines...)
+			header = 0
+		}
+		lines[header] = ConfVersionHeader + " = " + strconv.Itoa(schema_version)
+		changed++
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	backup := config_file + "." + time.Now().Format("20060102-150405") + ".bak"
+	if err = writeFileAtomic(backup, data, info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	ShowDebug("Backed up %s to %s", config_file, backup)
+	return changed, writeFileAtomic(config_file, []byte(strings.Join(lines, "\n")), info.Mode().Perm())
+}
+
+/*****************************************************************************\
+  Check a config file line for a renamed option or section name, returning
+  its kind ("option" or "section"), the name, the rename, and the line
+  rewritten to use the new name.
+\*****************************************************************************/
+
+func (c *Configurator) renamedLine(line string) (kind string, name string, renamed rename, migrated string, ok bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+	if strings.HasPrefix(trimmed, "[") {
+		name = strings.TrimSuffix(strings.TrimPrefix(strings.TrimRight(trimmed, " \t"), "["), "]")
+		if renamed, ok = c.sectionRenames[name]; ok {
+			return "section", name, renamed, indent + "[" + renamed.newName + "]", true
+		}
+		return "", "", renamed, line, false
+	} else if strings.HasPrefix(trimmed, "#") || !strings.Contains(trimmed, "=") {
+		return "", "", renamed, line, false
+	}
+	slice := strings.SplitN(trimmed, "=", 2)
+	name = strings.TrimRight(slice[0], " \t")
+	if renamed, ok = c.optionRenames[c.optionKey(name)]; ok {
+		return "option", name, renamed, indent + renamed.newName + strings.TrimPrefix(trimmed, name), true
+	}
+	return "", "", renamed, line, false
+}
+
+/*****************************************************************************\
+  Register a migration, introduced in the specified config schema version.
+\*****************************************************************************/
+
+func (c *Configurator) RegisterMigration(version int, desc string, transform ConfigTransform) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	c.migrations = append(c.migrations, configMigration{version: version, desc: desc, transform: transform})
+	sort.SliceStable(c.migrations, func(i, j int) bool {
+		return c.migrations[i].version < c.migrations[j].version
+	})
+}
+
+/*****************************************************************************\
+  Set the config schema version the program understands, if other than the
+  latest version with a registered migration.
+\*****************************************************************************/
+
+func (c *Configurator) SetConfigSchemaVersion(version int) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	c.schemaVersion = version
+}
+
+/*****************************************************************************\
+  Return the config schema version the program understands: as set by
+  SetConfigSchemaVersion, or the latest version with a registered migration.
+\*****************************************************************************/
+
+func (c *Configurator) ConfigSchemaVersion() int {
+	version := c.schemaVersion
+	for _, migration := range c.migrations {
+		if migration.version > version {
+			version = migration.version
+		}
+	}
+	return version
+}
+
+/*****************************************************************************\
+  Return the schema version of a config file, per its config-version header
+  (preceding any section), and the index of the header line: 0 and -1 if it
+  has none.
+\*****************************************************************************/
+
+func configFileVersion(lines []string) (int, int, error) {
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " \t")
+		if strings.HasPrefix(trimmed, "[") {
+			break
+		} else if !isVersionHeader(trimmed) {
+			continue
+		}
+		_, _, _, value, _ := splitAssignment(trimmed)
+		value = configCommentRegexp.ReplaceAllString(value, "")
+		version, err := strconv.Atoi(value)
+		if err != nil || version < 0 {
+			return 0, i, Error("Bad %s \"%s\"", ConfVersionHeader, value)
+		}
+		return version, i, nil
+	}
+	return 0, -1, nil
+}
+
+func isVersionHeader(line string) bool {
+	_, name, op, _, ok := splitAssignment(line)
+	return ok && op == "=" && strings.EqualFold(name, ConfVersionHeader)
+}
+
+/*****************************************************************************\
+  Migrate the lines of a config file of the specified schema version, in
+  memory, as it is read.  Warn (once per file) if the file is of a newer
+  version than the program understands.
+\*****************************************************************************/
+
+func (c *Configurator) migrateConfigLines(config_file string, lines []string, version int) []string {
+	if schema_version := c.ConfigSchemaVersion(); version > schema_version {
+		if key := config_file + "\x00" + ConfVersionHeader; !c.renameWarned[key] {
+			c.setRenameWarned(key)
+			Warn("Config file %s is of %s %d; this version of %s understands up to %d",
+				config_file, ConfVersionHeader, version, ProgramName, schema_version)
+		}
+		return lines
+	}
+	lines, changed := c.applyMigrations(lines, version)
+	if changed > 0 {
+		ShowDebug("Migrated %s in memory from %s %d: %d change(s)", config_file, ConfVersionHeader, version, changed)
+	}
+	return lines
+}
+
+/*****************************************************************************\
+  Map the lines of a config file migrated in memory back to the lines of the
+  file: return the line number in the file of each migrated line.  Lines
+  left as is are matched in order (as the longest common subsequence); a
+  changed or inserted line is numbered as the first line it replaced, or, if
+  none, the line before it.
+\*****************************************************************************/
+
+func sourceLineNumbers(original []string, migrated []string) []int {
+	numbers := make([]int, len(migrated))
+	// Only the lines between any common prefix and suffix need matching.
+	var prefix, suffix int
+	for prefix < len(original) && prefix < len(migrated) && original[prefix] == migrated[prefix] {
+		numbers[prefix] = prefix + 1
+		prefix++
+	}
+	for suffix < len(original)-prefix && suffix < len(migrated)-prefix &&
+		original[len(original)-1-suffix] == migrated[len(migrated)-1-suffix] {
+		numbers[len(migrated)-1-suffix] = len(original) - suffix
+		suffix++
+	}
+	from, to := original[prefix:len(original)-suffix], migrated[prefix:len(migrated)-suffix]
+
+	// common[i][j]: the length of the longest common subsequence of from[i:]
+	// and to[j:].
+	common := make([][]int, len(from)+1)
+	for i := range common {
+		common[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+	// The number of the last line kept, and of the first line replaced since.
+	last, replaced := prefix, 0
+	for i, j := 0, 0; j < len(to); {
+		if i < len(from) && from[i] == to[j] {
+			numbers[prefix+j] = prefix + i + 1
+			last, replaced = prefix+i+1, 0
+			i++
+			j++
+		} else if i < len(from) && common[i+1][j] >= common[i][j+1] {
+			if replaced == 0 {
+				replaced = prefix + i + 1
+			}
+			i++
+		} else {
+			switch {
+			case replaced != 0:
+				numbers[prefix+j] = replaced
+			case last != 0:
+				numbers[prefix+j] = last
+			default:
+				numbers[prefix+j] = 1
+			}
+			j++
+		}
+	}
+	return numbers
+}
+
+/*****************************************************************************\
+  Apply the migrations for schema versions after the specified version, in
+  order, returning the lines and the number of changes made.
+\*****************************************************************************/
+
+func (c *Configurator) applyMigrations(lines []string, version int) ([]string, int) {
+	var changed int
+	for _, migration := range c.migrations {
+		if migration.version <= version {
+			continue
+		}
+		var count int
+		if lines, count = migration.transform(lines); count > 0 {
+			ShowDebug("Migration %d (%s): %d change(s)", migration.version, migration.desc, count)
+			changed += count
+		}
+	}
+	return lines, changed
+}
+
+/*****************************************************************************\
+  Split a config file assignment into its indent, name, operator ("=" or
+  "+="), and value, with any trailing comment.  Return ok false for other
+  lines.
+\*****************************************************************************/
+
+func splitAssignment(line string) (indent string, name string, op string, value string, ok bool) {
+	trimmed := strings.TrimLeft(line, " \t")
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "[") {
+		return "", "", "", "", false
+	}
+	slice := strings.SplitN(trimmed, "=", 2)
+	if len(slice) != 2 {
+		return "", "", "", "", false
+	}
+	indent, name, op = line[:len(line)-len(trimmed)], strings.TrimSpace(slice[0]), "="
+	if strings.HasSuffix(name, "+") {
+		name, op = strings.TrimSpace(strings.TrimSuffix(name, "+")), "+="
+	}
+	return indent, name, op, strings.TrimSpace(slice[1]), true
+}
+
+/*****************************************************************************\
+  Standard transforms.  RenameOptionTransform renames an option, in all
+  sections.
+\*****************************************************************************/
+
+func RenameOptionTransform(old_name string, new_name string) ConfigTransform {
+	return func(lines []string) ([]string, int) {
+		var changed int
+		for i, line := range lines {
+			indent, name, _, _, ok := splitAssignment(line)
+			if ok && strings.EqualFold(name, old_name) {
+				trimmed := strings.TrimLeft(line, " \t")
+				lines[i] = indent + new_name + strings.TrimPrefix(trimmed, name)
+				changed++
+			}
+		}
+		return lines, changed
+	}
+}
+
+/*****************************************************************************\
+  SplitValueTransform replaces an option whose value combines several (i.e.
+  "Server = host:port") with an option per part ("Host = host", "Port =
+  port"), split on sep.  Parts beyond the number of new names are left
+  joined to the last; any trailing comment stays with the first.
+\*****************************************************************************/
+
+func SplitValueTransform(old_name string, sep string, new_names ...string) ConfigTransform {
+	if len(new_names) == 0 {
+		panic(Error("programming error: SplitValueTransform of \"%s\" without new names", old_name))
+	}
+	return func(lines []string) ([]string, int) {
+		var output []string
+		var changed int
+		for _, line := range lines {
+			indent, name, op, value, ok := splitAssignment(line)
+			if !ok || !strings.EqualFold(name, old_name) {
+				output = append(output, line)
+				continue
+			}
+			value, comment := splitConfigComment(value)
+			for i, part := range strings.SplitN(value, sep, len(new_names)) {
+				output = append(output, indent+new_names[i]+" "+op+" "+strings.TrimSpace(part)+comment)
+				comment = ""
+			}
+			changed++
+		}
+		return output, changed
+	}
+}
+
+/*****************************************************************************\
+  MoveSectionTransform moves the settings of a section to another, i.e. when
+  a command is moved ("host:create" to "host:add").
+\*****************************************************************************/
+
+func MoveSectionTransform(old_section string, new_section string) ConfigTransform {
+	return func(lines []string) ([]string, int) {
+		var changed int
+		for i, line := range lines {
+			trimmed := strings.TrimLeft(line, " \t")
+			if !strings.HasPrefix(trimmed, "[") {
+				continue
+			}
+			comment := configCommentRegexp.FindString(trimmed)
+			section := strings.TrimSuffix(strings.TrimPrefix(strings.TrimRight(strings.TrimSuffix(trimmed, comment), " \t"), "["), "]")
+			if section == old_section {
+				lines[i] = line[:len(line)-len(trimmed)] + "[" + new_section + "]" + comment
+				changed++
+			}
+		}
+		return lines, changed
+	}
+}
+
+/*****************************************************************************\
+  Migrate each of the config files for the program.  Files which cannot be
+  migrated (i.e. not writable) are reported, and the last error returned.
+\*****************************************************************************/
+
+func MigrateConfig() (err error) {
+	config_files, err := std.configFiles()
+	if err != nil {
+		Warn("%v", err)
+		return err
+	}
+	for _, config_file := range config_files {
+		changed, file_err := std.MigrateConfigFile(config_file)
+		if file_err != nil {
+			Warn("%v", file_err)
+			err = file_err
+		} else if changed > 0 {
+			Show("Migrated %d change(s) in %s", changed, config_file)
+		} else {
+			Infof("No changes needed in %s", config_file)
+		}
+	}
+	return err
+}
+
+/*****************************************************************************\
+  A deprecated (renamed) name used in a config file, as reported by
+  --Deprecations.
+\*****************************************************************************/
+
+type Deprecation struct {
+	File    string
+	Line    int
+	Kind    string
+	Name    string
+	NewName string
+	Version string
+}
+
+/*****************************************************************************\
+  Return the deprecated option and section names used in each of the config
+  files for the program, in all their sections, so that config files needing
+  migration may be found before the old names are removed.
+\*****************************************************************************/
+
+func (c *Configurator) Deprecations() ([]Deprecation, error) {
+
+	var deprecations []Deprecation
+
+	c.setConfigDirs()
+	config_files, err := c.configFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, config_file := range config_files {
+		data, err := os.ReadFile(config_file)
+		if err != nil {
+			return deprecations, Error("Error reading config file %s: %v", config_file, err)
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			if kind, name, renamed, _, ok := c.renamedLine(line); ok {
+				deprecations = append(deprecations, Deprecation{File: config_file, Line: i + 1, Kind: kind,
+					Name: name, NewName: renamed.newName, Version: renamed.version})
+			}
+		}
+	}
+	return deprecations, nil
+}
+
+/*****************************************************************************\
+  Show the deprecated names used in the config files, as a JSON list (empty
+  if none), and exit.
+\*****************************************************************************/
+
+func deprecationsMode() {
+	deprecations, err := std.Deprecations()
+	if err != nil {
+		Exit(1, err)
+	}
+	if deprecations == nil {
+		deprecations = []Deprecation{}
+	}
+	showJSON(deprecations)
+	Exit(0)
+}
@@ -3,6 +3,10 @@ package sitepkg
 /*****************************************************************************\
   Functions for outputing warning or informational messages.  We want all
   output to go through us, so we can divert to mail, syslog, etc as desired.
+
+  The functions are safe for concurrent use: each writes its output in a
+  single write, under a lock, so lines written by different goroutines are
+  not interleaved.
 \*****************************************************************************/
 
 import (
@@ -10,6 +14,8 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
 )
 
 var DefaultPrint io.Writer = os.Stdout
@@ -17,49 +23,180 @@ var DefaultShow io.Writer = os.Stdout
 var DefaultErr io.Writer = os.Stderr
 var DefaultDebug io.Writer = os.Stderr
 
+// If set, the output of Print, Show and the leveled logging functions is
+// diverted to logSink (see SetSlogLogger).
+var logSink func(level LogLevel, message string, fields Fields)
+
+var outputLock sync.Mutex
+
+// Write the text to w in a single write, under the output lock.
+func writeOutput(w io.Writer, text string) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	io.WriteString(w, text)
+}
+
 func Print(format string, a ...interface{}) {
-	fmt.Fprintf(DefaultPrint, format, a...)
+	if logSink != nil {
+		logSink(LevelInfo, strings.TrimSuffix(fmt.Sprintf(format, a...), "\n"), nil)
+		return
+	}
+	writeOutput(classWriter(ClassPrint), fmt.Sprintf(format, a...))
 }
 
 func Println(format string, a ...interface{}) {
-	fmt.Fprintf(DefaultPrint, format+"\n", a...)
+	if logSink != nil {
+		logSink(LevelInfo, fmt.Sprintf(format, a...), nil)
+		return
+	}
+	writeOutput(classWriter(ClassPrint), fmt.Sprintf(format+"\n", a...))
 }
 
 func Show(format string, a ...interface{}) {
-	myformat := ProgramName + ": " + format
-	fmt.Fprintf(DefaultShow, myformat+"\n", a...)
+	if logSink != nil || logJSON {
+		Showf(nil, format, a...)
+		return
+	}
+	myformat := timestampPrefix() + prefixFormat() + format
+	writeOutput(classWriter(ClassShow), fmt.Sprintf(myformat+"\n", a...))
 }
 
 func Warn(format string, a ...interface{}) {
-	myformat := ProgramName + ": Warning: " + format
-	fmt.Fprintf(DefaultErr, myformat+"\n", a...)
+	logf(LevelWarn, format, a...)
 }
 
 func Fprint(w io.Writer, format string, a ...interface{}) {
-	fmt.Fprintf(w, format, a...)
+	writeOutput(w, fmt.Sprintf(format, a...))
 }
 
 func Fprintln(w io.Writer, format string, a ...interface{}) {
-	fmt.Fprintf(w, format+"\n", a...)
+	writeOutput(w, fmt.Sprintf(format+"\n", a...))
 }
 
 func Fshow(w io.Writer, format string, a ...interface{}) {
-	myformat := ProgramName + ": " + format
+	myformat := timestampPrefix() + prefixFormat() + format
 	Fprintln(w, myformat, a...)
 }
 
 func Fwarn(w io.Writer, format string, a ...interface{}) {
-	myformat := ProgramName + ": Warning: " + format
-	fmt.Fprintf(w, myformat+"\n", a...)
+	myformat := timestampPrefix() + prefixFormat() + "Warning: " + format
+	writeOutput(w, Colorize(w, ColorWarn, fmt.Sprintf(myformat, a...))+"\n")
+}
+
+func ShowInfo(format string, a ...interface{}) {
+	logf(LevelInfo, format, a...)
 }
 
 func ShowDebug(format string, a ...interface{}) {
-	if Debug {
-		fmt.Fprintf(DefaultDebug, "DEBUG: "+format+"\n", a...)
-	}
+	logf(LevelDebug, format, a...)
+}
+
+func ShowTrace(format string, a ...interface{}) {
+	logf(LevelTrace, format, a...)
 }
 
 func Log(format string, a ...interface{}) {
+	if logJSON {
+		writeJSONLog(log.Writer(), LevelInfo, fmt.Sprintf(format, a...), nil)
+		return
+	} else if timestampFormat != "" {
+		// In place of the log package's own date and time.
+		message := strings.TrimSuffix(fmt.Sprintf(format, a...), "\n")
+		writeOutput(log.Writer(), Timestamp()+" "+log.Prefix()+message+"\n")
+		return
+	}
 	log.Printf(format, a...)
 }
 
+/*****************************************************************************\
+  Tee all output (DefaultPrint, DefaultShow, DefaultErr and DefaultDebug) to
+  the specified writer as well, i.e. a log file, or the mail capture (see
+  MailList), or stop doing so.
+\*****************************************************************************/
+
+var outputTees []io.Writer
+var untee struct {
+	print, show, err, debug io.Writer
+}
+
+func addOutputTee(w io.Writer) {
+	if len(outputTees) == 0 {
+		untee.print, untee.show = DefaultPrint, DefaultShow
+		untee.err, untee.debug = DefaultErr, DefaultDebug
+	}
+	outputTees = append(outputTees, w)
+	setOutputTees()
+}
+
+func removeOutputTee(w io.Writer) {
+	for i, tee := range outputTees {
+		if tee == w {
+			outputTees = append(outputTees[:i], outputTees[i+1:]...)
+			setOutputTees()
+			return
+		}
+	}
+}
+
+func setOutputTees() {
+	tee := func(w io.Writer) io.Writer {
+		if len(outputTees) == 0 {
+			return w
+		}
+		return io.MultiWriter(append([]io.Writer{w}, outputTees...)...)
+	}
+	DefaultPrint, DefaultShow = tee(untee.print), tee(untee.show)
+	DefaultErr, DefaultDebug = tee(untee.err), tee(untee.debug)
+}
+
+/*****************************************************************************\
+  Reset the output to its state before PackageInit: no tees (the log file
+  closed, and the output no longer captured for emailing), routes, log
+  destinations, journal, suppressed warnings or registered output formats,
+  and the default log format, timestamps, color, prefix, debug categories
+  and exit codes.  The Default writers are restored to those teed, if teed,
+  and otherwise left as set by the program.  For ResetConfig.
+\*****************************************************************************/
+
+func resetOutput() {
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+	mailOutput = nil
+	if len(outputTees) > 0 {
+		outputTees = nil
+		setOutputTees()
+	}
+
+	routesLock.Lock()
+	routes = make(map[MessageClass][]string)
+	routeDestinations = make(map[string]io.Writer)
+	routesLock.Unlock()
+	logDestinationsLock.Lock()
+	logDestinations = nil
+	logDestinationsLock.Unlock()
+	journalLock.Lock()
+	if journalConn != nil {
+		journalConn.Close()
+		journalConn = nil
+	}
+	journalLock.Unlock()
+	warningCountsLock.Lock()
+	warningCounts = make(map[string]*warningCount)
+	warningOrder = nil
+	warningSummaryOnce = sync.Once{}
+	warningCountsLock.Unlock()
+	exitCodesLock.Lock()
+	exitCodes = defaultExitCodes()
+	exitCodesLock.Unlock()
+
+	outputFormats = []string{FormatText, FormatJSON, FormatCSV, FormatTable}
+	outputWriters = make(map[string]OutputWriter)
+	logJSON = false
+	logSink = nil
+	timestampFormat = ""
+	colorMode = ColorAuto
+	prefixTemplate = ""
+	debugCategories = nil
+}
@@ -0,0 +1,78 @@
+package sitepkg
+
+/*****************************************************************************\
+  The generic override flag: "-o name=value" (or "--Option name=value"),
+  repeatable, sets any option which may be set in config files, so that
+  rarely used options needn't each be given a flag to be overridden in a
+  one-off run:
+
+    ibapi -o CacheTTL=0 -o Servers+=ib3.example.com host get www
+
+  As in config files, "name+=value" appends to a list option.  Overrides are
+  applied after the other command line options, so they take precedence.
+\*****************************************************************************/
+
+import (
+	"strings"
+)
+
+/*****************************************************************************\
+  Return the overrides given with --Option.  Its values are comma separated
+  as for any list option, so a value without "=" continues the previous one,
+  i.e. "-o Servers=a,b".  Called with the lock held.
+\*****************************************************************************/
+
+func (c *Configurator) optionOverrides() []string {
+	var overrides []string
+	lc := c.optionKey("Option")
+	option, ok := c.Config[lc]
+	if !ok || option.Type != "list" || !c.FlagSet.Changed(lc) {
+		return nil
+	}
+	for _, item := range *option.ListValue {
+		if n := len(overrides); n > 0 && !strings.Contains(item, "=") {
+			overrides[n-1] += "," + item
+		} else {
+			overrides = append(overrides, item)
+		}
+	}
+	return overrides
+}
+
+/*****************************************************************************\
+  Apply the overrides given with --Option.  Called with the lock held, after
+  the command line is parsed.
+\*****************************************************************************/
+
+func (c *Configurator) applyOptionOverrides() error {
+	for _, override := range c.optionOverrides() {
+		parts := strings.SplitN(override, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return Error("Bad option override \"%s\": expected name=value", override)
+		}
+		name := strings.TrimSpace(parts[0])
+		append_value := strings.HasSuffix(name, "+")
+		name = strings.TrimSpace(strings.TrimSuffix(name, "+"))
+		option, ok := c.Config[c.optionKey(name)]
+		if !ok {
+			return Error("Unknown option \"%s\" in override \"%s\"", name, override)
+		} else if option.Sources&SourceFile == 0 {
+			return Error("Illegal override of option \"%s\": only options which may be set in config files may be overridden with --Option", name)
+		} else if option.Final {
+			return Error("Option \"%s\" may not be overridden; it is final, set by %s", name, option.Source)
+		} else if append_value && option.Type != "list" {
+			return Error("Illegal \"+=\" for %s option \"%s\" in override \"%s\"", option.Type, name, override)
+		}
+		previous := optionValue(option)
+		if err := setOptionValue(option, name, parts[1]); err != nil {
+			return Error("%s in override \"%s\"", err, override)
+		}
+		if append_value {
+			*option.ListValue = append(previous.([]string), *option.ListValue...)
+		}
+		option.Source = "CommandLine"
+		recordAssignment(option, 0)
+		c.trace("command line: -o %s => %s = %s", override, option.Name, formatValue(optionValue(option)))
+	}
+	return nil
+}
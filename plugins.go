@@ -0,0 +1,183 @@
+package sitepkg
+
+/*****************************************************************************\
+  External plugin commands.  As with git, an executable named
+  <program>-<command> (i.e. "ibapi-audit", or "ibapi-host-audit" for "ibapi
+  host audit") in PackageDir/libexec, or else in PATH, is a command of the
+  program, so that sites can extend tools without rebuilding them.  Plugins
+  are found for programs with registered commands (see AddCommand), in place
+  of unknown commands, and listed in the usage.
+
+  Options are parsed up to the plugin command; the rest of the command line
+  is passed to the plugin as is.  The global options set (other than by
+  default) are passed in their environment variables (see EnvVarName),
+  whatever their sources, listed in <PKG>_PLUGIN_OPTIONS: plugins using
+  sitepkg, with the same package name, accept those options from the
+  environment, and so see the same configuration.
+\*****************************************************************************/
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+/*****************************************************************************\
+  Return the plugins for the commands directly under the specified command
+  path ("" for the top level commands): command word to executable.  Those
+  in PackageDir/libexec take precedence over those in PATH.
+\*****************************************************************************/
+
+func (c *Configurator) Plugins(path string) map[string]string {
+	plugins := make(map[string]string)
+	path = c.commandPath(path)
+	prefix := c.pluginPrefix(path)
+	dirs := append([]string{filepath.Join(c.PackageDir, "libexec")}, filepath.SplitList(os.Getenv("PATH"))...)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if runtime.GOOS == "windows" {
+				name = strings.TrimSuffix(name, ".exe")
+			}
+			word := strings.TrimPrefix(name, prefix)
+			if word == name || word == "" || c.subcommandPlugin(path, word) {
+				continue
+			} else if _, ok := plugins[word]; ok {
+				continue
+			} else if executable(filepath.Join(dir, entry.Name())) {
+				plugins[word] = filepath.Join(dir, entry.Name())
+			}
+		}
+	}
+	return plugins
+}
+
+// Check if a plugin word is that of a plugin under a subcommand: i.e.
+// "host-audit", with "host" a command.
+func (c *Configurator) subcommandPlugin(path string, word string) bool {
+	first := strings.SplitN(word, "-", 2)[0]
+	if path != "" {
+		first = path + ":" + first
+	}
+	_, ok := c.commands[first]
+	return ok && strings.Contains(word, "-")
+}
+
+// Return the file name prefix of the plugins under a command path.
+func (c *Configurator) pluginPrefix(path string) string {
+	if path == "" {
+		return c.ProgramName + "-"
+	}
+	return c.ProgramName + "-" + strings.ReplaceAll(path, ":", "-") + "-"
+}
+
+/*****************************************************************************\
+  Return the executable for the plugin command word under a command path,
+  or "" if none.
+\*****************************************************************************/
+
+func (c *Configurator) findPlugin(path string, word string) string {
+	if word == "" || strings.ContainsAny(word, "/\\") {
+		return ""
+	}
+	name := c.pluginPrefix(path) + word
+	candidate := filepath.Join(c.PackageDir, "libexec", name)
+	if executable(candidate) {
+		return candidate
+	}
+	if candidate, err := exec.LookPath(name); err == nil {
+		return candidate
+	}
+	return ""
+}
+
+/*****************************************************************************\
+  Return the executable of the plugin invoked by an unknown word following a
+  command path, or "" if none: only commands without a Run function (and
+  the top level) have plugins, as the others take arguments.
+\*****************************************************************************/
+
+func (c *Configurator) pluginFor(path string, word string) string {
+	if command, ok := c.commands[path]; (ok && command.Run != nil) || strings.HasPrefix(word, "-") {
+		return ""
+	}
+	return c.findPlugin(path, word)
+}
+
+/*****************************************************************************\
+  Return the environment variable listing the options passed to a plugin,
+  and, in a plugin, the options so passed.
+\*****************************************************************************/
+
+func (c *Configurator) pluginOptionsVar() string {
+	return strings.ToUpper(c.PkgName) + "_PLUGIN_OPTIONS"
+}
+
+func (c *Configurator) pluginOptions() map[string]bool {
+	passed := make(map[string]bool)
+	for _, name := range strings.Split(os.Getenv(c.pluginOptionsVar()), ",") {
+		if name != "" {
+			passed[c.optionKey(name)] = true
+		}
+	}
+	return passed
+}
+
+// Return the value of an option passed to the plugin, or "" if none: for
+// the options prescanned, as they affect how the config files are read.
+func (c *Configurator) passedOption(name string) string {
+	if !c.pluginOptions()[c.optionKey(name)] {
+		return ""
+	}
+	return os.Getenv(c.EnvVarName(c.optionKey(name)))
+}
+
+func executable(filename string) bool {
+	info, err := os.Stat(filename)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
+
+/*****************************************************************************\
+  Run the plugin invoked, with the specified (remaining) arguments, exiting
+  with its exit status if it fails.
+\*****************************************************************************/
+
+func (c *Configurator) runPlugin(args []string) error {
+	var passed []string
+	c.lock.RLock()
+	environment := os.Environ()
+	for name, option := range c.Config {
+		if !c.commandOptions[name] && !isDefaultSource(option.Source) {
+			environment = append(environment, c.EnvVarName(name)+"="+formatValue(optionValue(option)))
+			passed = append(passed, name)
+		}
+	}
+	environment = append(environment, c.pluginOptionsVar()+"="+strings.Join(passed, ","))
+	plugin := c.plugin
+	c.lock.RUnlock()
+
+	ShowDebug("Running plugin %s %v", plugin, args)
+	command := exec.CommandContext(CommandContext(), plugin, args...)
+	command.Stdin, command.Stdout, command.Stderr = os.Stdin, os.Stdout, os.Stderr
+	command.Env = environment
+	err := command.Run()
+	var exit_error *exec.ExitError
+	if context_err := CommandContextError(); context_err != nil {
+		return Error("Failure running plugin %s: %v", plugin, context_err)
+	} else if errors.As(err, &exit_error) {
+		Exit(exit_error.ExitCode())
+	} else if err != nil {
+		return Error("Failure running plugin %s: %v", plugin, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package sitepkg
+
+/*****************************************************************************\
+  Message prefixes.  Messages (those of Show, Warn, and the leveled logging
+  functions) are prefixed with the program name ("ibapi: ..."), or, per the
+  MessagePrefix option (or SetPrefix), a template of:
+
+    {program}   the program name
+    {host}      the host name, without its domain
+    {fqdn}      the full host name
+    {pid}       the process ID
+    {command}   the invoked command line, i.e. "ibapi host add"
+
+  i.e. "{host} {program}[{pid}]" for "ns1 ibapi[4242]: ...", so that the
+  output of runs across hosts identifies its source; or "none" for no
+  prefix.
+\*****************************************************************************/
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const PrefixNone = "none"
+
+var prefixTemplate string
+var prefixPlaceholder = regexp.MustCompile(`\{[^{}]*\}`)
+var prefixHostname string
+var prefixHostnameOnce sync.Once
+
+/*****************************************************************************\
+  Set the message prefix template, "none" for no prefix, or "" for the
+  default, {program}.  ConfigureOptions sets it from the MessagePrefix
+  option.
+\*****************************************************************************/
+
+func SetPrefix(template string) error {
+	for _, placeholder := range prefixPlaceholder.FindAllString(template, -1) {
+		switch placeholder {
+		case "{program}", "{host}", "{fqdn}", "{pid}", "{command}":
+		default:
+			return Error("Bad message prefix \"%s\": unknown placeholder %s", template, placeholder)
+		}
+	}
+	prefixTemplate = template
+	return nil
+}
+
+func setPrefixFromOptions() error {
+	template, _ := pkgOpt[string]("MessagePrefix")
+	if err := SetPrefix(template); err != nil {
+		return WrapError(UsageError, err)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Return the prefix of messages: i.e. "ibapi: ", or "" for none.
+\*****************************************************************************/
+
+func MessagePrefix() string {
+	switch prefixTemplate {
+	case "":
+		return ProgramName + ": "
+	case PrefixNone:
+		return ""
+	}
+	prefix := prefixPlaceholder.ReplaceAllStringFunc(prefixTemplate, func(placeholder string) string {
+		switch placeholder {
+		case "{program}":
+			return ProgramName
+		case "{host}":
+			return strings.SplitN(hostname(), ".", 2)[0]
+		case "{fqdn}":
+			return hostname()
+		case "{pid}":
+			return strconv.Itoa(os.Getpid())
+		case "{command}":
+			return std.commandLine()
+		}
+		return placeholder
+	})
+	return prefix + ": "
+}
+
+// Return the message prefix as a format string, with any % escaped.
+func prefixFormat() string {
+	return strings.ReplaceAll(MessagePrefix(), "%", "%%")
+}
+
+func hostname() string {
+	prefixHostnameOnce.Do(func() {
+		prefixHostname, _ = os.Hostname()
+	})
+	return prefixHostname
+}
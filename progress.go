@@ -0,0 +1,201 @@
+package sitepkg
+
+/*****************************************************************************\
+  Progress bars and spinners, i.e. for bulk imports of thousands of records:
+
+    progress := sitepkg.NewProgress(len(records))
+    progress.SetLabel("Importing")
+    for _, record := range records {
+        ...
+        progress.Tick()
+    }
+    progress.Done()
+
+  On a terminal, a progress bar (or, for NewSpinner, whose total is not
+  known, a spinner) is redrawn in place on stderr:
+
+    Importing [==========>          ]  52%  5200/10000  ETA 41s
+
+  When stderr is redirected, a line is written every ProgressLogInterval
+  instead, and a final line by Done.  Nothing is written in Quiet mode.
+  Tick and Set are safe for concurrent use.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const progressRenderInterval = 100 * time.Millisecond
+const progressBarWidth = 30
+
+var ProgressLogInterval = 10 * time.Second
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+type Progress struct {
+	label    string
+	total    int64
+	current  int64
+	start    time.Time
+	rendered time.Time
+	frame    int
+	terminal bool
+	done     bool
+	lock     sync.Mutex
+}
+
+/*****************************************************************************\
+  Return a progress bar of the specified total, or, if total is 0 or less,
+  a spinner.
+\*****************************************************************************/
+
+func NewProgress(total int) *Progress {
+	now := time.Now()
+	return &Progress{
+		total:    int64(total),
+		start:    now,
+		rendered: now,
+		terminal: isTerminal(DefaultErr),
+	}
+}
+
+func NewSpinner() *Progress {
+	return NewProgress(0)
+}
+
+func (p *Progress) SetLabel(label string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.label = label
+}
+
+/*****************************************************************************\
+  Advance the progress by one, or set it.
+\*****************************************************************************/
+
+func (p *Progress) Tick() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.current++
+	p.update(false)
+}
+
+func (p *Progress) Set(current int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.current = int64(current)
+	p.update(false)
+}
+
+/*****************************************************************************\
+  Finish the progress: draw it complete (ending the line), or write the
+  final line.
+\*****************************************************************************/
+
+func (p *Progress) Done() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.done {
+		return
+	}
+	p.update(true)
+	p.done = true
+}
+
+// Render the progress if due, or if final.  Called with p.lock held.
+func (p *Progress) update(final bool) {
+	if p.done || Quiet {
+		return
+	}
+	now := time.Now()
+	interval := ProgressLogInterval
+	if p.terminal {
+		interval = progressRenderInterval
+	}
+	if !final && now.Sub(p.rendered) < interval {
+		return
+	}
+	p.rendered = now
+	if p.terminal {
+		line := "\r" + p.bar() + "\x1b[K"
+		if final {
+			line += "\n"
+		}
+		writeOutput(DefaultErr, line)
+	} else {
+		Fshow(DefaultErr, "%s", p.status(final))
+	}
+}
+
+// The progress as drawn on a terminal.
+func (p *Progress) bar() string {
+	var bar strings.Builder
+	if p.label != "" {
+		bar.WriteString(p.label + " ")
+	}
+	if p.total <= 0 {
+		p.frame = (p.frame + 1) % len(spinnerFrames)
+		fmt.Fprintf(&bar, "%s  %d  %s", spinnerFrames[p.frame], p.current, roundDuration(time.Since(p.start)))
+		return bar.String()
+	}
+	filled := int(p.fraction() * progressBarWidth)
+	arrow := ""
+	if filled < progressBarWidth {
+		arrow = ">"
+	}
+	fmt.Fprintf(&bar, "[%s%s%s] %3d%%  %d/%d", strings.Repeat("=", filled), arrow,
+		strings.Repeat(" ", progressBarWidth-filled-len(arrow)), int(p.fraction()*100), p.current, p.total)
+	if eta := p.eta(); eta > 0 {
+		bar.WriteString("  ETA " + roundDuration(eta).String())
+	}
+	return bar.String()
+}
+
+// The progress as a log line.
+func (p *Progress) status(final bool) string {
+	label := p.label
+	if label == "" {
+		label = "Progress"
+	}
+	elapsed := roundDuration(time.Since(p.start))
+	switch {
+	case final:
+		return fmt.Sprintf("%s: done, %d in %v", label, p.current, elapsed)
+	case p.total <= 0:
+		return fmt.Sprintf("%s: %d in %v", label, p.current, elapsed)
+	}
+	status := fmt.Sprintf("%s: %d/%d (%d%%)", label, p.current, p.total, int(p.fraction()*100))
+	if eta := p.eta(); eta > 0 {
+		status += ", ETA " + roundDuration(eta).String()
+	}
+	return status
+}
+
+func (p *Progress) fraction() float64 {
+	switch {
+	case p.current <= 0:
+		return 0
+	case p.current >= p.total:
+		return 1
+	}
+	return float64(p.current) / float64(p.total)
+}
+
+// The estimated time remaining, at the rate so far; 0 if not known.
+func (p *Progress) eta() time.Duration {
+	if p.current <= 0 || p.current >= p.total {
+		return 0
+	}
+	elapsed := time.Since(p.start)
+	return time.Duration(float64(elapsed) * float64(p.total-p.current) / float64(p.current))
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	if d < 10*time.Second {
+		return d.Round(100 * time.Millisecond)
+	}
+	return d.Round(time.Second)
+}
@@ -0,0 +1,124 @@
+package sitepkg
+
+/*****************************************************************************\
+  Records: results built field by field, then written in the output format
+  (see OutputFormat) like those of a Formatter:
+
+    records := sitepkg.NewRecordSet()
+    for _, host := range hosts {
+        records.Add(sitepkg.NewRecord().
+            Set("Host", host.Name).
+            Set("Address", host.Address).
+            Set("TTL", host.TTL))
+    }
+    err := records.Write()
+
+  The columns are the fields of the records in the order first set, unless
+  given with SetColumns, so the output is the same from run to run; the
+  fields a record lacks are empty (null in JSON).
+\*****************************************************************************/
+
+import (
+	"io"
+)
+
+type Record struct {
+	names  []string
+	values map[string]interface{}
+}
+
+type RecordSet struct {
+	columns []string
+	fixed   bool
+	records []*Record
+}
+
+func NewRecord() *Record {
+	return &Record{values: make(map[string]interface{})}
+}
+
+/*****************************************************************************\
+  Set a field of the record, returning the record.
+\*****************************************************************************/
+
+func (r *Record) Set(name string, value interface{}) *Record {
+	if _, ok := r.values[name]; !ok {
+		r.names = append(r.names, name)
+	}
+	r.values[name] = value
+	return r
+}
+
+func (r *Record) Get(name string) interface{} {
+	return r.values[name]
+}
+
+// Return the names of the fields set, in the order set.
+func (r *Record) Fields() []string {
+	return append([]string{}, r.names...)
+}
+
+/*****************************************************************************\
+  Create an empty set of records.
+\*****************************************************************************/
+
+func NewRecordSet() *RecordSet {
+	return &RecordSet{}
+}
+
+/*****************************************************************************\
+  Set the columns written, and their order; fields of other names are not
+  written.
+\*****************************************************************************/
+
+func (rs *RecordSet) SetColumns(columns ...string) {
+	rs.columns = columns
+	rs.fixed = true
+}
+
+func (rs *RecordSet) Add(records ...*Record) {
+	for _, record := range records {
+		rs.records = append(rs.records, record)
+		if rs.fixed {
+			continue
+		}
+		for _, name := range record.names {
+			if in_list, _ := InList(rs.columns, name); !in_list {
+				rs.columns = append(rs.columns, name)
+			}
+		}
+	}
+}
+
+func (rs *RecordSet) Len() int {
+	return len(rs.records)
+}
+
+/*****************************************************************************\
+  Write the records in the output format to DefaultPrint, or, with Fwrite,
+  to the specified writer.
+\*****************************************************************************/
+
+func (rs *RecordSet) Write() error {
+	return rs.Fwrite(DefaultPrint)
+}
+
+func (rs *RecordSet) Fwrite(w io.Writer) error {
+	return rs.Formatter().Fwrite(w)
+}
+
+/*****************************************************************************\
+  Return a Formatter of the records.
+\*****************************************************************************/
+
+func (rs *RecordSet) Formatter() *Formatter {
+	formatter := NewFormatter(rs.columns...)
+	for _, record := range rs.records {
+		values := make([]interface{}, len(rs.columns))
+		for i, column := range rs.columns {
+			values[i] = record.values[column]
+		}
+		formatter.Add(values...)
+	}
+	return formatter
+}
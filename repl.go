@@ -0,0 +1,398 @@
+package sitepkg
+
+/*****************************************************************************\
+  Interactive mode (--Interactive): a shell for operators doing many
+  operations in a session.  Command lines are read, with history (kept in
+  the state directory across sessions) and tab completion of commands and
+  options, and each is run through the registered commands (see AddCommand)
+  as if the program had been invoked with it, following any options given
+  with --Interactive:
+
+    $ ibapi --Interactive --Tenant lab
+    ibapi> host add www.example.com 10.1.1.1 --TTL 300
+    ibapi> help host add
+    ibapi> exit
+
+  Each command line is configured afresh, from the config files, environment
+  and its options.  "help [command]" shows the commands, or a command's
+  usage, and "exit" or "quit" (or EOF) ends the session.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+const interactiveHistorySize = 500
+
+type lineEditor struct {
+	prompt   string
+	history  []string
+	complete func(line string) []string
+}
+
+/*****************************************************************************\
+  Run the interactive shell, until exit or EOF.  Return an error only if the
+  shell cannot be run; errors running commands are shown, and the session
+  continues.
+\*****************************************************************************/
+
+func RunInteractive() error {
+
+	var session_args []string
+
+	if len(std.Subcommands("")) == 0 {
+		return Error("No commands defined for interactive mode.")
+	}
+	for _, arg := range std.optionArgs(os.Args[1:]) {
+		if lc := strings.ToLower(arg); lc != "--interactive" && lc != "--interactive=true" {
+			session_args = append(session_args, arg)
+		}
+	}
+	session := std.newSession()
+	editor := &lineEditor{prompt: ProgramName + "> ", history: loadInteractiveHistory()}
+	editor.complete = func(line string) []string {
+		session.reset()
+		words := strings.Fields(line)
+		if line == "" || strings.HasSuffix(line, " ") {
+			words = append(words, "")
+		}
+		return commandLineCompletions(append(append([]string(nil), session_args...), words...))
+	}
+
+	for {
+		line, err := editor.readLine()
+		if err == io.EOF {
+			Println("")
+			break
+		} else if err != nil {
+			return err
+		}
+		words, err := splitCommandLine(line)
+		if err != nil {
+			Warn("%v", err)
+			continue
+		} else if len(words) == 0 {
+			continue
+		}
+		editor.addHistory(line)
+		if words[0] == "exit" || words[0] == "quit" {
+			break
+		} else if words[0] == "help" {
+			words = append(words[1:], "--Help")
+		}
+		if err = session.run(append(append([]string(nil), session_args...), words...)); err != nil {
+			Warn("%v", err)
+		}
+	}
+	saveInteractiveHistory(editor.history)
+	return nil
+}
+
+/*****************************************************************************\
+  The session state restored before each command line: the option defaults,
+  and the options defined before the session.
+\*****************************************************************************/
+
+type interactiveSession struct {
+	c        *Configurator
+	defaults map[string]interface{}
+}
+
+func (c *Configurator) newSession() *interactiveSession {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	session := &interactiveSession{c: c, defaults: make(map[string]interface{})}
+	for lc, option := range c.Config {
+		if !c.commandOptions[lc] {
+			session.defaults[lc] = option.Default
+		}
+	}
+	return session
+}
+
+/*****************************************************************************\
+  Restore the options to their state before any command line was processed.
+\*****************************************************************************/
+
+func (session *interactiveSession) reset() {
+	c := session.c
+	c.writeLock()
+	defer c.lock.Unlock()
+	for lc := range c.Config {
+		if _, ok := session.defaults[lc]; !ok {
+			delete(c.Config, lc)
+		}
+	}
+	for lc, option := range c.Config {
+		option.Default = session.defaults[lc]
+		setTypedValue(option, option.Default)
+		option.Source, option.Final = "Default", false
+		option.History = nil
+		recordAssignment(option, 0)
+	}
+	c.commandOptions = nil
+	c.invoked, c.pathSet, c.plugin = "", false, ""
+	c.ConfigFilesRead = nil
+	c.FlagSet = pflag.NewFlagSet(c.ProgramName, pflag.ContinueOnError)
+	SetLogLevel(LevelWarn)
+}
+
+/*****************************************************************************\
+  Configure the options for a command line, and run its command.
+\*****************************************************************************/
+
+func (session *interactiveSession) run(args []string) error {
+	session.reset()
+	resetCommandContext()
+	args, err := session.c.ConfigureOptions(args)
+	syncGlobals()
+	if err != nil {
+		return err
+	}
+	if err = setLoggingFromOptions(); err != nil {
+		return err
+	}
+	if help, _ := pkgOpt[bool]("Help"); help {
+		Usage()
+		return nil
+	}
+	return session.c.RunCommand(args)
+}
+
+/*****************************************************************************\
+  Split a command line into words, on white space, honoring quotes ('...'
+  and "...") and backslash escapes.
+\*****************************************************************************/
+
+func splitCommandLine(line string) ([]string, error) {
+
+	var words []string
+	var word strings.Builder
+	var quote rune
+	var in_word, escaped bool
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, in_word = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, in_word = r, true
+		case r == ' ' || r == '\t':
+			if in_word {
+				words = append(words, word.String())
+				word.Reset()
+				in_word = false
+			}
+		default:
+			word.WriteRune(r)
+			in_word = true
+		}
+	}
+	if quote != 0 {
+		return nil, Error("Unterminated %c quote", quote)
+	} else if in_word {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+/*****************************************************************************\
+  Read a line, with editing (backspace, ^U, ^W), history (up and down
+  arrows) and completion (tab), if stdin is a terminal.  Return io.EOF on ^D
+  at an empty line; ^C discards the line.
+\*****************************************************************************/
+
+func (editor *lineEditor) readLine() (string, error) {
+
+	if !IsInteractive() {
+		line, err := Prompt("%s", editor.prompt)
+		if err != nil {
+			return "", io.EOF
+		}
+		return line, nil
+	}
+	saved, err := sttySave()
+	if err != nil {
+		return "", err
+	}
+	if err = stty("raw", "-echo"); err != nil {
+		return "", err
+	}
+	defer stty(saved)
+
+	var line []rune
+	position := len(editor.history)
+	redraw := func() {
+		editor.write("\r\x1b[K%s%s", editor.prompt, string(line))
+	}
+	redraw()
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil || n == 0 {
+			return "", io.EOF
+		}
+		input := []rune(string(buf[:n]))
+		for i := 0; i < len(input); i++ {
+			switch r := input[i]; {
+			case r == '\r' || r == '\n':
+				editor.write("\r\n")
+				return string(line), nil
+			case r == 0x04: // ^D
+				if len(line) == 0 {
+					editor.write("\r\n")
+					return "", io.EOF
+				}
+			case r == 0x03: // ^C
+				editor.write("^C\r\n")
+				return "", nil
+			case r == 0x7f || r == 0x08: // Backspace
+				if len(line) > 0 {
+					line = line[:len(line)-1]
+				}
+			case r == 0x15: // ^U
+				line = nil
+			case r == 0x17: // ^W
+				trimmed := strings.TrimRight(string(line), " ")
+				line = []rune(trimmed[:strings.LastIndex(trimmed, " ")+1])
+			case r == '\t':
+				line = editor.completeLine(line)
+			case r == 0x1b && i+2 < len(input) && input[i+1] == '[':
+				switch input[i+2] {
+				case 'A':
+					if position > 0 {
+						position--
+						line = []rune(editor.history[position])
+					}
+				case 'B':
+					if position < len(editor.history) {
+						position++
+						line = nil
+						if position < len(editor.history) {
+							line = []rune(editor.history[position])
+						}
+					}
+				}
+				i += 2
+			case r >= ' ':
+				line = append(line, r)
+			}
+		}
+		redraw()
+	}
+}
+
+/*****************************************************************************\
+  Complete the last word of the line: if there is one completion, use it,
+  else extend the word to the completions' common prefix, and list them.
+\*****************************************************************************/
+
+func (editor *lineEditor) completeLine(line []rune) []rune {
+	text := string(line)
+	matches := editor.complete(text)
+	if len(matches) == 0 {
+		return line
+	}
+	start := strings.LastIndexAny(text, " \t") + 1
+	if len(matches) == 1 {
+		return []rune(text[:start] + matches[0] + " ")
+	}
+	common := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(match, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	editor.write("\r\n%s\r\n", strings.Join(matches, "  "))
+	if len(common) > len(text)-start {
+		return []rune(text[:start] + common)
+	}
+	return line
+}
+
+// Write the line editor's output to the terminal, and only to it: not to
+// the log file or mail capture teed to (see TeeOutput), nor as routed.
+func (editor *lineEditor) write(format string, a ...interface{}) {
+	writeOutput(os.Stdout, fmt.Sprintf(format, a...))
+}
+
+func (editor *lineEditor) addHistory(line string) {
+	if n := len(editor.history); n == 0 || editor.history[n-1] != line {
+		editor.history = append(editor.history, line)
+	}
+}
+
+/*****************************************************************************\
+  Save and restore the terminal settings, via stty.
+\*****************************************************************************/
+
+func sttySave() (string, error) {
+	stty, err := ExecPath("stty")
+	if err != nil {
+		return "", Error("Command stty not found.")
+	}
+	command := exec.Command(stty, "-g")
+	command.Stdin = os.Stdin
+	output, err := command.Output()
+	if err != nil {
+		return "", Error("Failure running \"stty -g\": %v", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+/*****************************************************************************\
+  Load and save the interactive history, in the state directory.
+\*****************************************************************************/
+
+func interactiveHistoryFile() (string, error) {
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "interactive_history"), nil
+}
+
+func loadInteractiveHistory() []string {
+	filename, err := interactiveHistoryFile()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			history = append(history, line)
+		}
+	}
+	return history
+}
+
+func saveInteractiveHistory(history []string) {
+	if len(history) > interactiveHistorySize {
+		history = history[len(history)-interactiveHistorySize:]
+	}
+	filename, err := interactiveHistoryFile()
+	if err == nil {
+		err = writeFileAtomic(filename, []byte(strings.Join(history, "\n")+"\n"), 0600)
+	}
+	if err != nil {
+		ShowDebug("Failure saving the interactive history: %v", err)
+	}
+}
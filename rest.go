@@ -0,0 +1,292 @@
+package sitepkg
+
+/*****************************************************************************\
+  A simple REST (JSON over HTTP) client helper, with paging support, for the
+  API based tools.  Requests carry any RunAs/OnBehalfOf impersonation headers
+  (see ImpersonationHeaders).
+\*****************************************************************************/
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RESTClient struct {
+	BaseURL  string
+	User     string
+	Password string
+	Headers  http.Header
+	Client   *http.Client
+	Cache    *ResponseCache
+}
+
+/*****************************************************************************\
+  Create a REST client for the API at the specified base URL.
+\*****************************************************************************/
+
+func NewRESTClient(base_url string) *RESTClient {
+	return &RESTClient{
+		BaseURL: strings.TrimRight(base_url, "/"),
+		Headers: ImpersonationHeaders(),
+		Client:  &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+/*****************************************************************************\
+  Send a request, returning the response body.  The path is relative to the
+  client's BaseURL; body, if not nil, is sent as JSON.  Responses other than
+  2xx are returned as errors.
+\*****************************************************************************/
+
+func (rc *RESTClient) Do(method string, path string, query url.Values, body []byte) ([]byte, error) {
+	request_url := rc.URL(path, query)
+	status, _, response_body, err := rc.send(method, request_url, body, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response_body, checkStatus(method, request_url, status, response_body)
+}
+
+/*****************************************************************************\
+  Return the full URL for the path (relative to BaseURL) and query.
+\*****************************************************************************/
+
+func (rc *RESTClient) URL(path string, query url.Values) string {
+	request_url := rc.BaseURL + "/" + strings.TrimLeft(path, "/")
+	if len(query) > 0 {
+		request_url += "?" + query.Encode()
+	}
+	return request_url
+}
+
+/*****************************************************************************\
+  Send a request, with any extra headers, and return the response status,
+  headers and body.  Only failures to get a response are returned as errors.
+  Failures and 5xx responses count against the API's circuit breaker.
+\*****************************************************************************/
+
+func (rc *RESTClient) send(method string, request_url string, body []byte, headers http.Header) (int, http.Header, []byte, error) {
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(CommandContext(), method, request_url, reader)
+	if err != nil {
+		return 0, nil, nil, Error("Bad request %s %s: %v", method, request_url, err)
+	}
+	for name, values := range rc.Headers {
+		request.Header[name] = values
+	}
+	for name, values := range headers {
+		request.Header[name] = values
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	request.Header.Set("Accept", "application/json")
+	if rc.User != "" {
+		request.SetBasicAuth(rc.User, rc.Password)
+	}
+
+	breaker := Breaker(rc.BaseURL)
+	if err = breaker.Allow(); err != nil {
+		return 0, nil, nil, err
+	}
+	ShowDebug("REST: %s %s", method, request_url)
+	response, err := rc.Client.Do(request)
+	if err != nil {
+		breaker.Failure()
+		return 0, nil, nil, Error("Failure sending request %s %s: %v", method, request_url, err)
+	}
+	defer response.Body.Close()
+	response_body, err := io.ReadAll(response.Body)
+	if err != nil {
+		breaker.Failure()
+		return 0, nil, nil, Error("Failure reading response to %s %s: %v", method, request_url, err)
+	}
+	if response.StatusCode >= 500 {
+		breaker.Failure()
+	} else {
+		breaker.Success()
+	}
+	return response.StatusCode, response.Header, response_body, nil
+}
+
+/*****************************************************************************\
+  Return an error for responses other than 2xx.
+\*****************************************************************************/
+
+func checkStatus(method string, request_url string, status int, body []byte) error {
+	if status < 200 || status > 299 {
+		return Error("Request %s %s failed: %d %s: %s", method, request_url, status,
+			http.StatusText(status), strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Send a GET request, returning the response body.  If the client has a
+  Cache, it is used.  The pages of a Paginator are not cached: their page
+  tokens expire.
+\*****************************************************************************/
+
+func (rc *RESTClient) Get(path string, query url.Values) ([]byte, error) {
+	if rc.Cache != nil {
+		return rc.Cache.get(rc, rc.URL(path, query))
+	}
+	return rc.Do(http.MethodGet, path, query, nil)
+}
+
+/*****************************************************************************\
+  A Paginator iterates over the items of a paged API listing.  Paging is
+  either token based (each page includes the token of the next page), or,
+  if OffsetParam is set, offset based (pages are requested by offset and
+  limit until a short page is returned).  The defaults suit the Infoblox WAPI:
+  paging requested with "_paging=1&_return_as_object=1&_max_results=N", a
+  "result" list of items, and a "next_page_id" token, passed as "_page_id".
+\*****************************************************************************/
+
+type Paginator struct {
+	Client *RESTClient
+	Path   string
+	Query  url.Values
+
+	// The response field holding the page's list of items.
+	ItemsField string
+
+	// Token based paging: the response field holding the next page token,
+	// the query parameter by which it is passed, and any query parameters
+	// requesting paging.
+	TokenField  string
+	TokenParam  string
+	PagingQuery url.Values
+
+	// Offset based paging: the query parameter for the offset.
+	OffsetParam string
+
+	// The query parameter for the page size (if any in token based paging),
+	// and the page size.
+	LimitParam string
+	PageSize   int
+
+	// The minimum interval between requests, for rate limiting.
+	Interval time.Duration
+
+	// If set, called after each page with the pages and items fetched so far.
+	Progress func(pages int, items int)
+}
+
+/*****************************************************************************\
+  Create a paginator, with the defaults, for the specified listing.
+\*****************************************************************************/
+
+func NewPaginator(client *RESTClient, path string, query url.Values) *Paginator {
+	return &Paginator{
+		Client:     client,
+		Path:       path,
+		Query:      query,
+		ItemsField: "result",
+		TokenField: "next_page_id",
+		TokenParam: "_page_id",
+		PagingQuery: url.Values{
+			"_paging":           {"1"},
+			"_return_as_object": {"1"},
+		},
+		LimitParam: "_max_results",
+		PageSize:   1000,
+	}
+}
+
+/*****************************************************************************\
+  Call fn for each item of the listing, fetching pages as needed.  Stop at the
+  first error, from fetching a page or from fn, or if the run times out (see
+  CommandContext).  A page token returned twice is an error, rather than a
+  loop.
+\*****************************************************************************/
+
+func (p *Paginator) Each(fn func(item json.RawMessage) error) error {
+
+	var pages, items int
+	var token string
+	var last time.Time
+
+	if p.OffsetParam != "" && p.PageSize <= 0 {
+		return Error("Bad page size %d for %s", p.PageSize, p.Path)
+	}
+	tokens := make(map[string]bool)
+	for {
+		query := url.Values{}
+		for name, values := range p.Query {
+			query[name] = values
+		}
+		if p.OffsetParam != "" {
+			query.Set(p.OffsetParam, strconv.Itoa(items))
+			query.Set(p.LimitParam, strconv.Itoa(p.PageSize))
+		} else {
+			for name, values := range p.PagingQuery {
+				query[name] = values
+			}
+			if p.LimitParam != "" && p.PageSize > 0 {
+				query.Set(p.LimitParam, strconv.Itoa(p.PageSize))
+			}
+			if token != "" {
+				query.Set(p.TokenParam, token)
+			}
+		}
+
+		if wait := p.Interval - time.Since(last); p.Interval > 0 && wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-CommandContext().Done():
+				return CommandContextError()
+			}
+		}
+		last = time.Now()
+		body, err := p.Client.Do(http.MethodGet, p.Path, query, nil)
+		if err != nil {
+			return err
+		}
+
+		var page map[string]json.RawMessage
+		var page_items []json.RawMessage
+		if err = json.Unmarshal(body, &page); err != nil {
+			return Error("Bad page %d of %s: %v", pages+1, p.Path, err)
+		} else if err = json.Unmarshal(page[p.ItemsField], &page_items); err != nil {
+			return Error("Bad \"%s\" field in page %d of %s: %v", p.ItemsField, pages+1, p.Path, err)
+		}
+		for _, item := range page_items {
+			if err = fn(item); err != nil {
+				return err
+			}
+		}
+		pages++
+		items += len(page_items)
+		if p.Progress != nil {
+			p.Progress(pages, items)
+		}
+
+		if p.OffsetParam != "" {
+			if len(page_items) < p.PageSize {
+				return nil
+			}
+			continue
+		}
+		token = ""
+		if raw, ok := page[p.TokenField]; ok {
+			json.Unmarshal(raw, &token)
+		}
+		if token == "" {
+			return nil
+		} else if tokens[token] {
+			return Error("Page %d of %s returned a page token already seen", pages, p.Path)
+		}
+		tokens[token] = true
+	}
+}
@@ -0,0 +1,236 @@
+package sitepkg
+
+/*****************************************************************************\
+  The standard result of a batch run, so that runs which partially fail are
+  reported the same way by every tool, and orchestration wrapping the tools
+  can retry just the failures.  The convention:
+    - exit status 0 if all items succeeded, ExitPartialFailure if some
+      failed, and 1 if all failed;
+    - a summary line: "Result: partial: 8 of 10 items succeeded, 2 failed.";
+    - a JSON result block, written to the ResultFile if set (or shown in
+      Debug mode), listing the failed items with reasons:
+        {"Status":"partial","Total":10,"Succeeded":8,
+         "Failed":[{"Item":"host1","Reason":"..."},...]}
+    - the failed items written to a timestamped failures file in the state
+      dir, which may be passed to --RetryFailed to re-run just those.
+\*****************************************************************************/
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ExitPartialFailure = 3
+
+	ResultSuccess = "success"
+	ResultPartial = "partial"
+	ResultFailure = "failure"
+)
+
+type FailedItem struct {
+	Item   string
+	Reason string
+}
+
+type RunResult struct {
+	Status    string
+	Total     int
+	Succeeded int
+	Failed    []FailedItem
+	lock      sync.Mutex
+}
+
+/*****************************************************************************\
+  Define the standard result options: ResultFile, the file to which to write
+  the JSON result block, and RetryFailed, a failures file of a previous run
+  whose items to re-run (see RetryItems).
+\*****************************************************************************/
+
+func SetResultOpts() {
+	std.definePkgOpts(func() {
+		SetStringOpt("ResultFile", "", false, "", "Specify a file to which to write the JSON result of the run")
+		SetStringOpt("RetryFailed", "", false, "", "Re-run just the items in the specified failures file")
+	})
+}
+
+/*****************************************************************************\
+  Return the items to re-run, from the RetryFailed failures file, or nil if
+  RetryFailed is not set.
+\*****************************************************************************/
+
+func RetryItems() ([]string, error) {
+	failures_file, _ := pkgOpt[string]("RetryFailed")
+	if failures_file == "" {
+		return nil, nil
+	}
+	items, err := ReadListFromFile(failures_file)
+	if err != nil {
+		return nil, err
+	} else if len(items) == 0 {
+		return nil, Error("No items to retry in failures file %s.", failures_file)
+	}
+	return items, nil
+}
+
+/*****************************************************************************\
+  Create an empty run result.
+\*****************************************************************************/
+
+func NewRunResult() *RunResult {
+	return &RunResult{}
+}
+
+/*****************************************************************************\
+  Record the outcome of an item.  These are safe to call from multiple
+  goroutines.
+\*****************************************************************************/
+
+func (r *RunResult) Succeed(item string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Total++
+	r.Succeeded++
+}
+
+func (r *RunResult) Fail(item string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Total++
+	r.Failed = append(r.Failed, FailedItem{Item: item, Reason: fmt.Sprintf("%v", err)})
+}
+
+/*****************************************************************************\
+  Return the result of running against targets (see RunAgainstTargets).
+\*****************************************************************************/
+
+func TargetRunResult(results []TargetResult) *RunResult {
+	r := NewRunResult()
+	for _, result := range results {
+		if result.Err != nil {
+			r.Fail(result.Target, result.Err)
+		} else {
+			r.Succeed(result.Target)
+		}
+	}
+	return r
+}
+
+/*****************************************************************************\
+  Return the status of the run: success, partial or failure.  A run of no
+  items is a success.
+\*****************************************************************************/
+
+func (r *RunResult) status() string {
+	if len(r.Failed) == 0 {
+		return ResultSuccess
+	} else if r.Succeeded == 0 {
+		return ResultFailure
+	}
+	return ResultPartial
+}
+
+/*****************************************************************************\
+  Return the exit status for the run.
+\*****************************************************************************/
+
+func (r *RunResult) ExitCode() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	switch r.status() {
+	case ResultFailure:
+		return 1
+	case ResultPartial:
+		return ExitPartialFailure
+	}
+	return 0
+}
+
+/*****************************************************************************\
+  Return the summary line for the run.
+\*****************************************************************************/
+
+func (r *RunResult) Summary() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return fmt.Sprintf("Result: %s: %d of %d items succeeded, %d failed.",
+		r.status(), r.Succeeded, r.Total, len(r.Failed))
+}
+
+/*****************************************************************************\
+  Return the JSON result block for the run.
+\*****************************************************************************/
+
+func (r *RunResult) JSON() ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Status = r.status()
+	if r.Failed == nil {
+		r.Failed = []FailedItem{}
+	}
+	return json.Marshal(r)
+}
+
+/*****************************************************************************\
+  Write the failed items, with their reasons as comments, to a timestamped
+  failures file in the state dir (i.e.: ~/.ibapi/state/failures/
+  ibapi-host-add-20240131-120000.list).  Return the file name.
+\*****************************************************************************/
+
+func (r *RunResult) WriteFailures() (string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	dir += "/failures"
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return "", Error("Error creating directory \"%s\": %v", dir, err)
+	}
+	lines := []string{fmt.Sprintf("# Failed items of %s, %s", strings.Join(os.Args, " "), time.Now().Format(time.RFC3339))}
+	for _, failed := range r.Failed {
+		reason := strings.Join(strings.Fields(failed.Reason), " ")
+		lines = append(lines, formatListLine(failed.Item, nil, reason))
+	}
+	program := strings.ReplaceAll(ProgramName, " ", "-")
+	failures_file := dir + "/" + program + "-" + time.Now().Format("20060102-150405") + ".list"
+	return failures_file, writeListFile(failures_file, lines)
+}
+
+/*****************************************************************************\
+  Report the result of the run: show the summary line (unless in Quieter
+  mode, or if any items failed), write the failures file if any items failed,
+  write the JSON result block to ResultFile if set, and return the exit
+  status.  Typically: Exit(result.Report()).
+\*****************************************************************************/
+
+func (r *RunResult) Report() int {
+	code := r.ExitCode()
+	if code != 0 {
+		Warn("%s", r.Summary())
+		if failures_file, err := r.WriteFailures(); err != nil {
+			Warn("Failure writing the failures file: %v", err)
+		} else {
+			Warn("Failed items written to %s; to re-run them: --RetryFailed %s", failures_file, failures_file)
+		}
+	} else if !Quieter {
+		Show("%s", r.Summary())
+	}
+	data, err := r.JSON()
+	if err != nil {
+		Warn("Failure encoding the run result: %v", err)
+		return code
+	}
+	ShowDebug("Result: %s", data)
+	if result_file, _ := pkgOpt[string]("ResultFile"); result_file != "" {
+		if err = os.WriteFile(result_file, append(data, '\n'), 0644); err != nil {
+			Warn("Failure writing the run result to %s: %v", result_file, err)
+		}
+	}
+	return code
+}
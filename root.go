@@ -0,0 +1,63 @@
+package sitepkg
+
+/*****************************************************************************\
+  Alternate roots.  With --Root (or SetRoot), the absolute package paths
+  (PackageDir, PackageEtc, LocalEtc, the POD dirs and SecretsDir) are taken
+  relative to the specified directory, so that tools may run against an
+  image being built or a recovery chroot.  The user's own config dirs, in
+  the home dir, are not affected.
+\*****************************************************************************/
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+/*****************************************************************************\
+  Set the alternate root ("" or "/" for none), and the package paths and
+  config dirs accordingly.  Call before ConfigureOptions.
+\*****************************************************************************/
+
+func (c *Configurator) SetRoot(root string) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	if root != "" {
+		root = strings.TrimRight(filepath.Clean(root), "/")
+	}
+	c.Root = root
+	if c.PkgName != "" {
+		c.setPackage(c.PkgName, c.PkgVersion)
+	}
+	c.ConfigDirs = nil
+}
+
+/*****************************************************************************\
+  Return the path within the alternate root, if any, of an absolute path.
+  Relative paths are returned as is.
+\*****************************************************************************/
+
+func (c *Configurator) RootPath(path string) string {
+	if c.Root == "" || !filepath.IsAbs(path) {
+		return path
+	}
+	return c.Root + path
+}
+
+/*****************************************************************************\
+  Set the alternate root from --Root, if it is an option and is specified
+  (or passed to a plugin), before the command line has been parsed, as the
+  config dirs depend on it.
+\*****************************************************************************/
+
+func (c *Configurator) preScanRoot(args []string) {
+	if _, ok := c.Config[c.optionKey("Root")]; !ok {
+		return
+	}
+	root := preScanOption(args, "Root")
+	if root == "" {
+		root = c.passedOption("Root")
+	}
+	if root != "" && root != c.Root {
+		c.SetRoot(root)
+	}
+}
@@ -0,0 +1,171 @@
+package sitepkg
+
+/*****************************************************************************\
+  Message routing.  By default, Print output goes to DefaultPrint, Show and
+  info messages to DefaultShow, warnings and errors to DefaultErr, and debug
+  and trace messages to DefaultDebug, each teed to the log file and the mail
+  capture if any (see LogFile and MailList).  A program may route a class of
+  messages to other destinations instead:
+
+    sitepkg.SetRoute(sitepkg.ClassWarn, "stderr", "logfile")
+    sitepkg.SetRoute(sitepkg.ClassShow, "stderr")
+
+  i.e. so that nothing but the data goes to stdout with OutputFormat=json.
+  The destinations are "stdout", "stderr", "logfile", "mail", and those
+  added with SetRouteDestination; a class routed to no destinations is
+  discarded.
+\*****************************************************************************/
+
+import (
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+type MessageClass string
+
+const (
+	ClassPrint MessageClass = "print"
+	ClassShow  MessageClass = "show"
+	ClassWarn  MessageClass = "warn"
+	ClassError MessageClass = "error"
+	ClassDebug MessageClass = "debug"
+)
+
+var routes = make(map[MessageClass][]string)
+var routeDestinations = make(map[string]io.Writer)
+var routesLock sync.RWMutex
+
+/*****************************************************************************\
+  Route a class of messages to the specified destinations, or, if none are
+  specified, discard them.
+\*****************************************************************************/
+
+func SetRoute(class MessageClass, destinations ...string) error {
+	switch class {
+	case ClassPrint, ClassShow, ClassWarn, ClassError, ClassDebug:
+	default:
+		return Error("Bad message class \"%s\": expected print, show, warn, error or debug", class)
+	}
+	routesLock.Lock()
+	defer routesLock.Unlock()
+	for _, name := range destinations {
+		if !builtinRouteDestination(name) && routeDestinations[name] == nil {
+			return Error("No such route destination \"%s\"", name)
+		}
+	}
+	routes[class] = append([]string{}, destinations...)
+	return nil
+}
+
+/*****************************************************************************\
+  Restore the default routing of a class of messages, or, if none is
+  specified, of all of them.
+\*****************************************************************************/
+
+func ResetRoute(classes ...MessageClass) {
+	routesLock.Lock()
+	defer routesLock.Unlock()
+	if len(classes) == 0 {
+		routes = make(map[MessageClass][]string)
+	}
+	for _, class := range classes {
+		delete(routes, class)
+	}
+}
+
+/*****************************************************************************\
+  Add a named route destination, or, if w is nil, remove it: once no class
+  is routed to it (see SetRoute and ResetRoute).
+\*****************************************************************************/
+
+func SetRouteDestination(name string, w io.Writer) error {
+	if builtinRouteDestination(name) {
+		return Error("Route destination \"%s\" is builtin", name)
+	}
+	routesLock.Lock()
+	defer routesLock.Unlock()
+	if w == nil {
+		for class, destinations := range routes {
+			for _, destination := range destinations {
+				if destination == name {
+					return Error("Route destination \"%s\" is in use by the %s route", name, class)
+				}
+			}
+		}
+		delete(routeDestinations, name)
+	} else {
+		routeDestinations[name] = w
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Return the routes set, as "class: destination ...", sorted by class.
+\*****************************************************************************/
+
+func Routes() []string {
+	routesLock.RLock()
+	defer routesLock.RUnlock()
+	var lines []string
+	for class, destinations := range routes {
+		line := string(class) + ":"
+		for _, name := range destinations {
+			line += " " + name
+		}
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func builtinRouteDestination(name string) bool {
+	return name == "stdout" || name == "stderr" || name == "logfile" || name == "mail"
+}
+
+/*****************************************************************************\
+  Return the writer for a class of messages: the default writer, unless the
+  class is routed.  Destinations not open (i.e. "logfile" without LogFile)
+  are skipped.
+\*****************************************************************************/
+
+func classWriter(class MessageClass) io.Writer {
+	routesLock.RLock()
+	defer routesLock.RUnlock()
+	destinations, ok := routes[class]
+	if !ok {
+		switch class {
+		case ClassPrint:
+			return DefaultPrint
+		case ClassShow:
+			return DefaultShow
+		case ClassDebug:
+			return DefaultDebug
+		}
+		return DefaultErr
+	}
+	var writers []io.Writer
+	for _, name := range destinations {
+		switch name {
+		case "stdout":
+			writers = append(writers, os.Stdout)
+		case "stderr":
+			writers = append(writers, os.Stderr)
+		case "logfile":
+			if logFile != nil {
+				writers = append(writers, logFile)
+			}
+		case "mail":
+			if mailOutput != nil {
+				writers = append(writers, mailOutput)
+			}
+		default:
+			writers = append(writers, routeDestinations[name])
+		}
+	}
+	if len(writers) == 1 {
+		return writers[0]
+	}
+	return io.MultiWriter(writers...)
+}
@@ -0,0 +1,302 @@
+package sitepkg
+
+/*****************************************************************************\
+  A scaffolding generator for new site tools.  Given a declarative spec of
+  the tool's options and commands (a ToolSpec, which may be read from JSON),
+  it emits the main.go boilerplate (PackageInit, the SetXOpt calls, the
+  command dispatcher and a stub per command) and a matching POD skeleton per
+  command, so that new tools start out consistent.  For use from a small
+  go:generate program:
+
+    //go:generate go run ./scaffold tool.json
+\*****************************************************************************/
+
+import (
+	"bytes"
+	"go/format"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type ToolSpec struct {
+	Name     string
+	Version  string
+	Desc     string
+	Options  []OptionSpec
+	Commands []CommandSpec
+}
+
+type OptionSpec struct {
+	Name     string
+	ShortOpt string
+	Type     string // string, bool, int, uint or list
+	File     bool   // may also be set in config files
+	Default  string // as in a config file; lists comma separated
+	Desc     string
+}
+
+type CommandSpec struct {
+	Path    string // i.e. "host add"
+	Desc    string
+	Options []OptionSpec
+}
+
+/*****************************************************************************\
+  Write the main.go source for the tool to w.
+\*****************************************************************************/
+
+func GenerateMain(w io.Writer, spec ToolSpec) error {
+
+	var source bytes.Buffer
+
+	if spec.Name == "" {
+		return Error("Tool spec has no name.")
+	}
+	Fprintln(&source, "package main\n")
+	Fprintln(&source, "import (\n\t\"github.com/dirtman/sitepkg\"\n)\n")
+	Fprintln(&source, "func main() {")
+	Fprintln(&source, "\tif err := sitepkg.PackageInit(%s, %s); err != nil {", strconv.Quote(spec.Name), strconv.Quote(spec.Version))
+	Fprintln(&source, "\t\tsitepkg.Exit(1, err)\n\t}")
+	for _, option := range spec.Options {
+		call, err := optionCall(option)
+		if err != nil {
+			return err
+		}
+		Fprintln(&source, "\t%s", call)
+	}
+	for _, command := range spec.Commands {
+		Fprint(&source, "\tsitepkg.AddCommand(%s, %s, %s)", strconv.Quote(command.Path),
+			commandFuncName(command.Path), strconv.Quote(command.Desc))
+		if len(command.Options) > 0 {
+			Fprintln(&source, ".Options(func() {")
+			for _, option := range command.Options {
+				call, err := optionCall(option)
+				if err != nil {
+					return Error("Command \"%s\": %v", command.Path, err)
+				}
+				Fprintln(&source, "\t\t%s", call)
+			}
+			Fprint(&source, "\t})")
+		}
+		Fprintln(&source, "")
+	}
+	Fprintln(&source, "\n\targs, err := sitepkg.ConfigureOptions()")
+	Fprintln(&source, "\tif err != nil {\n\t\tsitepkg.Exit(1, err)\n\t}")
+	if len(spec.Commands) > 0 {
+		Fprintln(&source, "\tif err = sitepkg.RunCommand(args); err != nil {")
+	} else {
+		Fprintln(&source, "\tif err = run(args); err != nil {")
+	}
+	Fprintln(&source, "\t\tsitepkg.Exit(1, err)\n\t}\n\tsitepkg.Exit(0)\n}")
+
+	stubs := []CommandSpec{{Desc: spec.Desc}}
+	if len(spec.Commands) > 0 {
+		stubs = spec.Commands
+	}
+	for _, command := range stubs {
+		Fprintln(&source, "")
+		if command.Desc != "" {
+			Fprintln(&source, "// %s.", strings.TrimSuffix(command.Desc, "."))
+		}
+		Fprintln(&source, "func %s(args []string) error {", commandFuncName(command.Path))
+		Fprintln(&source, "\treturn nil\n}")
+	}
+
+	formatted, err := format.Source(source.Bytes())
+	if err != nil {
+		return Error("Failure formatting the generated source: %v", err)
+	}
+	_, err = w.Write(formatted)
+	return err
+}
+
+/*****************************************************************************\
+  Return the SetXOpt call defining an option.
+\*****************************************************************************/
+
+func optionCall(option OptionSpec) (string, error) {
+	var value string
+	switch option.Type {
+	case "string", "":
+		option.Type, value = "string", strconv.Quote(option.Default)
+	case "bool":
+		if option.Default == "" {
+			option.Default = "false"
+		}
+		if _, err := strconv.ParseBool(option.Default); err != nil {
+			return "", Error("Bad default \"%s\" for bool option \"%s\"", option.Default, option.Name)
+		}
+		value = option.Default
+	case "int", "uint":
+		if option.Default == "" {
+			option.Default = "0"
+		}
+		if _, err := strconv.ParseInt(option.Default, 10, 64); err != nil ||
+			(option.Type == "uint" && strings.HasPrefix(option.Default, "-")) {
+			return "", Error("Bad default \"%s\" for %s option \"%s\"", option.Default, option.Type, option.Name)
+		}
+		value = option.Default
+	case "list":
+		var items []string
+		for _, item := range strings.Split(option.Default, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, strconv.Quote(item))
+			}
+		}
+		value = "[]string{" + strings.Join(items, ", ") + "}"
+	default:
+		return "", Error("Bad type \"%s\" for option \"%s\"", option.Type, option.Name)
+	}
+	if option.Name == "" {
+		return "", Error("Option spec has no name.")
+	}
+	set_func := "Set" + strings.ToUpper(option.Type[:1]) + option.Type[1:] + "Opt"
+	return "sitepkg." + set_func + "(" + strconv.Quote(option.Name) + ", " + strconv.Quote(option.ShortOpt) + ", " +
+		strconv.FormatBool(option.File) + ", " + value + ", " + strconv.Quote(option.Desc) + ")", nil
+}
+
+/*****************************************************************************\
+  Return the name of the function stub for a command path: i.e. "hostAdd"
+  for "host add", or "run" for the tool itself.
+\*****************************************************************************/
+
+func commandFuncName(path string) string {
+	words := strings.FieldsFunc(path, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	if len(words) == 0 {
+		return "run"
+	}
+	name := strings.ToLower(words[0])
+	for _, word := range words[1:] {
+		name += strings.ToUpper(word[:1]) + strings.ToLower(word[1:])
+	}
+	if !unicode.IsLetter(rune(name[0])) {
+		name = "run" + strings.ToUpper(name[:1]) + name[1:]
+	}
+	return name
+}
+
+/*****************************************************************************\
+  Write a POD skeleton for the tool ("" path), or one of its commands, to w:
+  name, synopsis, description, options (the command's, then the tool's) and
+  subcommands, to be filled in.
+\*****************************************************************************/
+
+func GeneratePod(w io.Writer, spec ToolSpec, path string) error {
+
+	var command *CommandSpec
+	var options []OptionSpec
+
+	desc := spec.Desc
+	words := strings.Join(strings.Fields(strings.ReplaceAll(path, ":", " ")), " ")
+	for i := range spec.Commands {
+		if strings.Join(strings.Fields(spec.Commands[i].Path), " ") == words {
+			command = &spec.Commands[i]
+		}
+	}
+	if words != "" && command == nil {
+		return Error("Unknown command \"%s\"", words)
+	} else if command != nil {
+		desc = command.Desc
+		options = append(options, command.Options...)
+	}
+	options = append(options, spec.Options...)
+	name := strings.TrimSpace(spec.Name + " " + words)
+
+	Fprintln(w, "=head1 NAME\n")
+	Fprintln(w, "%s - %s\n", name, desc)
+	Fprintln(w, "=head1 SYNOPSIS\n")
+	Fprintln(w, "%s [options] [arguments]\n", name)
+	Fprintln(w, "=head1 DESCRIPTION\n")
+	Fprintln(w, "%s.\n", strings.TrimSuffix(desc, "."))
+
+	var subcommands []CommandSpec
+	for _, subcommand := range spec.Commands {
+		sub_words := strings.Fields(subcommand.Path)
+		if len(sub_words) > 0 && strings.Join(sub_words[:len(sub_words)-1], " ") == words {
+			subcommands = append(subcommands, subcommand)
+		}
+	}
+	if len(subcommands) > 0 {
+		Fprintln(w, "=head1 COMMANDS\n\n=over 4\n")
+		for _, subcommand := range subcommands {
+			Fprintln(w, "=item B<%s>\n", strings.Join(strings.Fields(subcommand.Path), " "))
+			Fprintln(w, "%s.\n", strings.TrimSuffix(subcommand.Desc, "."))
+		}
+		Fprintln(w, "=back\n")
+	}
+
+	if len(options) > 0 {
+		Fprintln(w, "=head1 OPTIONS\n\n=over 4\n")
+		for _, option := range options {
+			item := "B<--" + option.Name + ">"
+			if option.Type != "bool" {
+				option_type := option.Type
+				if option_type == "" {
+					option_type = "string"
+				}
+				item += "=I<" + option_type + ">"
+			}
+			if option.ShortOpt != "" {
+				item += ", B<-" + option.ShortOpt + ">"
+			}
+			Fprintln(w, "=item %s\n", item)
+			Fprint(w, "%s.", strings.TrimSuffix(option.Desc, "."))
+			if option.Default != "" {
+				Fprint(w, "  Default: %s.", option.Default)
+			}
+			Fprintln(w, "\n")
+		}
+		Fprintln(w, "=back\n")
+	}
+	Fprintln(w, "=cut")
+	return nil
+}
+
+/*****************************************************************************\
+  Generate the tool's main.go, and its POD skeletons (in pod1, named as
+  FindPodFile expects: i.e. "ibapi", "host:add"), in the specified
+  directory.  Existing files are not overwritten.
+\*****************************************************************************/
+
+func GenerateScaffold(dir string, spec ToolSpec) error {
+
+	write := func(filename string, generate func(w io.Writer) error) error {
+		if _, err := os.Stat(filename); err == nil {
+			Show("Not overwriting %s", filename)
+			return nil
+		}
+		var data bytes.Buffer
+		if err := generate(&data); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filename, data.Bytes(), 0644); err != nil {
+			return err
+		}
+		Show("Generated %s", filename)
+		return nil
+	}
+
+	err := write(filepath.Join(dir, "main.go"), func(w io.Writer) error { return GenerateMain(w, spec) })
+	if err != nil {
+		return err
+	}
+	paths := []string{""}
+	for _, command := range spec.Commands {
+		paths = append(paths, command.Path)
+	}
+	for _, path := range paths {
+		name := spec.Name
+		if path != "" {
+			name = strings.Join(strings.Fields(strings.ReplaceAll(path, ":", " ")), ":")
+		}
+		err = write(filepath.Join(dir, "pod1", name), func(w io.Writer) error { return GeneratePod(w, spec, path) })
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
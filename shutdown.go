@@ -0,0 +1,145 @@
+package sitepkg
+
+/*****************************************************************************\
+  Graceful shutdown.  Cleanups registered with RegisterCleanup or OnExit
+  (i.e. to flush buffered output, write state, or remove temporary files
+  and pid files; see RemoveOnExit and WritePidFile) are run, most recently
+  registered first, when the program exits via Exit (or Fatal,
+  ExitWithError, etc), or on SIGINT or SIGTERM.  On a signal, the command
+  context (see CommandContext) is also cancelled, and the program exits
+  with the conventional 128 + the signal number (130 for SIGINT, 143 for
+  SIGTERM); a second signal exits at once.
+
+  Programs returning from main rather than calling Exit should defer
+  RunCleanups.
+\*****************************************************************************/
+
+import (
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+var cleanups []func()
+var cleanupsLock sync.Mutex
+var signalsOnce sync.Once
+var exitStatus int
+
+/*****************************************************************************\
+  Register a function to run at exit.
+\*****************************************************************************/
+
+func RegisterCleanup(cleanup func()) {
+	cleanupsLock.Lock()
+	defer cleanupsLock.Unlock()
+	cleanups = append(cleanups, cleanup)
+}
+
+/*****************************************************************************\
+  Register a function to run at exit, given the exit status (see
+  ExitStatus); run with the cleanups, in the same order.
+\*****************************************************************************/
+
+func OnExit(hook func(code int)) {
+	RegisterCleanup(func() { hook(exitStatus) })
+}
+
+/*****************************************************************************\
+  Run the registered cleanups, most recently registered first, each once: a
+  cleanup which panics is reported, and the others still run.
+\*****************************************************************************/
+
+func RunCleanups() {
+	for {
+		cleanupsLock.Lock()
+		n := len(cleanups)
+		if n == 0 {
+			cleanupsLock.Unlock()
+			return
+		}
+		cleanup := cleanups[n-1]
+		cleanups = cleanups[:n-1]
+		cleanupsLock.Unlock()
+		runCleanup(cleanup)
+	}
+}
+
+func runCleanup(cleanup func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			Fwarn(DefaultErr, "Failure running cleanup: %v", r)
+		}
+	}()
+	cleanup()
+}
+
+/*****************************************************************************\
+  Return the status with which the program is exiting, for cleanups: that
+  given to Exit, or 128 + the signal number.
+\*****************************************************************************/
+
+func ExitStatus() int {
+	return exitStatus
+}
+
+/*****************************************************************************\
+  Remove the specified file (or directory, with its contents) at exit: i.e.
+  a temporary file.
+\*****************************************************************************/
+
+func RemoveOnExit(path string) {
+	RegisterCleanup(func() {
+		if err := os.RemoveAll(path); err != nil {
+			ShowDebug("Failure removing %s: %v", path, err)
+		}
+	})
+}
+
+/*****************************************************************************\
+  Write the process ID to the specified pid file, removed at exit.
+\*****************************************************************************/
+
+func WritePidFile(filename string) error {
+	if err := writeFileAtomic(filename, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return err
+	}
+	RemoveOnExit(filename)
+	return nil
+}
+
+/*****************************************************************************\
+  Handle SIGINT and SIGTERM: cancel the command context, run the cleanups,
+  and exit.  Started by PackageInit.
+\*****************************************************************************/
+
+func handleSignals() {
+	signalsOnce.Do(func() {
+		signals := make(chan os.Signal, 2)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			sig := <-signals
+			ShowDebug("Received %v; shutting down", sig)
+			go func() {
+				<-signals
+				os.Exit(signalExitCode(sig))
+			}()
+			commandCtxLock.Lock()
+			if commandCancel != nil {
+				commandCancel()
+			}
+			commandCtxLock.Unlock()
+			exitStatus = signalExitCode(sig)
+			RunCleanups()
+			os.Exit(signalExitCode(sig))
+		}()
+	})
+}
+
+func signalExitCode(sig os.Signal) int {
+	if number, ok := sig.(syscall.Signal); ok {
+		return 128 + int(number)
+	}
+	return 1
+}
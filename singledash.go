@@ -0,0 +1,80 @@
+package sitepkg
+
+/*****************************************************************************\
+  Single dash long options, for compatibility with the legacy (Perl) site
+  tools, which accepted "-verbose" as well as "--verbose".  When enabled, a
+  single dash argument naming a long option ("-Verbose", "-tenant=lab") is
+  taken as that option, while other single dash arguments remain short
+  options ("-v", "-vq").  Arguments after "--", or after the first argument
+  when option parsing stops there (see SetInterspersed), are left as is.
+\*****************************************************************************/
+
+import (
+	"strings"
+)
+
+/*****************************************************************************\
+  Set whether single dash long options are accepted.  Call before
+  ConfigureOptions.
+\*****************************************************************************/
+
+func (c *Configurator) SetSingleDashLongOptions(accept bool) {
+	c.writeLock()
+	defer c.lock.Unlock()
+	c.singleDash = accept
+}
+
+/*****************************************************************************\
+  Return the arguments with any single dash long options given the double
+  dash, if single dash long options are accepted.  The arguments are those
+  of the command line, or, if command is set, those following the words of
+  the (found) command, whose options are then known.
+\*****************************************************************************/
+
+func (c *Configurator) normalizeArgs(args []string, command string) []string {
+
+	path, found := command, command != ""
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if !c.singleDash {
+		return args
+	}
+	normalized := append([]string(nil), args...)
+	for i := 0; i < len(normalized); i++ {
+		arg := normalized[i]
+		if arg == "--" {
+			break
+		} else if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			name := strings.SplitN(arg[1:], "=", 2)[0]
+			if _, ok := c.Config[c.optionKey(name)]; ok {
+				ShowDebug("Taking single dash option \"%s\" as \"-%s\"", arg, arg)
+				normalized[i] = "-" + arg
+			}
+		}
+		if arg = normalized[i]; strings.HasPrefix(arg, "-") && arg != "-" {
+			if c.takesValue(arg) {
+				i++
+			}
+			continue
+		}
+
+		// Command words, as in findCommand, then the arguments.
+		next := arg
+		if path != "" {
+			next = path + ":" + arg
+		}
+		if canonical, ok := c.aliases[next]; ok {
+			next = canonical
+		}
+		if _, ok := c.commands[next]; ok && !found {
+			path = next
+			continue
+		}
+		if c.noIntersperse || c.commandStopsAtArgument(path) || (!found && c.pluginFor(path, arg) != "") {
+			break
+		}
+		found = true
+	}
+	return normalized
+}
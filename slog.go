@@ -0,0 +1,139 @@
+//go:build go1.21
+
+package sitepkg
+
+/*****************************************************************************\
+  Integration with log/slog (Go 1.21 and later), both ways:
+
+    - NewSlogHandler returns a slog.Handler logging via sitepkg's leveled
+      logging (see Tracef, etc), so that packages using slog log like the
+      rest of the tool:
+
+        slog.SetDefault(slog.New(sitepkg.NewSlogHandler()))
+
+    - SetSlogLogger diverts the output of Print, Show, Warn and the leveled
+      logging functions to a slog.Logger, i.e. for daemons logging
+      structured records: Print and Show output at the info level, and the
+      leveled functions at theirs (trace is slog.LevelDebug-4), with any
+      fields (see Showf and Logf) as attributes.
+
+  The attributes of records logged via NewSlogHandler are written as fields
+  (see Logf), named group.key for groups.
+\*****************************************************************************/
+
+import (
+	"context"
+	"log/slog"
+)
+
+const SlogLevelTrace = slog.LevelDebug - 4
+
+type slogHandler struct {
+	attrs  Fields
+	prefix string
+}
+
+/*****************************************************************************\
+  Return a slog.Handler logging via sitepkg's leveled logging.
+\*****************************************************************************/
+
+func NewSlogHandler() slog.Handler {
+	return &slogHandler{}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return logWanted(fromSlogLevel(level))
+}
+
+func (h *slogHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := make(Fields)
+	for name, value := range h.attrs {
+		fields[name] = value
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		addSlogAttr(fields, h.prefix, attr)
+		return true
+	})
+	writeLog(fromSlogLevel(record.Level), record.Message, fields)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := &slogHandler{attrs: make(Fields), prefix: h.prefix}
+	for name, value := range h.attrs {
+		handler.attrs[name] = value
+	}
+	for _, attr := range attrs {
+		addSlogAttr(handler.attrs, h.prefix, attr)
+	}
+	return handler
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &slogHandler{attrs: h.attrs, prefix: h.prefix + name + "."}
+}
+
+// Add an attribute to the fields, flattening groups.
+func addSlogAttr(fields Fields, prefix string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, member := range value.Group() {
+			addSlogAttr(fields, prefix, member)
+		}
+	} else if attr.Key != "" {
+		fields[prefix+attr.Key] = value.Any()
+	}
+}
+
+/*****************************************************************************\
+  Divert the output of Print, Show, Warn and the leveled logging functions
+  to the specified logger, or, if logger is nil, restore it.
+\*****************************************************************************/
+
+func SetSlogLogger(logger *slog.Logger) {
+	if logger == nil {
+		logSink = nil
+		return
+	}
+	logSink = func(level LogLevel, message string, fields Fields) {
+		var attrs []any
+		for _, name := range sortedFieldNames(fields) {
+			attrs = append(attrs, slog.Any(name, fields[name]))
+		}
+		logger.Log(context.Background(), toSlogLevel(level), message, attrs...)
+	}
+}
+
+func toSlogLevel(level LogLevel) slog.Level {
+	switch level {
+	case LevelTrace:
+		return SlogLevelTrace
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
+}
+
+func fromSlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelDebug:
+		return LevelTrace
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	}
+	return LevelError
+}
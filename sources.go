@@ -0,0 +1,156 @@
+package sitepkg
+
+/*****************************************************************************\
+  Option sources.  Each option has a mask of the sources which may set it:
+  the command line, config files and the environment.  By default, options
+  may be set on the command line, and in config files if so defined; the
+  environment is opt-in.  SetOptSources declares options CLI-only (i.e.
+  --Force), file-only (i.e. site policy knobs) or env-only.  An option set by
+  a disallowed source is an error naming that source, except that the
+  environment variables of options not set by the environment are ignored.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+type OptionSource uint
+
+const (
+	SourceCommandLine OptionSource = 1 << iota
+	SourceFile
+	SourceEnv
+	SourceAny = SourceCommandLine | SourceFile | SourceEnv
+)
+
+var sourceNames = []struct {
+	source OptionSource
+	name   string
+}{
+	{SourceCommandLine, "command line"},
+	{SourceFile, "config file"},
+	{SourceEnv, "environment"},
+}
+
+/*****************************************************************************\
+  Return the sources in the mask, i.e. "command line or config file".
+\*****************************************************************************/
+
+func (sources OptionSource) String() string {
+	var names []string
+	for _, entry := range sourceNames {
+		if sources&entry.source != 0 {
+			names = append(names, entry.name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, " or ")
+}
+
+/*****************************************************************************\
+  Encode the sources by name, i.e. in JSON output.
+\*****************************************************************************/
+
+func (sources OptionSource) MarshalText() ([]byte, error) {
+	return []byte(sources.String()), nil
+}
+
+/*****************************************************************************\
+  Return the sources for an option defined with the "file" argument of the
+  Set*Opt functions.
+\*****************************************************************************/
+
+func fileSources(file bool) OptionSource {
+	if file {
+		return SourceCommandLine | SourceFile
+	}
+	return SourceCommandLine
+}
+
+func sourceViolation(option *Option, source OptionSource) string {
+	if option.derive != nil {
+		return "it is derived from other options"
+	}
+	return fmt.Sprintf("may not be set by the %s (only by the %s)", source, option.Sources)
+}
+
+/*****************************************************************************\
+  Set the sources which may set an option.  Call before ConfigureOptions.
+\*****************************************************************************/
+
+func (c *Configurator) SetOptSources(name string, sources OptionSource) error {
+	c.writeLock()
+	defer c.lock.Unlock()
+	option, ok := c.Config[c.optionKey(name)]
+	if !ok {
+		return Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	option.Sources = sources
+	return nil
+}
+
+/*****************************************************************************\
+  Set the value of a string or list option given on the command line without
+  one: i.e. "all", for --Debug as --Debug=all.  A value must then be given
+  as --Debug=value, not --Debug value.  Call before ConfigureOptions.
+\*****************************************************************************/
+
+func (c *Configurator) SetOptNoValue(name string, value string) error {
+	c.writeLock()
+	defer c.lock.Unlock()
+	option, ok := c.Config[c.optionKey(name)]
+	if !ok {
+		return Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	} else if option.Type != "string" && option.Type != "list" {
+		return Error("SetOptNoValue: bad call for %s \"%s\".", option.Type, name)
+	}
+	option.noValue = value
+	return nil
+}
+
+/*****************************************************************************\
+  Return the environment variable for an option: the package and option
+  names, in upper case, i.e. IBAPI_USERNAME.
+\*****************************************************************************/
+
+func (c *Configurator) EnvVarName(name string) string {
+	return strings.ToUpper(c.PkgName + "_" + name)
+}
+
+/*****************************************************************************\
+  Set options from their environment variables, if set.  Options passed by
+  the program running a plugin (see runPlugin) are set whatever their
+  sources.
+\*****************************************************************************/
+
+func (c *Configurator) readEnvironment() error {
+	c.writeLock()
+	defer c.lock.Unlock()
+	passed := c.pluginOptions()
+	for name, option := range c.Config {
+		env_var := c.EnvVarName(name)
+		value, ok := os.LookupEnv(env_var)
+		if !ok {
+			continue
+		} else if option.Sources&SourceEnv == 0 && !passed[name] {
+			// Not an error: environment variables are not always set for us.
+			ShowDebug("Ignoring environment variable %s: option \"%s\" %s", env_var, name,
+				sourceViolation(option, SourceEnv))
+			continue
+		} else if option.Final {
+			Warn("Ignoring environment variable %s; option \"%s\" is final, set by %s",
+				env_var, name, option.Source)
+			continue
+		}
+		option.Source = "env:" + env_var
+		if err := setOptionValue(option, name, value); err != nil {
+			return Error("%s in environment variable %s", err, env_var)
+		}
+		recordAssignment(option, 0)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for running commands on remote hosts via ssh.  We use the ssh
+  command, so the user's ssh config, known hosts and agent all apply.
+\*****************************************************************************/
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SSHResult struct {
+	Host     string
+	Command  string
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+/*****************************************************************************\
+  Define the standard ssh options: SSHUser, SSHKey (if not set, the ssh agent
+  or the user's default keys are used) and SSHTimeout (in seconds).
+\*****************************************************************************/
+
+func SetSSHOpts() {
+	std.definePkgOpts(func() {
+		SetStringOpt("SSHUser", "", true, "", "Specify the remote user for ssh")
+		SetStringOpt("SSHKey", "", true, "", "Specify the private key file for ssh (default: agent)")
+		SetIntOpt("SSHTimeout", "", true, 60, "Specify the timeout in seconds for ssh commands")
+	})
+}
+
+/*****************************************************************************\
+  Check that a host name is usable as the destination of ssh (or scp): a
+  name starting with "-" would be taken as an option, i.e. "-oProxyCommand=".
+\*****************************************************************************/
+
+func checkSSHHost(host string) error {
+	if host == "" {
+		return Error("No host specified for ssh")
+	} else if strings.HasPrefix(host, "-") {
+		return Error("Illegal host name \"%s\" for ssh", host)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Return the ssh command arguments for the specified host, per the options.
+  The host follows "--", so that it is never taken as an option.
+\*****************************************************************************/
+
+func sshArgs(host string, timeout int) []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if timeout > 0 {
+		args = append(args, "-o", "ConnectTimeout="+strconv.Itoa(timeout))
+	}
+	if key, _ := pkgOpt[string]("SSHKey"); key != "" {
+		args = append(args, "-i", key)
+	}
+	if user, _ := pkgOpt[string]("SSHUser"); user != "" {
+		args = append(args, "-l", user)
+	}
+	return append(args, "--", host)
+}
+
+/*****************************************************************************\
+  Run a command on the specified host, capturing its output.  In Verbose
+  mode, the output is also shown, prefixed by the host name.  An error is
+  returned if ssh fails, the command times out, or the command exits with a
+  non-zero status (see SSHResult.ExitCode).  Connection failures and timeouts
+  count against the host's circuit breaker.
+\*****************************************************************************/
+
+func RunSSH(host string, command string) (SSHResult, error) {
+	return runSSH(host, command, nil, nil)
+}
+
+/*****************************************************************************\
+  Run a command on the specified host as RunSSH does, but with its stdin read
+  from stdin, and its stdout written to stdout rather than captured, if not
+  nil: i.e. to stream files.  Streaming commands are not limited by
+  SSHTimeout, which then applies only to connecting.
+\*****************************************************************************/
+
+func runSSH(host string, command string, stdin io.Reader, stdout io.Writer) (SSHResult, error) {
+
+	var output, stderr bytes.Buffer
+
+	result := SSHResult{Host: host, Command: command}
+	if err := checkSSHHost(host); err != nil {
+		return result, err
+	}
+	ssh, err := ExecPath("ssh")
+	if err != nil {
+		return result, Error("Command ssh not found.")
+	}
+	breaker := Breaker("ssh:" + host)
+	if err = breaker.Allow(); err != nil {
+		return result, err
+	}
+	timeout, _ := pkgOpt[int]("SSHTimeout")
+	ctx := CommandContext()
+	if timeout > 0 && stdin == nil && stdout == nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+
+	ShowDebug("RunSSH: %s: %s", host, command)
+	ssh_command := exec.CommandContext(ctx, ssh, append(sshArgs(host, timeout), command)...)
+	ssh_command.Stdin = stdin
+	ssh_command.Stdout = &output
+	if stdout != nil {
+		ssh_command.Stdout = stdout
+	}
+	ssh_command.Stderr = &stderr
+	err = ssh_command.Run()
+
+	result.Stdout = output.String()
+	result.Stderr = stderr.String()
+	if Verbose {
+		showRemoteOutput(host, result.Stdout)
+		showRemoteOutput(host, result.Stderr)
+	}
+
+	var exit_error *exec.ExitError
+	if context_err := CommandContextError(); context_err != nil {
+		return result, Error("%s: %v", host, context_err)
+	} else if ctx.Err() == context.DeadlineExceeded {
+		breaker.Failure()
+		return result, Error("%s: command timed out after %d seconds", host, timeout)
+	} else if errors.As(err, &exit_error) {
+		result.ExitCode = exit_error.ExitCode()
+		if result.ExitCode == 255 {
+			breaker.Failure()
+			return result, Error("%s: ssh failed: %s", host, strings.TrimSpace(result.Stderr))
+		}
+		breaker.Success()
+		return result, Error("%s: command exited with status %d", host, result.ExitCode)
+	} else if err != nil {
+		return result, Error("%s: failure running ssh: %v", host, err)
+	}
+	breaker.Success()
+	return result, nil
+}
+
+func showRemoteOutput(host string, output string) {
+	for _, line := range strings.Split(strings.TrimRight(output, "\n"), "\n") {
+		if line != "" {
+			Show("%s: %s", host, line)
+		}
+	}
+}
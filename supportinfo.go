@@ -0,0 +1,173 @@
+package sitepkg
+
+/*****************************************************************************\
+  The support info report, for --SupportInfo: a single report of what we ask
+  users to paste into tickets.  It lists the version, the config files read
+  and the options changed from their defaults (with their sources), a
+  summary of the environment, the results of any self tests (see
+  AddSelfTest), and the latest failures files (of recent runs).  Values of
+  options and environment variables whose names suggest secrets are
+  redacted.  Shown as text, or as JSON if OutputFormat=json.
+\*****************************************************************************/
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+type selfTest struct {
+	name string
+	test func() error
+}
+
+type SupportInfo struct {
+	Program     string
+	PkgName     string
+	PkgVersion  string
+	GoVersion   string
+	Platform    string
+	Hostname    string
+	Identity    string
+	Tenant      string `json:",omitempty"`
+	ConfigFiles []string
+	Changed     []OptionInfo
+	Environment map[string]string
+	SelfTests   map[string]string
+	RecentRuns  []string
+}
+
+var selfTests []selfTest
+
+var secretNameRegexp = regexp.MustCompile("(?i)pass|secret|token|key|credential")
+
+const redacted = "<redacted>"
+
+// The general environment variables reported, besides the package's own.
+var supportEnvVars = []string{"PATH", "SHELL", "TERM", "LANG", "PAGER"}
+
+/*****************************************************************************\
+  Add a self test (i.e. "api": can we reach the API?) to be run and reported
+  by --SupportInfo.
+\*****************************************************************************/
+
+func AddSelfTest(name string, test func() error) {
+	selfTests = append(selfTests, selfTest{name: name, test: test})
+}
+
+/*****************************************************************************\
+  Gather the support info.
+\*****************************************************************************/
+
+func GetSupportInfo() SupportInfo {
+
+	hostname, _ := os.Hostname()
+	info := SupportInfo{
+		Program:     ProgramName,
+		PkgName:     PkgName,
+		PkgVersion:  PkgVersion,
+		GoVersion:   runtime.Version(),
+		Platform:    runtime.GOOS + "/" + runtime.GOARCH,
+		Hostname:    hostname,
+		Identity:    Identity(),
+		Tenant:      Tenant,
+		ConfigFiles: ConfigFilesRead,
+		Environment: make(map[string]string),
+		SelfTests:   make(map[string]string),
+	}
+
+	EachOption(func(option OptionInfo) bool {
+		if !valuesEqual(option.Value, option.Default) {
+			if secretNameRegexp.MatchString(option.Name) {
+				option.Value = redacted
+			}
+			info.Changed = append(info.Changed, option)
+		}
+		return true
+	})
+
+	env_prefix := strings.ToUpper(PkgName) + "_"
+	for _, entry := range os.Environ() {
+		pair := strings.SplitN(entry, "=", 2)
+		if in_list, _ := InList(supportEnvVars, pair[0]); in_list || strings.HasPrefix(pair[0], env_prefix) {
+			if secretNameRegexp.MatchString(pair[0]) {
+				pair[1] = redacted
+			}
+			info.Environment[pair[0]] = pair[1]
+		}
+	}
+
+	for _, entry := range selfTests {
+		if err := entry.test(); err != nil {
+			info.SelfTests[entry.name] = "FAILED: " + err.Error()
+		} else {
+			info.SelfTests[entry.name] = "ok"
+		}
+	}
+
+	if dir, err := StateDir(); err == nil {
+		runs, _ := filepath.Glob(dir + "/failures/*.list")
+		sort.Sort(sort.Reverse(sort.StringSlice(runs)))
+		if len(runs) > 5 {
+			runs = runs[:5]
+		}
+		info.RecentRuns = runs
+	}
+	return info
+}
+
+/*****************************************************************************\
+  Show the support info report.
+\*****************************************************************************/
+
+func ShowSupportInfo() {
+	info := GetSupportInfo()
+	if JSONOutput() {
+		showJSON(info)
+		return
+	}
+	Println("Support info for %s:", info.Program)
+	Println("  Package: %s %s", info.PkgName, info.PkgVersion)
+	Println("  Go: %s (%s)", info.GoVersion, info.Platform)
+	Println("  Host: %s", info.Hostname)
+	Println("  Identity: %s", info.Identity)
+	if info.Tenant != "" {
+		Println("  Tenant: %s", info.Tenant)
+	}
+	Println("Config files read:")
+	for _, config_file := range info.ConfigFiles {
+		Println("  %s", config_file)
+	}
+	Println("Changed settings:")
+	for _, option := range info.Changed {
+		Println("  %-20s %v  (%s)", option.Name, option.Value, option.Source)
+	}
+	Println("Environment:")
+	for _, name := range sortedStringKeys(info.Environment) {
+		Println("  %s=%s", name, info.Environment[name])
+	}
+	if len(info.SelfTests) > 0 {
+		Println("Self tests:")
+		for _, name := range sortedStringKeys(info.SelfTests) {
+			Println("  %s: %s", name, info.SelfTests[name])
+		}
+	}
+	if len(info.RecentRuns) > 0 {
+		Println("Recent failures files:")
+		for _, run := range info.RecentRuns {
+			Println("  %s", run)
+		}
+	}
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
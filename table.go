@@ -0,0 +1,153 @@
+package sitepkg
+
+/*****************************************************************************\
+  Tables.  A Table writes rows in columns sized to fit their values, rather
+  than hand-formatted with %-20s:
+
+    table := sitepkg.NewTable("Host", "Address", "Comment")
+    table.MaxWidth = 40
+    table.Add("www.example.com", "10.1.1.1", comment)
+    table.Write()
+
+  which writes:
+
+    Host             Address   Comment
+    www.example.com  10.1.1.1  Web server
+
+  or, with Border set:
+
+    +-----------------+----------+------------+
+    | Host            | Address  | Comment    |
+    +-----------------+----------+------------+
+    | www.example.com | 10.1.1.1 | Web server |
+    +-----------------+----------+------------+
+
+  Values longer than MaxWidth (if set) are truncated, ending in "...", and
+  each line is prefixed with Indent.  A table of no headers has no header
+  line.  Unlike a Formatter (see OutputFormat), a Table is always written
+  as a table.
+\*****************************************************************************/
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+type Table struct {
+	Headers  []string
+	Border   bool
+	MaxWidth int
+	Indent   string
+	rows     [][]string
+}
+
+/*****************************************************************************\
+  Create a table of the specified headers, or, if none, of no header line
+  (its columns set by the first row added).
+\*****************************************************************************/
+
+func NewTable(headers ...string) *Table {
+	return &Table{Headers: headers}
+}
+
+/*****************************************************************************\
+  Add a row.  A row of other than one value per column is a programming
+  error.
+\*****************************************************************************/
+
+func (t *Table) Add(values ...interface{}) {
+	if columns := t.columns(); columns > 0 && len(values) != columns {
+		panic(Error("programming error: Table.Add: %d values for %d columns", len(values), columns))
+	}
+	t.rows = append(t.rows, formatCells(values))
+}
+
+func (t *Table) columns() int {
+	if len(t.Headers) > 0 {
+		return len(t.Headers)
+	} else if len(t.rows) > 0 {
+		return len(t.rows[0])
+	}
+	return 0
+}
+
+/*****************************************************************************\
+  Write the table to DefaultPrint, or, with Fwrite, to the specified writer.
+\*****************************************************************************/
+
+func (t *Table) Write() {
+	t.Fwrite(classWriter(ClassPrint))
+}
+
+func (t *Table) Fwrite(w io.Writer) {
+	writeOutput(w, t.String())
+}
+
+/*****************************************************************************\
+  Return the table as text, one line per row.
+\*****************************************************************************/
+
+func (t *Table) String() string {
+	rows := t.rows
+	if len(t.Headers) > 0 {
+		rows = append([][]string{t.Headers}, rows...)
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+	widths := make([]int, t.columns())
+	cells := make([][]string, len(rows))
+	for n, row := range rows {
+		cells[n] = make([]string, len(row))
+		for i := range row {
+			cells[n][i] = truncateCell(row[i], t.MaxWidth)
+			if width := utf8.RuneCountInString(cells[n][i]); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	var text strings.Builder
+	rule := t.Indent
+	if t.Border {
+		for _, width := range widths {
+			rule += "+" + strings.Repeat("-", width+2)
+		}
+		rule += "+\n"
+		text.WriteString(rule)
+	}
+	for n, row := range cells {
+		line := t.Indent
+		for i, cell := range row {
+			padding := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			if t.Border {
+				line += "| " + cell + padding + " "
+			} else if i < len(row)-1 {
+				line += cell + padding + "  "
+			} else {
+				line += cell
+			}
+		}
+		if t.Border {
+			line += "|"
+		}
+		text.WriteString(strings.TrimRight(line, " ") + "\n")
+		if t.Border && (n == len(cells)-1 || (n == 0 && len(t.Headers) > 0)) {
+			text.WriteString(rule)
+		}
+	}
+	return text.String()
+}
+
+// Truncate a value to the width (if not 0), ending it in "...".
+func truncateCell(value string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(value) <= width {
+		return value
+	}
+	runes := []rune(value)
+	if width <= 3 {
+		return string(runes[:width])
+	}
+	return string(runes[:width-3]) + "..."
+}
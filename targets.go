@@ -0,0 +1,121 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for running an action against multiple targets (servers, grid
+  members, etc) concurrently, and reporting the per-target results along
+  with a success/failure summary.
+\*****************************************************************************/
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+type TargetResult struct {
+	Target   string
+	Output   string
+	Err      error
+	Duration time.Duration
+}
+
+/*****************************************************************************\
+  Define the standard options for specifying targets: Targets (a comma
+  separated list), TargetsFile (a package list file) and Parallel (the
+  maximum number of targets to run against at once).
+\*****************************************************************************/
+
+func SetTargetOpts() {
+	std.definePkgOpts(func() {
+		SetStringOpt("Targets", "", true, "", "Specify a comma separated list of targets")
+		SetStringOpt("TargetsFile", "", true, "", "Specify a file listing the targets, one per line")
+		SetIntOpt("Parallel", "", true, 10, "Specify the maximum number of targets to run against at once")
+	})
+}
+
+/*****************************************************************************\
+  Return the targets specified via the Targets and TargetsFile options, or,
+  if the result options are defined and RetryFailed is set, the targets in
+  that failures file.
+\*****************************************************************************/
+
+func GetTargets() (targets []string, err error) {
+
+	if targets, err = RetryItems(); err != nil || targets != nil {
+		return targets, err
+	}
+
+	if list, _ := pkgOpt[string]("Targets"); list != "" {
+		for _, target := range strings.Split(list, ",") {
+			if target = strings.TrimSpace(target); target != "" {
+				targets = append(targets, target)
+			}
+		}
+	}
+	if filename, _ := pkgOpt[string]("TargetsFile"); filename != "" {
+		entries, err := ReadListFromPkgFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			targets = append(targets, strings.Fields(entry)[0])
+		}
+	}
+	if len(targets) == 0 {
+		return nil, Error("No targets specified.")
+	}
+	return targets, nil
+}
+
+/*****************************************************************************\
+  Run the action against each of the targets, at most Parallel at a time.
+  The results are returned, and shown, in the order of the targets, followed
+  by a summary.  An error is returned if the action failed for any target.
+\*****************************************************************************/
+
+func RunAgainstTargets(targets []string, action func(target string) (string, error)) ([]TargetResult, error) {
+
+	var failed int
+	var wg sync.WaitGroup
+
+	parallel, _ := pkgOpt[int]("Parallel")
+	if parallel <= 0 {
+		parallel = 1
+	}
+	results := make([]TargetResult, len(targets))
+	slots := make(chan struct{}, parallel)
+
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			start := time.Now()
+			output, err := action(target)
+			results[i] = TargetResult{Target: target, Output: output, Err: err, Duration: time.Since(start)}
+		}(i, target)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		for _, line := range strings.Split(strings.TrimRight(result.Output, "\n"), "\n") {
+			if line != "" && !Quiet {
+				Show("%s: %s", result.Target, line)
+			}
+		}
+		if result.Err != nil {
+			failed++
+			Warn("%s: failed: %v", result.Target, result.Err)
+		} else {
+			ShowDebug("%s: succeeded in %v", result.Target, result.Duration)
+		}
+	}
+	if !Quieter {
+		Show("%d of %d targets succeeded, %d failed.", len(results)-failed, len(results), failed)
+	}
+	if failed > 0 {
+		return results, Error("Failed for %d of %d targets.", failed, len(results))
+	}
+	return results, nil
+}
@@ -0,0 +1,142 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions supporting multiple "tenants" (grids, views, etc) operated from
+  the same host.  The Tenant option selects the tenant; config file sections
+  named "tenant:<name>" apply only to that tenant, and programs should use
+  TenantDir and TenantKey to keep state, cache and audit data separate.
+  --view is an alias of --Tenant.  As tenant names become part of paths,
+  they must be valid DNS labels (see ValidateLabel).
+\*****************************************************************************/
+
+import (
+	"strings"
+)
+
+var Tenant string
+
+// The command line aliases of the Tenant option.
+var tenantAliases = []string{"View"}
+
+/*****************************************************************************\
+  Check that a tenant name is a valid DNS label, so that it is safe in paths
+  (i.e. not "../../x").  No tenant ("") is fine.
+\*****************************************************************************/
+
+func checkTenant(tenant string) error {
+	if tenant == "" {
+		return nil
+	} else if err := checkLabel(tenant, labelRegexp); err != nil {
+		return CategoryError(UsageError, "Invalid tenant \"%s\": %s", tenant, err)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Return the command line arguments with any aliases of the Tenant option
+  (--view x, --view=x) replaced by --Tenant, unless the program defines an
+  option of the alias's name.
+\*****************************************************************************/
+
+func (c *Configurator) resolveTenantAliases(args []string) []string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if _, ok := c.Config[c.optionKey("Tenant")]; !ok {
+		return args
+	}
+	resolved := append([]string(nil), args...)
+	for i, arg := range resolved {
+		if arg == "--" {
+			break
+		} else if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		name, value, has_value := strings.Cut(arg[2:], "=")
+		for _, alias := range tenantAliases {
+			if _, defined := c.Config[c.optionKey(alias)]; defined || c.optionKey(name) != c.optionKey(alias) {
+				continue
+			}
+			resolved[i] = "--Tenant"
+			if has_value {
+				resolved[i] += "=" + value
+			}
+		}
+	}
+	return resolved
+}
+
+/*****************************************************************************\
+  Find the value of an option specified on the command line, before the
+  command line has been parsed.  This is needed for options, such as Tenant,
+  that affect how the config files are read.
+\*****************************************************************************/
+
+func preScanOption(args []string, name string) string {
+	lc := strings.ToLower(name)
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		arg_lc := strings.ToLower(arg)
+		if strings.HasPrefix(arg_lc, "--"+lc+"=") {
+			return arg[len(lc)+3:]
+		} else if arg_lc == "--"+lc && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+/*****************************************************************************\
+  Check if a boolean flag is specified on the command line, before the
+  command line has been parsed.
+\*****************************************************************************/
+
+func preScanFlag(args []string, name string) bool {
+	lc := strings.ToLower(name)
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		arg_lc := strings.ToLower(arg)
+		if arg_lc == "--"+lc || arg_lc == "--"+lc+"=true" {
+			return true
+		}
+	}
+	return false
+}
+
+/*****************************************************************************\
+  Return the config file sections pertaining to the tenant, if any.
+\*****************************************************************************/
+
+func (c *Configurator) tenantSections() []string {
+	if c.Tenant == "" {
+		return nil
+	}
+	return []string{"tenant:" + c.Tenant}
+}
+
+/*****************************************************************************\
+  Return the tenant specific subdirectory of the specified directory, or the
+  directory itself if no tenant is specified.
+\*****************************************************************************/
+
+func TenantDir(dir string) string {
+	if Tenant == "" {
+		return dir
+	}
+	return dir + "/tenant-" + Tenant
+}
+
+/*****************************************************************************\
+  Return the key qualified by the tenant, if any, for use in caches and audit
+  records.
+\*****************************************************************************/
+
+func TenantKey(key string) string {
+	if Tenant == "" {
+		return key
+	}
+	return Tenant + ":" + key
+}
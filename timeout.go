@@ -0,0 +1,125 @@
+package sitepkg
+
+/*****************************************************************************\
+  The run timeout.  The Timeout option (i.e. "30s", "5m", or a number of
+  seconds; none by default) limits how long a run may take, via the context
+  returned by CommandContext, which the REST, ssh and scp helpers use, and
+  which programs pass to their own API calls:
+
+    request, err := http.NewRequestWithContext(sitepkg.CommandContext(), ...)
+
+  The timeout counts from the start of the program, or, in interactive mode,
+  from the start of each command line.
+\*****************************************************************************/
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var commandStart = time.Now()
+var commandParent = context.Background()
+var commandCtx context.Context
+var commandCancel context.CancelFunc
+var commandCtxLock sync.Mutex
+
+/*****************************************************************************\
+  Parse a duration: as for time.ParseDuration (i.e. "90s", "1h30m"), or a
+  number of seconds.
+\*****************************************************************************/
+
+func ParseDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, Error("Bad duration \"%s\" (i.e. 90s, 1h30m)", value)
+	}
+	return duration, nil
+}
+
+/*****************************************************************************\
+  Return the run timeout, from the Timeout option: 0 (none) if it is not set
+  or not defined, or an error if it is not a duration.
+\*****************************************************************************/
+
+func GetTimeout() (time.Duration, error) {
+	value, err := pkgOpt[string]("Timeout")
+	if err != nil || value == "" {
+		return 0, nil
+	}
+	timeout, err := ParseDuration(value)
+	if err != nil {
+		return 0, CategoryError(UsageError, "Option \"--Timeout\": %v", err)
+	} else if timeout < 0 {
+		return 0, CategoryError(UsageError, "Option \"--Timeout\": negative duration \"%s\"", value)
+	}
+	return timeout, nil
+}
+
+/*****************************************************************************\
+  Return the context of the run: done when the run times out (see Timeout),
+  or when the context passed to ConfigureOptionsContext is done.  It is
+  created at the first call, so call after ConfigureOptions.
+\*****************************************************************************/
+
+func CommandContext() context.Context {
+	commandCtxLock.Lock()
+	defer commandCtxLock.Unlock()
+	if commandCtx != nil {
+		return commandCtx
+	}
+	timeout, _ := GetTimeout()
+	if timeout > 0 {
+		commandCtx, commandCancel = context.WithDeadline(commandParent, commandStart.Add(timeout))
+	} else {
+		commandCtx, commandCancel = context.WithCancel(commandParent)
+	}
+	return commandCtx
+}
+
+/*****************************************************************************\
+  Return an error if the run has timed out (or been cancelled), else nil.
+\*****************************************************************************/
+
+func CommandContextError() error {
+	switch CommandContext().Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		if timeout, _ := GetTimeout(); timeout > 0 && commandParent.Err() == nil {
+			return CategoryError(TemporaryError, "Timed out after %v (see --Timeout)", timeout)
+		}
+		return CategoryError(TemporaryError, "Timed out")
+	}
+	return Error("Cancelled")
+}
+
+/*****************************************************************************\
+  Start a new command context, timing out from now: for each command line
+  in interactive mode.
+\*****************************************************************************/
+
+func resetCommandContext() {
+	commandCtxLock.Lock()
+	defer commandCtxLock.Unlock()
+	if commandCancel != nil {
+		commandCancel()
+	}
+	commandStart, commandCtx, commandCancel = time.Now(), nil, nil
+}
+
+/*****************************************************************************\
+  Set the context from which the command context is derived, starting a new
+  command context.
+\*****************************************************************************/
+
+func setCommandParent(ctx context.Context) {
+	resetCommandContext()
+	commandCtxLock.Lock()
+	defer commandCtxLock.Unlock()
+	commandParent = ctx
+}
@@ -0,0 +1,94 @@
+package sitepkg
+
+/*****************************************************************************\
+  Timestamps.  If the Timestamps option is set (or SetTimestampFormat is
+  called), the lines of Show, Warn, Log and the leveled logging functions
+  are prefixed with a timestamp, in one of the formats:
+
+    rfc3339   2024-05-01T10:00:00-04:00
+    syslog    May  1 10:00:00
+    epoch     1714572000
+    or a Go time layout, i.e. "2006-01-02 15:04:05.000"
+
+  i.e. for the logs of cron jobs and long runs.  Print and Println output,
+  the program's own output, is not prefixed, nor are JSON log lines, which
+  have their own time field (see LogFormat).
+\*****************************************************************************/
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TimestampRFC3339 = "rfc3339"
+	TimestampSyslog  = "syslog"
+	TimestampEpoch   = "epoch"
+)
+
+var timestampFormat string
+
+/*****************************************************************************\
+  Set the timestamp format: one of the above, a Go time layout, or "" for no
+  timestamps.  ConfigureOptions sets it from the Timestamps option.
+\*****************************************************************************/
+
+func SetTimestampFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "", TimestampRFC3339, TimestampSyslog, TimestampEpoch:
+		timestampFormat = strings.ToLower(format)
+	default:
+		// A layout formats as something other than itself.
+		if time.Now().Format(format) == format {
+			return Error("Bad timestamp format \"%s\": expected rfc3339, syslog, epoch or a Go time layout", format)
+		}
+		timestampFormat = format
+	}
+	return nil
+}
+
+func setTimestampsFromOptions() error {
+	format, _ := pkgOpt[string]("Timestamps")
+	if err := SetTimestampFormat(format); err != nil {
+		return WrapError(UsageError, err)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Return the current time in the timestamp format, or "" if timestamps are
+  not enabled.
+\*****************************************************************************/
+
+func Timestamp() string {
+	now := time.Now()
+	switch timestampFormat {
+	case "":
+		return ""
+	case TimestampRFC3339:
+		return now.Format(time.RFC3339)
+	case TimestampSyslog:
+		return now.Format(time.Stamp)
+	case TimestampEpoch:
+		return strconv.FormatInt(now.Unix(), 10)
+	}
+	return now.Format(timestampFormat)
+}
+
+// Return the timestamp prefix of a line, as a format string: "" or the
+// timestamp and a space, with any % escaped.
+func timestampPrefix() string {
+	if timestamp := Timestamp(); timestamp != "" {
+		return strings.ReplaceAll(timestamp, "%", "%%") + " "
+	}
+	return ""
+}
+
+// Return the line prefixed with the timestamp, if timestamps are enabled.
+func stampLine(line string) string {
+	if timestamp := Timestamp(); timestamp != "" {
+		return timestamp + " " + line
+	}
+	return line
+}
@@ -0,0 +1,38 @@
+package sitepkg
+
+/*****************************************************************************\
+  Config parse tracing (--TraceConfig): echo each candidate config file, each
+  line as it is parsed, the section it falls in, whether it was skipped, and
+  the resulting assignment, for debugging why a setting isn't taking effect.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"os"
+)
+
+/*****************************************************************************\
+  Write a trace message, if tracing.
+\*****************************************************************************/
+
+func (c *Configurator) trace(format string, a ...interface{}) {
+	if !c.Trace {
+		return
+	}
+	w := DefaultDebug
+	if w == nil {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, "TRACE: "+format+"\n", a...)
+}
+
+/*****************************************************************************\
+  Return a description of a config file section, for tracing.
+\*****************************************************************************/
+
+func traceSection(section string) string {
+	if section == "" {
+		return "(global)"
+	}
+	return "[" + section + "]"
+}
@@ -0,0 +1,178 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for copying files to and from remote hosts over ssh, verifying
+  the copies via SHA-256 checksums (which requires sha256sum on the remote
+  host).  Files are streamed through ssh (with cat) rather than copied with
+  scp, so that the remote path is quoted the same way for the copy and the
+  checksum, and so that the copy's progress is known: in Verbose mode, the
+  bytes copied are shown as they go (see NewProgress).  The SSHUser, SSHKey
+  and SSHTimeout options apply (see SetSSHOpts).
+\*****************************************************************************/
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Count the bytes copied, reporting them to the progress, if any.
+type transferCounter struct {
+	progress *Progress
+	count    int64
+}
+
+func (tc *transferCounter) Write(data []byte) (int, error) {
+	tc.count += int64(len(data))
+	if tc.progress != nil {
+		tc.progress.Set(int(tc.count))
+	}
+	return len(data), nil
+}
+
+/*****************************************************************************\
+  Copy a local file to the specified host.
+\*****************************************************************************/
+
+func PushFile(local_file string, host string, remote_file string) error {
+	return transferFile(local_file, host, remote_file, true)
+}
+
+/*****************************************************************************\
+  Copy a file from the specified host to a local file.
+\*****************************************************************************/
+
+func PullFile(host string, remote_file string, local_file string) error {
+	return transferFile(local_file, host, remote_file, false)
+}
+
+func transferFile(local_file string, host string, remote_file string, push bool) error {
+
+	var from, to string
+
+	if err := checkSSHHost(host); err != nil {
+		return err
+	} else if remote_file == "" {
+		return Error("No remote file specified for copying to or from %s", host)
+	}
+	remote := host + ":" + remote_file
+	if push {
+		from, to = local_file, remote
+	} else {
+		from, to = remote, local_file
+	}
+
+	Infof("Copying %s to %s...", from, to)
+	start := time.Now()
+	var err error
+	if push {
+		err = pushFile(local_file, host, remote_file)
+	} else {
+		err = pullFile(host, remote_file, local_file)
+	}
+	if err != nil {
+		return Error("Failure copying %s to %s: %v", from, to, err)
+	}
+
+	local_sum, size, err := fileChecksum(local_file)
+	if err != nil {
+		return err
+	}
+	result, err := RunSSH(host, "sha256sum -- "+shellQuote(remote_file))
+	if err != nil {
+		return Error("Failure verifying copy of %s to %s: %v", from, to, err)
+	}
+	if fields := strings.Fields(result.Stdout); len(fields) == 0 || fields[0] != local_sum {
+		return Error("Checksum mismatch copying %s to %s", from, to)
+	}
+	Infof("Copied %d bytes from %s to %s in %.1fs (checksum verified).", size, from, to, time.Since(start).Seconds())
+	return nil
+}
+
+/*****************************************************************************\
+  Stream a local file to the remote file, or the remote file to a local file
+  (removed if the copy fails), showing the progress in Verbose mode: as a
+  percentage for a push, and in bytes for a pull, as the remote file's size
+  is not known.
+\*****************************************************************************/
+
+func pushFile(local_file string, host string, remote_file string) error {
+	file, err := os.Open(local_file)
+	if err != nil {
+		return Error("Error opening file \"%s\": %v", local_file, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return Error("Error reading file \"%s\": %v", local_file, err)
+	}
+	counter := &transferCounter{}
+	if Verbose {
+		counter.progress = NewProgress(int(info.Size()))
+		counter.progress.SetLabel("Copying " + local_file)
+		defer counter.progress.Done()
+	}
+	result, err := runSSH(host, "cat > "+shellQuote(remote_file), io.TeeReader(file, counter), nil)
+	return transferError(result, err)
+}
+
+func pullFile(host string, remote_file string, local_file string) error {
+	file, err := os.Create(local_file)
+	if err != nil {
+		return Error("Error creating file \"%s\": %v", local_file, err)
+	}
+	counter := &transferCounter{}
+	if Verbose {
+		counter.progress = NewSpinner()
+		counter.progress.SetLabel("Copying " + remote_file)
+	}
+	result, err := runSSH(host, "cat -- "+shellQuote(remote_file), nil, io.MultiWriter(file, counter))
+	if counter.progress != nil {
+		counter.progress.Done()
+	}
+	if close_err := file.Close(); err == nil && close_err != nil {
+		err = Error("Error writing file \"%s\": %v", local_file, close_err)
+	}
+	if err = transferError(result, err); err != nil {
+		os.Remove(local_file)
+	}
+	return err
+}
+
+// Return the error of a streaming ssh command, with its stderr, if any and
+// not already included (as it is for ssh failures).
+func transferError(result SSHResult, err error) error {
+	if stderr := strings.TrimSpace(result.Stderr); err != nil && stderr != "" && !strings.Contains(err.Error(), stderr) {
+		return Error("%v: %s", err, stderr)
+	}
+	return err
+}
+
+/*****************************************************************************\
+  Return the SHA-256 checksum (in hex) and size of a local file.
+\*****************************************************************************/
+
+func fileChecksum(filename string) (string, int64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", 0, Error("Error opening file \"%s\": %v", filename, err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, Error("Error reading file \"%s\": %v", filename, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+/*****************************************************************************\
+  Quote a string for use as a single word in a (remote) shell command.
+\*****************************************************************************/
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
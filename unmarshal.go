@@ -0,0 +1,160 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for defining options from, and copying option values into, the
+  fields of a struct, per the fields' tags:
+
+    type Settings struct {
+        Server  string `opt:"Server" short:"s" desc:"Specify the API server"`
+        Port    int    `opt:"Port" desc:"Specify the API port"`
+        Force   bool   `opt:"Force" file:"false" desc:"Force the update"`
+    }
+
+  The "opt" tag names the option; "short", "desc" and "file" (whether the
+  option may be set in a config file; default true) are optional.  Fields
+  must be of type string, bool, int, uint or []string, or be structs, whose fields
+  define namespaced options (dotted names), i.e.:
+
+    type Settings struct {
+        DB struct {
+            Host string `opt:"Host" desc:"Specify the database host"`
+        } `opt:"db"`
+    }
+
+  defines the option "db.Host".
+\*****************************************************************************/
+
+import (
+	"reflect"
+)
+
+/*****************************************************************************\
+  Define an option for each tagged field of the struct pointed to by ptr,
+  with the field's current value as the option's default.
+\*****************************************************************************/
+
+func RegisterStruct(ptr interface{}) error {
+	return walkStruct(ptr, func(name string, field reflect.Value, tag reflect.StructTag) error {
+		shortopt := tag.Get("short")
+		desc := tag.Get("desc")
+		file := tag.Get("file") != "false"
+		switch field.Kind() {
+		case reflect.String:
+			SetStringOpt(name, shortopt, file, field.String(), desc)
+		case reflect.Bool:
+			SetBoolOpt(name, shortopt, file, field.Bool(), desc)
+		case reflect.Int:
+			SetIntOpt(name, shortopt, file, int(field.Int()), desc)
+		case reflect.Uint:
+			SetUintOpt(name, shortopt, file, uint(field.Uint()), desc)
+		case reflect.Slice:
+			list, ok := field.Interface().([]string)
+			if !ok {
+				return Error("RegisterStruct: unsupported type %s for option \"%s\".", field.Type(), name)
+			}
+			SetListOpt(name, shortopt, file, list, desc)
+		default:
+			return Error("RegisterStruct: unsupported type %s for option \"%s\".", field.Type(), name)
+		}
+		return nil
+	})
+}
+
+/*****************************************************************************\
+  Copy the option values into the tagged fields of the struct pointed to by
+  ptr.  Call after ConfigureOptions.
+\*****************************************************************************/
+
+func ConfigUnmarshal(ptr interface{}) error {
+	return UnmarshalSubtree("", ptr)
+}
+
+/*****************************************************************************\
+  Copy the values of the options under the prefix (see GetSubtree) into the
+  tagged fields of the struct pointed to by ptr, whose "opt" tags name the
+  options relative to the prefix: i.e. with the prefix "db", the field tagged
+  `opt:"Host"` gets the value of the option "db.Host".
+\*****************************************************************************/
+
+func UnmarshalSubtree(prefix string, ptr interface{}) error {
+	if prefix != "" {
+		prefix += "."
+	}
+	return walkStructPrefix(ptr, prefix, func(name string, field reflect.Value, tag reflect.StructTag) error {
+		switch field.Kind() {
+		case reflect.String:
+			value, err := GetStringOpt(name)
+			if err != nil {
+				return err
+			}
+			field.SetString(value)
+		case reflect.Bool:
+			value, err := GetBoolOpt(name)
+			if err != nil {
+				return err
+			}
+			field.SetBool(value)
+		case reflect.Int:
+			value, err := GetIntOpt(name)
+			if err != nil {
+				return err
+			}
+			field.SetInt(int64(value))
+		case reflect.Uint:
+			value, err := GetUintOpt(name)
+			if err != nil {
+				return err
+			}
+			field.SetUint(uint64(value))
+		case reflect.Slice:
+			value, err := GetListOpt(name)
+			if err != nil {
+				return err
+			} else if field.Type() != reflect.TypeOf(value) {
+				return Error("ConfigUnmarshal: unsupported type %s for option \"%s\".", field.Type(), name)
+			}
+			field.Set(reflect.ValueOf(value))
+		default:
+			return Error("ConfigUnmarshal: unsupported type %s for option \"%s\".", field.Type(), name)
+		}
+		return nil
+	})
+}
+
+/*****************************************************************************\
+  Call fn for each field of the struct pointed to by ptr with an "opt" tag.
+\*****************************************************************************/
+
+func walkStruct(ptr interface{}, fn func(string, reflect.Value, reflect.StructTag) error) error {
+	return walkStructPrefix(ptr, "", fn)
+}
+
+func walkStructPrefix(ptr interface{}, prefix string, fn func(string, reflect.Value, reflect.StructTag) error) error {
+	value := reflect.ValueOf(ptr)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return Error("Bad call: expected a pointer to a struct, not %T.", ptr)
+	}
+	return walkValue(value.Elem(), prefix, fn)
+}
+
+func walkValue(value reflect.Value, prefix string, fn func(string, reflect.Value, reflect.StructTag) error) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := field.Tag.Get("opt")
+		if name == "" || name == "-" {
+			continue
+		} else if !field.IsExported() {
+			return Error("Bad call: field %s for option \"%s\" is not exported.", field.Name, name)
+		}
+		if field.Type.Kind() == reflect.Struct {
+			if err := walkValue(value.Field(i), prefix+name+".", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(prefix+name, value.Field(i), field.Tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
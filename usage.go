@@ -0,0 +1,134 @@
+package sitepkg
+
+/*****************************************************************************\
+  Option usage analytics.  The options read (via Get*Opt, GetOpt or Lookup)
+  are tracked, and in Debug mode, at Exit, those registered options never
+  read, and any lookups of options never registered, are reported: to help
+  find dead options and misspelled lookups.  And in Verbose mode, options set
+  in config files but never read are reported, as these usually indicate a
+  stale or misspelled setting on the host.  The package's own options (see
+  definePkgOpts), which it reads only as needed, are not reported.
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Set once ConfigureOptions returns to the program; usage is not reported
+// for runs that exit earlier (i.e. --Help).
+var optionsConfigured bool
+
+type optionUsage struct {
+	read sync.Map
+}
+
+/*****************************************************************************\
+  Note that an option was read.
+\*****************************************************************************/
+
+func (c *Configurator) noteRead(name string) {
+	if _, ok := c.usage.read.Load(name); !ok {
+		c.usage.read.Store(name, true)
+	}
+}
+
+/*****************************************************************************\
+  Define options for the package's own use (see PackageInit and the Set*Opts
+  functions).  The package reads them as it needs them, and the program need
+  not read them, so they are not reported as never read.
+\*****************************************************************************/
+
+func (c *Configurator) definePkgOpts(define func()) {
+	defined := make(map[string]bool)
+	for _, name := range c.OptionNames() {
+		defined[name] = true
+	}
+	define()
+	c.writeLock()
+	defer c.lock.Unlock()
+	for name, option := range c.Config {
+		if !defined[name] {
+			option.pkg = true
+		}
+	}
+}
+
+/*****************************************************************************\
+  Return the registered options never read (other than the package's own),
+  and the options read but never registered, each sorted.
+\*****************************************************************************/
+
+func (c *Configurator) OptionUsage() (unread []string, unregistered []string) {
+	read := make(map[string]bool)
+	c.usage.read.Range(func(name interface{}, _ interface{}) bool {
+		read[c.optionKey(name.(string))] = true
+		return true
+	})
+	c.lock.RLock()
+	for name, option := range c.Config {
+		if option.pkg {
+			read[name] = true
+		}
+	}
+	c.lock.RUnlock()
+	for _, name := range c.OptionNames() {
+		if !read[name] {
+			unread = append(unread, name)
+		}
+		delete(read, name)
+	}
+	for name := range read {
+		unregistered = append(unregistered, name)
+	}
+	sort.Strings(unregistered)
+	return unread, unregistered
+}
+
+/*****************************************************************************\
+  Return the config file settings, as "file:line: option", of the options
+  never read.
+\*****************************************************************************/
+
+func (c *Configurator) UnreadFileSettings() (settings []string) {
+	unread, _ := c.OptionUsage()
+	for _, name := range unread {
+		history, _ := c.OptionHistory(name)
+		for _, assignment := range history {
+			if strings.HasPrefix(assignment.Source, "file:") {
+				settings = append(settings, fmt.Sprintf("%s:%d: %s",
+					strings.TrimPrefix(assignment.Source, "file:"), assignment.Line, name))
+			}
+		}
+	}
+	sort.Strings(settings)
+	return settings
+}
+
+/*****************************************************************************\
+  Show the option usage report (in Debug mode), and warn of unread config
+  file settings (in Verbose mode).  Called by Exit.
+\*****************************************************************************/
+
+func ShowOptionUsage() {
+	if !optionsConfigured {
+		return
+	}
+	if Verbose {
+		for _, setting := range std.UnreadFileSettings() {
+			Warn("%s: setting never used", setting)
+		}
+	}
+	if !Debug {
+		return
+	}
+	unread, unregistered := std.OptionUsage()
+	if len(unread) > 0 {
+		ShowDebug("Options never read: %s", strings.Join(unread, ", "))
+	}
+	if len(unregistered) > 0 {
+		ShowDebug("Options read but not registered: %s", strings.Join(unregistered, ", "))
+	}
+}
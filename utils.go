@@ -1,7 +1,6 @@
 package sitepkg
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"os"
@@ -11,16 +10,34 @@ import (
 )
 
 /*****************************************************************************\
-  Exit the program.  Improve upon later.
+  Exit the program.  Improve upon later.  In Debug mode, first show the
+  option usage report (see ShowOptionUsage).  The errors are shown whatever
+  the log level, then the cleanups are run (see RegisterCleanup and
+  OnExit).
 \*****************************************************************************/
 
 func Exit(code int, errs ...error) {
+	ShowOptionUsage()
 	for _, err := range errs {
-		Warn("%v", err)
+		if logJSON {
+			writeJSONLog(classWriter(ClassError), LevelError, err.Error(), nil)
+		} else {
+			Fwarn(classWriter(ClassError), "%v", err)
+		}
 	}
+	exitStatus = code
+	RunCleanups()
 	os.Exit(code)
 }
 
+/*****************************************************************************\
+  Exit the program with status 1, after showing the specified error message.
+\*****************************************************************************/
+
+func Fatal(format string, a ...interface{}) {
+	Exit(1, Error(format, a...))
+}
+
 /*****************************************************************************\
   Convenience wrapper for errors.New().
 \*****************************************************************************/
@@ -50,7 +67,7 @@ func FileExists(filename string) (exists bool, err error) {
   priority to lowest.  Return only the first one found.
 \*****************************************************************************/
 
-func FindPackageFile(filename string) (pathname string, err error) {
+func (c *Configurator) FindPackageFile(filename string) (pathname string, err error) {
 
 	if filename == "" {
 		return "", Error("Bad call: filename not defined.")
@@ -64,8 +81,8 @@ func FindPackageFile(filename string) (pathname string, err error) {
 		return filename, nil
 	}
 
-	for i := len(ConfigDirs) - 1; i >= 0; i-- {
-		pathname := ConfigDirs[i] + "/" + filename
+	for i := len(c.ConfigDirs) - 1; i >= 0; i-- {
+		pathname := c.ConfigDirs[i] + "/" + filename
 		if exists, err := FileExists(pathname); err != nil {
 			return "", err
 		} else if exists {
@@ -96,34 +113,12 @@ func ReadListFromPkgFile(filename string) (list []string, err error) {
 
 func ReadListFromFile(filename string) (list []string, err error) {
 
-	if filename == "" {
-		return list, Error("Bad call: filename not defined.")
-	} else if exists, err := FileExists(filename); err != nil {
-		return nil, err
-	} else if !exists {
-		return nil, Error("No such file \"%s\".", filename)
-	}
-
-	file, err := os.Open(filename)
+	entries, err := ReadListEntriesFromFile(filename)
 	if err != nil {
-		return nil, Error("Error opening file \"%s\": %v", filename, err)
+		return nil, err
 	}
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		// Remove leading spaces and tabs:
-		line := strings.TrimLeft(scanner.Text(), " \t")
-		// Skip comment (#) lines:
-		if strings.HasPrefix(line, "#") {
-			continue
-		} else if line == "" {
-			continue
-		}
-		// Define a trailing comment: one or more spaces/tabs followed by '#.*':
-		comment := regexp.MustCompile("[ \t]+#.*$")
-		// Split line into a slice of at most 2 strings, spitting by our regexp:
-		slice := comment.Split(line, 2)
-		// Append our trimmed line to the list:
-		list = append(list, strings.TrimRight(slice[0], " \t"))
+	for _, entry := range entries {
+		list = append(list, entry.Text)
 	}
 	return list, nil
 }
@@ -141,12 +136,18 @@ func GetSecret(account string) (string, error) {
 	}
 	var secrets_dir, filename string
 
-	if secrets_dir, _ = GetStringOpt("SecretsDir"); secrets_dir == "" {
+	if secrets_dir, _ = pkgOpt[string]("SecretsDir"); secrets_dir == "" {
 		if filename, _ = FindPackageFile("private/" + account); filename == "" {
-			return "", Error("Credentials file \"%s\" not found.", account)
+			if !IsFirstRun() {
+				return "", Error("Credentials file \"%s\" not found.", account)
+			} else if !firstRunSetup() {
+				return "", Error("Credentials file \"%s\" not found.  %s", account, GettingStarted())
+			} else if filename, _ = FindPackageFile("private/" + account); filename == "" {
+				return "", Error("Credentials file \"%s\" not found.", account)
+			}
 		}
 	} else {
-		filename = secrets_dir + "/" + account
+		filename = RootPath(secrets_dir) + "/" + account
 	}
 
 	list, err := ReadListFromFile(filename)
@@ -155,6 +156,7 @@ func GetSecret(account string) (string, error) {
 	} else if list == nil {
 		return "", Error("Failure reading secret from secrets file \"%s\".", filename)
 	}
+	CheckFileDrift(filename)
 	return list[0], nil
 }
 
@@ -209,18 +211,32 @@ func CheckFlagValue(user_value string, resource_value string, not_specified bool
   GetCommandPaths returns the list of "paths" for the invoked
   command/sub-commands. For instance, if the user invoked "ibapi host
   add host.com 10.10.10.10", and "add" is the final sub-command, the
-  following list is returned: ["ibapi", "host", "host:add" ].
+  following list is returned: ["ibapi", "host", "host:add" ].  The
+  subcommands are those set by SetCommandPath, or of the command found by
+  ConfigureOptions if any are registered (see AddCommand), or else the words
+  of os.Args[0], which the caller may have rewritten to "ibapi host add".
 \*****************************************************************************/
 
-func GetCommandPaths() []string {
+func (c *Configurator) GetCommandPaths() []string {
 
 	var paths []string
 	var sep, command string
 
+	words := strings.Split(os.Args[0], " ")[1:]
+	if len(words) > 0 && len(c.aliases) > 0 {
+		words = strings.Split(c.resolveAlias(strings.Join(words, ":")), ":")
+	}
+	if c.pathSet {
+		words = nil
+		if c.invoked != "" {
+			words = strings.Split(c.invoked, ":")
+		}
+	}
+
 	// Set up the list of paths to search.
-	paths = append(paths, ProgramName)
-	for _, c := range strings.Split(os.Args[0], " ")[1:] {
-		command += sep + c
+	paths = append(paths, c.ProgramName)
+	for _, word := range words {
+		command += sep + word
 		sep = ":"
 		paths = append(paths, command)
 	}
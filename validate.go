@@ -0,0 +1,109 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for validating names (host names, zone names, labels) against
+  the DNS rules and our site naming conventions.  Programs defining the
+  validation options (see SetValidationOpts) may configure the site
+  conventions via HostnameRegex, ZoneNameRegex and LabelRegex; when set, a
+  name must match the regex in addition to the DNS rules.
+\*****************************************************************************/
+
+import (
+	"regexp"
+	"strings"
+)
+
+var labelRegexp = regexp.MustCompile("^[a-z0-9]([a-z0-9-]*[a-z0-9])?$")
+var zoneLabelRegexp = regexp.MustCompile("^[a-z0-9_]([a-z0-9_/-]*[a-z0-9_])?$")
+
+/*****************************************************************************\
+  Define the validation options: the site naming convention regexes.
+\*****************************************************************************/
+
+func SetValidationOpts() {
+	std.definePkgOpts(func() {
+		SetStringOpt("HostnameRegex", "", true, "", "Specify a regex that host names must match")
+		SetStringOpt("ZoneNameRegex", "", true, "", "Specify a regex that zone names must match")
+		SetStringOpt("LabelRegex", "", true, "", "Specify a regex that DNS labels must match")
+	})
+}
+
+/*****************************************************************************\
+  Validate a single DNS label (i.e.: the "www" of www.example.com).
+\*****************************************************************************/
+
+func ValidateLabel(label string) error {
+	if err := checkLabel(label, labelRegexp); err != nil {
+		return Error("Invalid label \"%s\": %s", label, err)
+	}
+	return checkSiteRegex("LabelRegex", "label", label)
+}
+
+/*****************************************************************************\
+  Validate a fully qualified host name.  A trailing dot is allowed.
+\*****************************************************************************/
+
+func ValidateHostname(name string) error {
+	if err := checkName(name, labelRegexp); err != nil {
+		return Error("Invalid hostname \"%s\": %s", name, err)
+	}
+	return checkSiteRegex("HostnameRegex", "hostname", name)
+}
+
+/*****************************************************************************\
+  Validate a zone name.  Zone names are more lenient than host names, allowing
+  underscores (_msdcs.example.com) and, for classless reverse delegations,
+  slashes (0/25.10.10.10.in-addr.arpa).  A trailing dot is allowed.
+\*****************************************************************************/
+
+func ValidateZoneName(name string) error {
+	if err := checkName(name, zoneLabelRegexp); err != nil {
+		return Error("Invalid zone name \"%s\": %s", name, err)
+	}
+	return checkSiteRegex("ZoneNameRegex", "zone name", name)
+}
+
+func checkName(name string, label_regexp *regexp.Regexp) error {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" {
+		return Error("empty name")
+	} else if len(name) > 253 {
+		return Error("longer than 253 characters")
+	}
+	for _, label := range strings.Split(name, ".") {
+		if err := checkLabel(label, label_regexp); err != nil {
+			return Error("label \"%s\": %s", label, err)
+		}
+	}
+	return nil
+}
+
+func checkLabel(label string, label_regexp *regexp.Regexp) error {
+	if label == "" {
+		return Error("empty label")
+	} else if len(label) > 63 {
+		return Error("longer than 63 characters")
+	} else if !label_regexp.MatchString(strings.ToLower(label)) {
+		return Error("illegal characters, or leading or trailing hyphen")
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Check the name against the site regex configured via the specified option,
+  if the option exists and is set.
+\*****************************************************************************/
+
+func checkSiteRegex(option_name string, kind string, name string) error {
+	site_regex, _ := pkgOpt[string](option_name)
+	if site_regex == "" {
+		return nil
+	}
+	match, err := regexp.MatchString(site_regex, name)
+	if err != nil {
+		return Error("Bad %s regex \"%s\": %v", option_name, site_regex, err)
+	} else if !match {
+		return Error("Invalid %s \"%s\": does not match site naming convention \"%s\"", kind, name, site_regex)
+	}
+	return nil
+}
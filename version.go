@@ -0,0 +1,91 @@
+package sitepkg
+
+/*****************************************************************************\
+  Dependency versions for ShowVersion, to speed up support triage: the Go
+  version and module versions the program was built with (from its build
+  info), and the paths and versions of the external commands it relies on,
+  as declared with SetVersionCommand.
+\*****************************************************************************/
+
+import (
+	"context"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+type Dependency struct {
+	Name    string
+	Version string
+	Path    string `json:",omitempty"`
+}
+
+type versionCommand struct {
+	command string
+	args    []string
+}
+
+var versionCommands []versionCommand
+
+/*****************************************************************************\
+  Declare an external command the program relies on (i.e. "ssh", "-V"), to be
+  listed by ShowVersion: its path (found via ExecPath), and the first line of
+  the output of running it with the specified arguments, if any.
+\*****************************************************************************/
+
+func SetVersionCommand(command string, version_args ...string) {
+	versionCommands = append(versionCommands, versionCommand{command: command, args: version_args})
+}
+
+/*****************************************************************************\
+  Return the Go version and modules the program was built with.
+\*****************************************************************************/
+
+func ModuleDependencies() (deps []Dependency) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	deps = append(deps, Dependency{Name: "go", Version: info.GoVersion})
+	for _, module := range info.Deps {
+		if module.Replace != nil {
+			module = module.Replace
+		}
+		deps = append(deps, Dependency{Name: module.Path, Version: module.Version})
+	}
+	return deps
+}
+
+/*****************************************************************************\
+  Return the external commands declared, with their paths and versions.
+  Commands not found are listed with the version "not found".
+\*****************************************************************************/
+
+func CommandDependencies() (deps []Dependency) {
+	for _, entry := range versionCommands {
+		dep := Dependency{Name: entry.command}
+		if path, err := ExecPath(entry.command); err != nil {
+			dep.Version = "not found"
+		} else {
+			dep.Path = path
+			dep.Version = commandVersion(path, entry.args)
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+func commandVersion(path string, args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(CommandContext(), 5*time.Second)
+	defer cancel()
+	// Some commands (i.e. ssh -V) report their version on stderr.
+	output, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil && len(output) == 0 {
+		return "unknown"
+	}
+	return strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+}
@@ -0,0 +1,112 @@
+package sitepkg
+
+/*****************************************************************************\
+  Functions for interoperating with viper (github.com/spf13/viper), for tools
+  migrating from viper onto the site layout.  To avoid depending on viper,
+  these accept any value with the needed methods, which *viper.Viper has.
+  Like sitepkg, viper uses case insensitive (lowercased) keys.
+\*****************************************************************************/
+
+import (
+	"sort"
+	"strings"
+)
+
+type ViperSetter interface {
+	Set(key string, value interface{})
+	SetDefault(key string, value interface{})
+}
+
+type ViperGetter interface {
+	AllKeys() []string
+	Get(key string) interface{}
+	IsSet(key string) bool
+}
+
+/*****************************************************************************\
+  Export the options to viper: each option's default becomes the viper
+  default, and the value of any option set via a config file or the command
+  line is set explicitly.  Call after ConfigureOptions, so existing viper
+  based code sees the values from sitepkg's section aware config files.
+\*****************************************************************************/
+
+func ToViper(v ViperSetter) {
+	std.lock.RLock()
+	defer std.lock.RUnlock()
+	for name, option := range std.Config {
+		v.SetDefault(name, option.Default)
+		if !isDefaultSource(option.Source) {
+			v.Set(name, optionValue(option))
+		}
+	}
+}
+
+/*****************************************************************************\
+  Import settings from viper: each key set in viper whose option exists is
+  set to viper's value, with Source "viper".  If register is set, options are
+  defined for keys with no option, typed per viper's value (string, bool,
+  int or uint), so that sitepkg's config files, help, and ShowConfig cover
+  settings still defined only in viper.
+\*****************************************************************************/
+
+func FromViper(v ViperGetter, register bool) error {
+
+	keys := v.AllKeys()
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := v.Get(key)
+		name := std.viperOption(key)
+		if name == "" {
+			if !register {
+				continue
+			}
+			name = key
+			switch typed := value.(type) {
+			case string:
+				SetStringOpt(name, "", true, typed, "")
+			case bool:
+				SetBoolOpt(name, "", true, typed, "")
+			case int:
+				SetIntOpt(name, "", true, typed, "")
+			case uint:
+				SetUintOpt(name, "", true, typed, "")
+			case []string:
+				SetListOpt(name, "", true, typed, "")
+			default:
+				return Error("FromViper: unsupported type %T for key \"%s\".", value, key)
+			}
+		}
+		if v.IsSet(key) {
+			if err := SetOptValue(name, formatValue(value), "viper"); err != nil {
+				return Error("FromViper: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Return the name of the option of a viper key, or "" if none.  As viper's
+  keys are lowercased, in case sensitive mode (see SetCaseSensitive) the
+  option is that whose name lowercased is the key, if there is only one.
+\*****************************************************************************/
+
+func (c *Configurator) viperOption(key string) string {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if option, ok := c.Config[c.optionKey(key)]; ok {
+		return option.Name
+	} else if !c.CaseSensitive {
+		return ""
+	}
+	var found string
+	for _, option := range c.Config {
+		if strings.ToLower(option.Name) == key {
+			if found != "" {
+				return ""
+			}
+			found = option.Name
+		}
+	}
+	return found
+}
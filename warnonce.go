@@ -0,0 +1,84 @@
+package sitepkg
+
+/*****************************************************************************\
+  Warning deduplication and rate limiting, i.e. for retry loops, which would
+  otherwise flood the output (and the cron mail) with identical warnings:
+
+    WarnOnce    shows a warning the first time only
+    WarnEvery   shows a warning at most once per interval
+
+  Repeats of a message not shown are counted, and summarized at exit:
+
+    prog: Warning: "Failure contacting ns1: timeout" suppressed 4213 times
+\*****************************************************************************/
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type warningCount struct {
+	message    string
+	shown      time.Time
+	suppressed int
+}
+
+var warningCounts = make(map[string]*warningCount)
+var warningOrder []*warningCount
+var warningCountsLock sync.Mutex
+var warningSummaryOnce sync.Once
+
+func WarnOnce(format string, a ...interface{}) {
+	warnLimited(-1, fmt.Sprintf(format, a...))
+}
+
+func WarnEvery(interval time.Duration, format string, a ...interface{}) {
+	warnLimited(interval, fmt.Sprintf(format, a...))
+}
+
+// Show the warning unless it was shown within the interval, or at all if
+// interval is negative; otherwise count it.
+func warnLimited(interval time.Duration, message string) {
+	now := time.Now()
+	warningCountsLock.Lock()
+	count, ok := warningCounts[message]
+	if !ok {
+		count = &warningCount{message: message, shown: now}
+		warningCounts[message] = count
+		warningOrder = append(warningOrder, count)
+	} else if interval >= 0 && now.Sub(count.shown) >= interval {
+		count.shown = now
+		ok = false
+	} else {
+		count.suppressed++
+	}
+	warningCountsLock.Unlock()
+
+	if !ok {
+		logFields(LevelWarn, nil, message)
+	} else {
+		warningSummaryOnce.Do(func() { RegisterCleanup(ShowSuppressedWarnings) })
+	}
+}
+
+/*****************************************************************************\
+  Show the number of times each warning was suppressed, in the order first
+  shown, and reset the counts.  Run at exit.
+\*****************************************************************************/
+
+func ShowSuppressedWarnings() {
+	warningCountsLock.Lock()
+	var summary []string
+	for _, count := range warningOrder {
+		if count.suppressed > 0 {
+			summary = append(summary, fmt.Sprintf("%q suppressed %d times", count.message, count.suppressed))
+			count.suppressed = 0
+		}
+	}
+	warningCountsLock.Unlock()
+
+	for _, line := range summary {
+		logFields(LevelWarn, nil, line)
+	}
+}
@@ -0,0 +1,295 @@
+package sitepkg
+
+/*****************************************************************************\
+  An interactive configuration "wizard", which walks the user through the
+  program's file settable options (and any secrets the program uses), and
+  saves the answers to the user's own config file and secrets directory.
+\*****************************************************************************/
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+type secretAccount struct {
+	Account string
+	Desc    string
+}
+
+var secretAccounts []secretAccount
+var stdinReader *bufio.Reader
+
+/*****************************************************************************\
+  Assign an option to a group, so that related options are presented together
+  by the configuration wizard.  Options not assigned to a group are in the
+  "General" group.
+\*****************************************************************************/
+
+func SetOptGroup(name string, group string) error {
+	std.lock.Lock()
+	defer std.lock.Unlock()
+	lc := std.optionKey(name)
+	option, ok := Config[lc]
+	if !ok {
+		return Error("%s \"%s\"!", ConfErrNoSuchOption, name)
+	}
+	option.Group = group
+	return nil
+}
+
+/*****************************************************************************\
+  Declare a secret (see GetSecret) used by the program, so that the
+  configuration wizard can prompt for it.
+\*****************************************************************************/
+
+func SetSecretAccount(account string, desc string) {
+	secretAccounts = append(secretAccounts, secretAccount{Account: account, Desc: desc})
+}
+
+/*****************************************************************************\
+  Check if stdin is a terminal.
+\*****************************************************************************/
+
+func IsInteractive() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+/*****************************************************************************\
+  Prompt the user, and return the (trimmed) line entered.
+\*****************************************************************************/
+
+func Prompt(format string, a ...interface{}) (string, error) {
+	if stdinReader == nil {
+		stdinReader = bufio.NewReader(os.Stdin)
+	}
+	Print(format, a...)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", Error("Failure reading input: %v", err)
+	}
+	return strings.TrimSpace(line), nil
+}
+
+/*****************************************************************************\
+  Prompt the user for a secret, without echoing what is typed.
+\*****************************************************************************/
+
+func PromptSecret(format string, a ...interface{}) (string, error) {
+	if err := stty("-echo"); err != nil {
+		return "", err
+	}
+	secret, err := Prompt(format, a...)
+	stty("echo")
+	Println("")
+	return secret, err
+}
+
+func stty(settings ...string) error {
+	stty, err := ExecPath("stty")
+	if err != nil {
+		return Error("Command stty not found.")
+	}
+	command := exec.Command(stty, settings...)
+	command.Stdin = os.Stdin
+	if err = command.Run(); err != nil {
+		return Error("Failure running \"stty %s\": %v", strings.Join(settings, " "), err)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Return the user's own config directory (i.e.: ~/.ibapi).
+\*****************************************************************************/
+
+func UserConfigDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", Error("Failure getting home dir: %v", err)
+	}
+	return home + "/." + PkgName, nil
+}
+
+/*****************************************************************************\
+  Return the user's state directory (i.e.: ~/.ibapi/state), tenant specific,
+  for files the programs keep between runs.
+\*****************************************************************************/
+
+func StateDir() (string, error) {
+	dir, err := UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return TenantDir(dir + "/state"), nil
+}
+
+/*****************************************************************************\
+  Run the configuration wizard.  Each file settable option is presented by
+  group, showing its current value; an empty answer keeps the current value.
+  Answers are validated, and saved to the user's config file for the program.
+  Then prompt for any declared secrets, saving them to the SecretsDir, if
+  set (as GetSecret reads them from it), or else to the user's "private"
+  directory.
+\*****************************************************************************/
+
+func ConfigureWizard() error {
+
+	if !IsInteractive() {
+		return Error("The configuration wizard requires an interactive terminal.")
+	}
+	user_dir, err := UserConfigDir()
+	if err != nil {
+		return err
+	}
+	config_file := user_dir + "/" + ProgramName + ".conf"
+
+	groups := make(map[string][]string)
+	for name, option := range Config {
+		if option.Sources&SourceFile == 0 || option.Final {
+			continue
+		}
+		group := option.Group
+		if group == "" {
+			group = "General"
+		}
+		groups[group] = append(groups[group], name)
+	}
+	group_names := make([]string, 0, len(groups))
+	for group := range groups {
+		group_names = append(group_names, group)
+	}
+	sort.Strings(group_names)
+
+	Println("Configuring %s; press return to keep the current value.", ProgramName)
+	values := make(map[string]string)
+
+	for _, group := range group_names {
+		Println("\n%s settings:", group)
+		sort.Strings(groups[group])
+		for _, name := range groups[group] {
+			option := Config[name]
+			Println("\n  %s", option.Desc)
+			for {
+				answer, err := Prompt("  %s [%v]: ", option.Name, optionValue(option))
+				if err != nil {
+					return err
+				} else if answer == "" {
+					break
+				} else if err = checkWizardValue(name, answer); err != nil {
+					Warn("%v", err)
+					continue
+				} else if err = std.setFileOption(name, answer, config_file, 0, false, false); err != nil {
+					Warn("%v", err)
+					continue
+				}
+				values[name] = answer
+				break
+			}
+		}
+	}
+
+	if len(values) > 0 {
+		if err = UpdateConfigFile(config_file, "", values); err != nil {
+			return err
+		}
+		Show("Saved %d setting(s) to %s", len(values), config_file)
+	}
+
+	secrets_dir := user_dir + "/private"
+	if dir, _ := pkgOpt[string]("SecretsDir"); dir != "" {
+		secrets_dir = RootPath(dir)
+	}
+	for _, secret := range secretAccounts {
+		Println("\n  %s", secret.Desc)
+		answer, err := PromptSecret("  Secret for %s (return to skip): ", secret.Account)
+		if err != nil {
+			return err
+		} else if answer == "" {
+			continue
+		}
+		secrets_file := secrets_dir + "/" + secret.Account
+		if err = os.MkdirAll(secrets_dir, 0700); err != nil {
+			return Error("Error creating directory \"%s\": %v", secrets_dir, err)
+		}
+		if err = writeFileAtomic(secrets_file, []byte(answer+"\n"), 0600); err != nil {
+			return err
+		}
+		Show("Saved secret %s to %s", secret.Account, secrets_file)
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  If the option has a list of valid values (see SetOptCompletionValues),
+  check that the value is one of them.
+\*****************************************************************************/
+
+func checkWizardValue(name string, value string) error {
+	completion, ok := completions[name]
+	if !ok || len(completion.Values) == 0 {
+		return nil
+	}
+	if in_list, _ := InList(completion.Values, value); !in_list {
+		return Error("Bad value \"%s\" for %s; must be one of: %s", value, name,
+			strings.Join(completion.Values, ", "))
+	}
+	return nil
+}
+
+/*****************************************************************************\
+  Check if this looks like the first run of the program for the invoking
+  user: no config file was read, and the user has no config directory (and
+  so no config files or secrets) of their own.
+\*****************************************************************************/
+
+func IsFirstRun() bool {
+	if len(ConfigFilesRead) > 0 {
+		return false
+	}
+	user_dir, err := UserConfigDir()
+	if err != nil {
+		return false
+	}
+	for _, dir := range []string{user_dir, user_dir + "-" + PkgVersion} {
+		if exists, _ := FileExists(dir); exists {
+			return false
+		}
+	}
+	return true
+}
+
+/*****************************************************************************\
+  Return a getting started message for first time users.
+\*****************************************************************************/
+
+func GettingStarted() string {
+	message := "It looks like this is your first time running " + ProgramName + "."
+	if _, ok := Config[std.optionKey("Configure")]; ok {
+		message += "  Run \"" + ProgramName + " --Configure\" to set up your configuration and credentials,"
+		message += " or see \"" + ProgramName + " --help\"."
+	} else {
+		message += "  See \"" + ProgramName + " --help\" for how to configure it."
+	}
+	return message
+}
+
+/*****************************************************************************\
+  Handle a missing secret on a first run: if interactive, run the wizard, so
+  the user can set up their configuration and secrets, and return true if it
+  completes; otherwise print the getting started message and return false.
+\*****************************************************************************/
+
+func firstRunSetup() bool {
+	if _, ok := Config[std.optionKey("Configure")]; !ok || !IsInteractive() {
+		return false
+	}
+	Show("%s", GettingStarted())
+	if err := ConfigureWizard(); err != nil {
+		Warn("%v", err)
+		return false
+	}
+	return true
+}